@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"main/duckweed/entities"
+	"main/mqtt"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// maxBuffered mirrors the firmware, which keeps this many readings in RAM
+	// while offline and sends them as one batch after reconnecting.
+	maxBuffered    = 500
+	publishTimeout = 5 * time.Second
+	// rebootChance is how likely a dropout ends with the board rebooting and
+	// losing its buffered readings, rather than just rejoining the network.
+	rebootChance = 0.3
+)
+
+var offlinePayload = mustMarshal(entities.BoardHeartbeatDto{Status: "offline"})
+
+// virtualBoard is one simulated board with its own MQTT connection, so the
+// broker publishes its Last Will when the simulator drops it.
+type virtualBoard struct {
+	id       string
+	password string
+	opts     simOptions
+	rng      *rand.Rand
+	stats    *simStats
+
+	telemetryTopic  string
+	statusTopic     string
+	commandTopic    string
+	commandAckTopic string
+	otaTopic        string
+
+	mutex       sync.Mutex
+	pond        *pondModel
+	client      paho.Client
+	conn        net.Conn
+	connected   bool
+	bootedAt    time.Time
+	resetReason string
+	firmware    string
+	seq         int64
+	buffer      []entities.InsertSensorLogDto
+}
+
+func newVirtualBoard(credential boardCredential, opts simOptions, seed int64, stats *simStats) (*virtualBoard, error) {
+	id := credential.boardID
+	params := map[string]string{"tenant": opts.tenant}
+	telemetryTopic, err := mqtt.RenderBoardTopic(opts.telemetryTopic, id, params)
+	if err != nil {
+		return nil, err
+	}
+	statusTopic, err := mqtt.RenderBoardTopic(opts.statusTopic, id, params)
+	if err != nil {
+		return nil, err
+	}
+	commandTopic, err := mqtt.RenderBoardTopic(opts.commandTopic, id, params)
+	if err != nil {
+		return nil, err
+	}
+	commandAckTopic, err := mqtt.RenderBoardTopic(opts.commandAckTopic, id, params)
+	if err != nil {
+		return nil, err
+	}
+	otaTopic, err := mqtt.RenderBoardTopic(opts.otaTopic, id, params)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	return &virtualBoard{
+		id:              id,
+		password:        credential.password,
+		opts:            opts,
+		rng:             rng,
+		stats:           stats,
+		telemetryTopic:  telemetryTopic,
+		statusTopic:     statusTopic,
+		commandTopic:    commandTopic,
+		commandAckTopic: commandAckTopic,
+		otaTopic:        otaTopic,
+		pond:            newPondModel(rng, opts),
+		bootedAt:        time.Now(),
+		resetReason:     "power_on",
+		firmware:        opts.firmware,
+	}, nil
+}
+
+func (b *virtualBoard) run(ctx context.Context) {
+	readTicker := time.NewTicker(b.opts.interval)
+	defer readTicker.Stop()
+	heartbeatTicker := time.NewTicker(b.opts.heartbeat)
+	defer heartbeatTicker.Stop()
+
+	lastTick := time.Now()
+	simTime := lastTick
+	var offlineUntil time.Time
+
+	if err := b.connect(); err != nil {
+		log.Printf("Board %s could not connect: %v", b.id, err)
+		b.stats.errors.Add(1)
+		offlineUntil = lastTick.Add(b.opts.interval)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.disconnect()
+			return
+
+		case now := <-heartbeatTicker.C:
+			if b.online() {
+				b.publishHeartbeat(now)
+			}
+
+		case now := <-readTicker.C:
+			elapsed := time.Duration(float64(now.Sub(lastTick)) * b.opts.speed)
+			simTime = simTime.Add(elapsed)
+			lastTick = now
+			reading := b.takeReading(simTime, elapsed, now)
+
+			if !b.online() {
+				if now.Before(offlineUntil) {
+					b.bufferReading(reading)
+					continue
+				}
+				if err := b.reconnect(now); err != nil {
+					b.stats.errors.Add(1)
+					b.bufferReading(reading)
+					offlineUntil = now.Add(b.opts.interval)
+					continue
+				}
+			}
+
+			b.publishReadings([]entities.InsertSensorLogDto{reading})
+			if b.chance(b.opts.dropoutRate) {
+				offlineUntil = now.Add(b.dropout())
+			}
+		}
+	}
+}
+
+func (b *virtualBoard) takeReading(simTime time.Time, elapsed time.Duration, now time.Time) entities.InsertSensorLogDto {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	values := b.pond.step(simTime, elapsed)
+	b.seq++
+	seq := b.seq
+	return entities.InsertSensorLogDto{
+		BoardID: b.id,
+		Values: map[entities.MetricEnum]float64{
+			entities.MetricTemperature: round(values.temperature, 2),
+			entities.MetricPh:          round(values.ph, 2),
+			entities.MetricEc:          round(values.ec, 0),
+		},
+		Timestamp: &entities.DeviceTime{Time: now},
+		Sequence:  &seq,
+	}
+}
+
+func (b *virtualBoard) bufferReading(reading entities.InsertSensorLogDto) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.buffer = append(b.buffer, reading)
+	if len(b.buffer) > maxBuffered {
+		b.buffer = b.buffer[len(b.buffer)-maxBuffered:]
+	}
+}
+
+// connect opens a fresh MQTT session with the Last Will set on the status
+// topic and announces the board with a heartbeat.
+func (b *virtualBoard) connect() error {
+	opts := paho.NewClientOptions().
+		AddBroker(b.opts.broker).
+		SetClientID(b.id).
+		SetCleanSession(true).
+		SetAutoReconnect(false).
+		SetKeepAlive(30*time.Second).
+		SetBinaryWill(b.statusTopic, offlinePayload, 1, false).
+		SetCustomOpenConnectionFn(b.dial).
+		SetConnectionLostHandler(func(paho.Client, error) {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			b.markOffline()
+		})
+	if b.password != "" {
+		opts.SetUsername(b.id).SetPassword(b.password)
+	}
+	opts.OnConnect = func(client paho.Client) {
+		client.Subscribe(b.commandTopic, 1, b.handleCommand)
+	}
+
+	client := paho.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(10*time.Second) || token.Error() != nil {
+		return fmt.Errorf("connect %s: %v", b.id, token.Error())
+	}
+
+	b.mutex.Lock()
+	b.client = client
+	b.connected = true
+	b.mutex.Unlock()
+	b.stats.online.Add(1)
+
+	b.publishHeartbeat(time.Now())
+	return nil
+}
+
+// dial keeps hold of the raw connection so dropout can cut it without a
+// DISCONNECT packet, which is what makes the broker send the Last Will.
+func (b *virtualBoard) dial(uri *url.URL, options paho.ClientOptions) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	switch uri.Scheme {
+	case "ssl", "tls", "mqtts", "tcps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", uri.Host, &tls.Config{ServerName: uri.Hostname()})
+	default:
+		conn, err = dialer.Dial("tcp", uri.Host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	b.conn = conn
+	b.mutex.Unlock()
+	return conn, nil
+}
+
+// dropout cuts the connection as a failing radio would and returns how long
+// the board stays away.
+func (b *virtualBoard) dropout() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	// paho does not always notice a connection closed underneath it, so the
+	// board is marked offline here rather than in the connection lost handler.
+	b.markOffline()
+	b.stats.dropouts.Add(1)
+
+	span := b.opts.maxDropout - b.opts.minDropout
+	if span <= 0 {
+		return b.opts.minDropout
+	}
+	return b.opts.minDropout + time.Duration(b.rng.Int63n(int64(span)))
+}
+
+// reconnect brings the board back after a dropout. A board that rebooted has
+// lost its buffer; one that only lost the network sends its backlog.
+func (b *virtualBoard) reconnect(now time.Time) error {
+	b.mutex.Lock()
+	if b.client != nil {
+		b.client.Disconnect(0)
+		b.client = nil
+	}
+	if b.rng.Float64() < rebootChance {
+		b.bootedAt = now
+		b.resetReason = "brownout"
+		b.seq = 0
+		b.buffer = nil
+	}
+	b.mutex.Unlock()
+
+	if err := b.connect(); err != nil {
+		return err
+	}
+	b.stats.reconnects.Add(1)
+
+	b.mutex.Lock()
+	backlog := b.buffer
+	b.buffer = nil
+	b.mutex.Unlock()
+	if len(backlog) > 0 {
+		b.publishReadings(backlog)
+	}
+	return nil
+}
+
+func (b *virtualBoard) disconnect() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.client != nil && b.connected {
+		b.client.Disconnect(250)
+	}
+	b.markOffline()
+	b.client = nil
+}
+
+// markOffline must be called with the mutex held.
+func (b *virtualBoard) markOffline() {
+	if b.connected {
+		b.connected = false
+		b.stats.online.Add(-1)
+	}
+}
+
+func (b *virtualBoard) online() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.connected
+}
+
+// publishReadings sends one reading as an object and a backlog as an array,
+// the two shapes the ingestion pipeline accepts.
+func (b *virtualBoard) publishReadings(readings []entities.InsertSensorLogDto) {
+	var payload []byte
+	if len(readings) == 1 {
+		payload = mustMarshal(readings[0])
+	} else {
+		payload = mustMarshal(readings)
+	}
+	if b.publish(b.telemetryTopic, payload) {
+		b.stats.messages.Add(1)
+		b.stats.readings.Add(uint64(len(readings)))
+	}
+}
+
+func (b *virtualBoard) publishHeartbeat(now time.Time) {
+	b.mutex.Lock()
+	uptime := int64(now.Sub(b.bootedAt) / time.Second)
+	resetReason := b.resetReason
+	firmware := b.firmware
+	rssi := -45 - b.rng.Intn(45)
+	freeHeap := int64(120000 + b.rng.Intn(40000))
+	b.mutex.Unlock()
+
+	heartbeat := entities.BoardHeartbeatDto{
+		Status:      "online",
+		Uptime:      &uptime,
+		Firmware:    &firmware,
+		Hardware:    &b.opts.hardware,
+		RSSI:        &rssi,
+		FreeHeap:    &freeHeap,
+		ResetReason: &resetReason,
+	}
+	if b.publish(b.statusTopic, mustMarshal(heartbeat)) {
+		b.stats.heartbeats.Add(1)
+	}
+}
+
+// handleCommand answers the downlink: "dose" runs the nutrient doser,
+// "reboot" restarts the board and "ota" installs a firmware release; anything
+// else is refused.
+func (b *virtualBoard) handleCommand(_ paho.Client, msg paho.Message) {
+	var command entities.BoardCommandMessage
+	if err := json.Unmarshal(msg.Payload(), &command); err != nil || command.CorrelationID == "" {
+		b.stats.errors.Add(1)
+		return
+	}
+	b.stats.commands.Add(1)
+
+	ack := entities.BoardCommandAckDto{CorrelationID: command.CorrelationID, Status: "ok"}
+	switch command.Command {
+	case "dose":
+		b.mutex.Lock()
+		b.pond.dose()
+		b.mutex.Unlock()
+	case "reboot":
+		b.mutex.Lock()
+		b.bootedAt = time.Now()
+		b.resetReason = "software"
+		b.seq = 0
+		b.mutex.Unlock()
+		defer b.publishHeartbeat(time.Now())
+	case entities.FirmwareCommand:
+		var params entities.FirmwareOtaParamsDto
+		if err := json.Unmarshal(command.Params, &params); err != nil || params.URL == "" {
+			ack.Status = "error"
+			ack.Error = "invalid ota params"
+			break
+		}
+		go b.installFirmware(params)
+	default:
+		ack.Status = "error"
+		ack.Error = "unsupported command " + command.Command
+	}
+	b.publish(b.commandAckTopic, mustMarshal(ack))
+}
+
+// installFirmware downloads the image from the signed link, checks it and
+// reboots into the new version, reporting each step on the OTA topic.
+func (b *virtualBoard) installFirmware(params entities.FirmwareOtaParamsDto) {
+	b.publishOtaProgress(params.UpdateID, "downloading", 0, "")
+	if err := downloadFirmware(params); err != nil {
+		b.publishOtaProgress(params.UpdateID, "failed", 0, err.Error())
+		return
+	}
+
+	b.publishOtaProgress(params.UpdateID, "installing", 50, "")
+	time.Sleep(2 * time.Second)
+	if b.chance(b.opts.otaFailureRate) {
+		b.publishOtaProgress(params.UpdateID, "failed", 50, "flash write failed")
+		return
+	}
+
+	now := time.Now()
+	b.mutex.Lock()
+	b.firmware = params.Version
+	b.bootedAt = now
+	b.resetReason = "ota"
+	b.seq = 0
+	b.mutex.Unlock()
+	b.publishOtaProgress(params.UpdateID, "succeeded", 100, "")
+	b.publishHeartbeat(now)
+}
+
+func downloadFirmware(params entities.FirmwareOtaParamsDto) error {
+	client := &http.Client{Timeout: time.Minute}
+	response, err := client.Get(params.URL)
+	if err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", response.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, response.Body); err != nil {
+		return fmt.Errorf("download failed: %v", err)
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != params.Checksum {
+		return fmt.Errorf("checksum mismatch: got %s", checksum)
+	}
+	return nil
+}
+
+func (b *virtualBoard) publishOtaProgress(updateID uint, state string, progress int, message string) {
+	b.publish(b.otaTopic, mustMarshal(entities.FirmwareProgressDto{
+		UpdateID: &updateID,
+		State:    state,
+		Progress: progress,
+		Error:    message,
+	}))
+}
+
+func (b *virtualBoard) publish(topic string, payload []byte) bool {
+	b.mutex.Lock()
+	client := b.client
+	connected := b.connected
+	b.mutex.Unlock()
+	if client == nil || !connected {
+		return false
+	}
+
+	token := client.Publish(topic, 1, false, payload)
+	if !token.WaitTimeout(publishTimeout) || token.Error() != nil {
+		log.Printf("Board %s failed to publish to %s: %v", b.id, topic, token.Error())
+		b.stats.errors.Add(1)
+		return false
+	}
+	return true
+}
+
+// chance draws from the board's random source, which the MQTT callbacks share
+// with the main loop.
+func (b *virtualBoard) chance(probability float64) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.rng.Float64() < probability
+}
+
+func mustMarshal(value any) []byte {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return payload
+}
+
+func round(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// boardCredential is the MQTT login of one provisioned board. The broker
+// expects the board ID as the username.
+type boardCredential struct {
+	boardID  string
+	password string
+}
+
+// loadCredentials reads a CSV of board_id,mqtt_password lines, as collected
+// from /v1/admin/boards/provision. A leading board_id header line, blank
+// lines and lines starting with # are skipped.
+func loadCredentials(path string) ([]boardCredential, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var credentials []boardCredential
+	seen := make(map[string]bool)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) != 2 {
+			return nil, fmt.Errorf("line %d: expected board_id,mqtt_password", line)
+		}
+		boardID, password := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if len(credentials) == 0 && boardID == "board_id" {
+			continue
+		}
+		if boardID == "" || password == "" {
+			return nil, fmt.Errorf("line %d: board ID and password must not be empty", line)
+		}
+		if seen[boardID] {
+			return nil, fmt.Errorf("line %d: board %s is listed twice", line, boardID)
+		}
+		seen[boardID] = true
+		credentials = append(credentials, boardCredential{boardID: boardID, password: password})
+	}
+	if len(credentials) == 0 {
+		return nil, errors.New("no boards listed")
+	}
+	return credentials, nil
+}
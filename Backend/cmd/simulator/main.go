@@ -0,0 +1,183 @@
+// Command simulator publishes telemetry and status messages for a fleet of
+// virtual boards to the configured MQTT broker. It is meant for demos, for
+// load-testing ingestion and the WebSocket fan-out, and for reproducing alert
+// scenarios.
+//
+// Run it from the Backend directory so config.yaml is found:
+//
+//	go run ./cmd/simulator -boards 50 -interval 5s -speed 60
+//
+// The boards must exist and be claimed for their readings to be stored;
+// otherwise they end up in the dead-letter table. When the broker requires
+// board credentials, provision the boards first and pass their passwords as
+// a CSV of board_id,mqtt_password lines; the listed boards are simulated
+// instead of -boards generated ones:
+//
+//	go run ./cmd/simulator -credentials boards.csv -interval 5s
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"main/config"
+)
+
+type simOptions struct {
+	broker          string
+	boards          int
+	prefix          string
+	credentials     string
+	tenant          string
+	interval        time.Duration
+	heartbeat       time.Duration
+	speed           float64
+	dropoutRate     float64
+	minDropout      time.Duration
+	maxDropout      time.Duration
+	faultRate       float64
+	doseThreshold   float64
+	doseAmount      float64
+	hardware        string
+	firmware        string
+	otaFailureRate  float64
+	seed            int64
+	duration        time.Duration
+	telemetryTopic  string
+	statusTopic     string
+	commandTopic    string
+	commandAckTopic string
+	otaTopic        string
+}
+
+// simStats counts what the fleet has done, for the periodic progress line.
+type simStats struct {
+	readings   atomic.Uint64
+	messages   atomic.Uint64
+	heartbeats atomic.Uint64
+	dropouts   atomic.Uint64
+	reconnects atomic.Uint64
+	commands   atomic.Uint64
+	errors     atomic.Uint64
+	online     atomic.Int64
+}
+
+func main() {
+	conf := config.GetConfig()
+
+	opts := simOptions{}
+	flag.StringVar(&opts.broker, "broker", defaultBroker(conf.MQTT), "MQTT broker URL")
+	flag.IntVar(&opts.boards, "boards", 10, "number of virtual boards")
+	flag.StringVar(&opts.prefix, "prefix", "sim-", "board ID prefix, boards are named <prefix>0001 and up")
+	flag.StringVar(&opts.credentials, "credentials", "", "CSV of board_id,mqtt_password for provisioned boards, replaces -boards and -prefix")
+	flag.StringVar(&opts.tenant, "tenant", "", "value for the {tenant} topic placeholder")
+	flag.DurationVar(&opts.interval, "interval", 10*time.Second, "time between readings of a board")
+	flag.DurationVar(&opts.heartbeat, "heartbeat", 30*time.Second, "time between heartbeats of a board")
+	flag.Float64Var(&opts.speed, "speed", 1, "how much faster than real time the simulated day runs")
+	flag.Float64Var(&opts.dropoutRate, "dropout", 0.002, "chance per reading that a board drops off the network")
+	flag.DurationVar(&opts.minDropout, "dropout-min", 20*time.Second, "shortest dropout")
+	flag.DurationVar(&opts.maxDropout, "dropout-max", 3*time.Minute, "longest dropout")
+	flag.Float64Var(&opts.faultRate, "fault", 0.001, "chance per reading of a probe fault value (-127 °C or pH 0)")
+	flag.Float64Var(&opts.doseThreshold, "dose-threshold", 900, "EC below which the nutrient doser runs")
+	flag.Float64Var(&opts.doseAmount, "dose-amount", 450, "EC added by one nutrient dose")
+	flag.StringVar(&opts.hardware, "hardware", "sim", "hardware model the boards report")
+	flag.StringVar(&opts.firmware, "firmware", "sim-1.0.0", "firmware version the boards boot with")
+	flag.Float64Var(&opts.otaFailureRate, "ota-failure", 0, "chance that a firmware update fails to install")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed, set it to replay a scenario")
+	flag.DurationVar(&opts.duration, "duration", 0, "stop after this long, 0 runs until interrupted")
+	flag.Parse()
+
+	var credentials []boardCredential
+	if opts.credentials != "" {
+		var err error
+		if credentials, err = loadCredentials(opts.credentials); err != nil {
+			log.Fatalf("Failed to load credentials from %s: %v", opts.credentials, err)
+		}
+		opts.boards = len(credentials)
+	}
+	if opts.boards <= 0 || opts.interval <= 0 || opts.speed <= 0 {
+		log.Fatalf("boards, interval and speed must be positive")
+	}
+	if opts.minDropout > opts.maxDropout {
+		log.Fatalf("dropout-min must not exceed dropout-max")
+	}
+	opts.telemetryTopic = firstTemplate(conf.MQTT.TopicTelemetry)
+	opts.statusTopic = firstTemplate(conf.MQTT.TopicStatus)
+	opts.commandTopic = conf.MQTT.TopicCommand
+	opts.commandAckTopic = firstTemplate(conf.MQTT.TopicCommandAck)
+	opts.otaTopic = firstTemplate(conf.MQTT.TopicOta)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if opts.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	log.Printf("Simulating %d boards against %s (seed %d)", opts.boards, opts.broker, opts.seed)
+
+	stats := &simStats{}
+	var wg sync.WaitGroup
+	for i := 1; i <= opts.boards; i++ {
+		credential := boardCredential{boardID: fmt.Sprintf("%s%04d", opts.prefix, i)}
+		if credentials != nil {
+			credential = credentials[i-1]
+		}
+		board, err := newVirtualBoard(credential, opts, opts.seed+int64(i), stats)
+		if err != nil {
+			log.Fatalf("Failed to set up board %d: %v", i, err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			board.run(ctx)
+		}()
+		// Stagger the connections so a large fleet does not hit the broker at once.
+		time.Sleep(opts.interval / time.Duration(opts.boards+1))
+	}
+
+	go reportStats(ctx, stats)
+	wg.Wait()
+	log.Printf("Simulation stopped: %s", stats)
+}
+
+func defaultBroker(conf *config.MQTT) string {
+	if conf.EmbeddedBroker {
+		return "tcp://" + conf.EmbeddedBrokerAddress
+	}
+	return conf.BrokerURL
+}
+
+func firstTemplate(templates []string) string {
+	if len(templates) == 0 {
+		return ""
+	}
+	return templates[0]
+}
+
+func reportStats(ctx context.Context, stats *simStats) {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			log.Printf("Progress: %s", stats)
+		}
+	}
+}
+
+func (s *simStats) String() string {
+	return fmt.Sprintf("%d online, %d readings in %d messages, %d heartbeats, %d dropouts, %d reconnects, %d commands, %d errors",
+		s.online.Load(), s.readings.Load(), s.messages.Load(), s.heartbeats.Load(),
+		s.dropouts.Load(), s.reconnects.Load(), s.commands.Load(), s.errors.Load())
+}
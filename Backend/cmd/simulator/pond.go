@@ -0,0 +1,92 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// pondModel is a rough model of a duckweed pond as seen by the three probes.
+// Temperature follows the sun with its peak mid-afternoon. pH drifts slowly,
+// rises with photosynthesis during the day and dips after every nutrient dose.
+// EC falls as the duckweed takes up nutrients and steps back up when the doser
+// runs, settling over a few minutes of simulated time as the dose mixes in.
+type pondModel struct {
+	rng *rand.Rand
+
+	baseTemperature  float64
+	swingTemperature float64
+
+	phDrift float64
+
+	ec       float64
+	ecTarget float64
+	// uptakePerHour is the share of nutrients consumed per simulated hour.
+	uptakePerHour float64
+
+	doseThreshold float64
+	doseAmount    float64
+	faultRate     float64
+}
+
+type pondReading struct {
+	temperature float64
+	ph          float64
+	ec          float64
+}
+
+func newPondModel(rng *rand.Rand, opts simOptions) *pondModel {
+	ec := 1000 + rng.Float64()*600
+	return &pondModel{
+		rng:              rng,
+		baseTemperature:  24 + rng.Float64()*4,
+		swingTemperature: 2 + rng.Float64()*2,
+		ec:               ec,
+		ecTarget:         ec,
+		uptakePerHour:    0.01 + rng.Float64()*0.02,
+		doseThreshold:    opts.doseThreshold,
+		doseAmount:       opts.doseAmount,
+		faultRate:        opts.faultRate,
+	}
+}
+
+// step advances the pond by elapsed simulated time and returns what the
+// probes read at simTime.
+func (m *pondModel) step(simTime time.Time, elapsed time.Duration) pondReading {
+	hours := elapsed.Hours()
+	hourOfDay := float64(simTime.Hour()) + float64(simTime.Minute())/60
+	// Peaks at 15:00 and bottoms out at 03:00.
+	sun := math.Sin(2 * math.Pi * (hourOfDay - 9) / 24)
+
+	m.phDrift += m.rng.NormFloat64() * 0.02 * math.Sqrt(hours)
+	m.phDrift *= 1 - math.Min(0.05*hours, 1)
+
+	m.ecTarget -= m.ecTarget * m.uptakePerHour * hours
+	if m.ecTarget < m.doseThreshold {
+		m.dose()
+	}
+	mixing := 1 - math.Exp(-elapsed.Minutes()/10)
+	m.ec += (m.ecTarget - m.ec) * mixing
+
+	reading := pondReading{
+		temperature: m.baseTemperature + m.swingTemperature*sun + m.rng.NormFloat64()*0.08,
+		ph:          7.0 + m.phDrift + 0.15*sun + m.rng.NormFloat64()*0.02,
+		ec:          m.ec + m.rng.NormFloat64()*5,
+	}
+
+	if m.rng.Float64() < m.faultRate {
+		if m.rng.Intn(2) == 0 {
+			reading.temperature = -127
+		} else {
+			reading.ph = 0
+		}
+	}
+	return reading
+}
+
+// dose adds nutrients; it runs on its own below the threshold and on demand
+// through the "dose" command.
+func (m *pondModel) dose() {
+	m.ecTarget += m.doseAmount
+	m.phDrift -= 0.05
+}
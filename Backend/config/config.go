@@ -1,6 +1,7 @@
 package config
 
 import (
+  "fmt"
   "strings"
   "sync"
   
@@ -12,12 +13,17 @@ type (
     Server *Server
     Db     *Db
     MQTT   *MQTT 
+    Quality *Quality
+    Firmware *Firmware
+    Liveness *Liveness
   }
   
   Server struct {
     Port int
     AllowOrigins string 
     JwtSecret string
+    // AdminUserIDs may use the /v1/admin routes.
+    AdminUserIDs []uint
   }
   
   Db struct {
@@ -32,9 +38,117 @@ type (
   MQTT struct { // Define MQTT configuration struct
 		BrokerURL string
 		ClientID  string
-    TopicTelemetry string
-    TopicStatus string
+    // Username and Password are the backend's own broker credentials. The
+    // username defaults to ClientID. The auth hook lets this client
+    // subscribe to every topic and publish to the command topics.
+    Username string
+    Password string
+    // AuthHookSecret must be sent by the broker to the /mqtt auth hook
+    // routes in the X-Auth-Hook-Secret header. The routes refuse every
+    // request while it is empty.
+    AuthHookSecret string
+    // TLS is used for ssl://, tls://, mqtts:// and wss:// broker URLs.
+    // CACertFile verifies the broker, and ClientCertFile and ClientKeyFile
+    // authenticate the backend with a certificate.
+    CACertFile string
+    ClientCertFile string
+    ClientKeyFile string
+    InsecureSkipVerify bool
+    // PersistentSession keeps the backend's session on the broker while it
+    // is away, so QoS 1 messages sent meanwhile are delivered when it comes
+    // back. ClientID must then be unique to this backend instance.
+    PersistentSession bool
+    KeepAliveSeconds int
+    ConnectTimeoutSeconds int
+    // ConnectRetrySeconds is the first wait between connection attempts. It
+    // doubles after each failure up to ReconnectMaxSeconds.
+    ConnectRetrySeconds int
+    ReconnectMaxSeconds int
+    // QoS of each subscription, and of the commands the backend publishes.
+    TelemetryQoS int
+    StatusQoS int
+    CommandAckQoS int
+    OtaQoS int
+    CommandQoS int
+    // EmbeddedBroker starts an in-process broker on EmbeddedBrokerAddress and
+    // connects to it instead of BrokerURL, for local development and tests.
+    EmbeddedBroker bool
+    EmbeddedBrokerAddress string
+    // Topic templates, e.g. farms/{tenant}/boards/{board}/telemetry. Inbound
+    // topics accept a list so old and new firmware layouts can coexist.
+    TopicTelemetry []string
+    TopicStatus []string
+    TopicCommandAck []string
+    // TopicOta is where boards report the progress of a firmware update.
+    TopicOta []string
+    // TopicCommand is the downlink template, {board} is replaced by the board ID.
+    TopicCommand string
+    // AllowedTenants restricts the {tenant} placeholder when not empty.
+    AllowedTenants []string
+    CommandTimeoutSeconds int
+    // Ingestion pipeline tuning, see mqtt/pipeline.go.
+    IngestWorkers int
+    IngestQueueSize int
+    IngestBatchSize int
+    IngestFlushIntervalMs int
+    LastSeenDebounceSeconds int
 	}
+
+  // Quality decides which readings are stored as suspect, see mqtt/quality.go.
+  Quality struct {
+    // Channels holds the limits of each channel by name; channels that are
+    // not listed only get spike detection with the defaults below.
+    Channels map[string]ChannelQuality
+    // SpikeWindow is how many recent good readings the median and MAD are
+    // taken over, and SpikeMinSamples how many are needed before judging.
+    SpikeWindow int
+    SpikeMinSamples int
+    // SpikeThreshold is how many scaled MADs from the median a reading may be.
+    SpikeThreshold float64
+  }
+
+  ChannelQuality struct {
+    Min *float64
+    Max *float64
+    // SpikeThreshold overrides Quality.SpikeThreshold when set; 0 disables
+    // spike detection for the channel.
+    SpikeThreshold *float64
+  }
+
+  // Firmware configures the release store and OTA rollouts, see
+  // usecases/firmware_usecase.go.
+  Firmware struct {
+    // StoragePath is the directory uploaded images are kept in.
+    StoragePath string
+    MaxUploadMB int
+    // PublicBaseURL is how boards reach this server, for example
+    // https://api.example.com. It defaults to http://localhost:<port>.
+    PublicBaseURL string
+    // SigningSecret signs download links; the JWT secret is used when empty.
+    SigningSecret string
+    DownloadURLTTLMinutes int
+    // UpdateTimeoutMinutes is how long a notified board has to report the
+    // result of an update before it counts as failed.
+    UpdateTimeoutMinutes int
+    // FailureThreshold is the share of failed updates that halts a rollout,
+    // checked once MinFailureSamples updates have finished. Rollouts may
+    // set their own threshold.
+    FailureThreshold float64
+    MinFailureSamples int
+  }
+
+  // Liveness decides when a silent board is marked inactive, see
+  // repositories/boardLivenessRepositories.go.
+  Liveness struct {
+    SweepIntervalSeconds int
+    // DefaultIntervalSeconds is how often a board is expected to report when
+    // none of its sensors has a SensorFrequency.
+    DefaultIntervalSeconds int
+    // GraceMultiplier is how many expected intervals a board may miss before
+    // it is marked inactive, and MinTimeoutSeconds the least time it gets.
+    GraceMultiplier float64
+    MinTimeoutSeconds int
+  }
 )
 
 var (
@@ -50,6 +164,48 @@ func GetConfig() *Config {
     viper.AddConfigPath("../..") 
     // use for main running
     viper.AddConfigPath("./")
+    viper.SetDefault("mqtt.embeddedBrokerAddress", "127.0.0.1:1883")
+    viper.SetDefault("mqtt.topicTelemetry", []string{"iot/{board}/telemetry"})
+    viper.SetDefault("mqtt.topicStatus", []string{"iot/{board}/status"})
+    viper.SetDefault("mqtt.topicCommand", "iot/{board}/cmd")
+    viper.SetDefault("mqtt.topicCommandAck", []string{"iot/{board}/cmd/ack"})
+    viper.SetDefault("mqtt.topicOta", []string{"iot/{board}/ota"})
+    viper.SetDefault("mqtt.commandTimeoutSeconds", 30)
+    viper.SetDefault("mqtt.keepAliveSeconds", 30)
+    viper.SetDefault("mqtt.connectTimeoutSeconds", 10)
+    viper.SetDefault("mqtt.connectRetrySeconds", 1)
+    viper.SetDefault("mqtt.reconnectMaxSeconds", 60)
+    viper.SetDefault("mqtt.telemetryQoS", 1)
+    viper.SetDefault("mqtt.statusQoS", 1)
+    viper.SetDefault("mqtt.commandAckQoS", 1)
+    viper.SetDefault("mqtt.otaQoS", 1)
+    viper.SetDefault("mqtt.commandQoS", 1)
+    viper.SetDefault("mqtt.ingestWorkers", 4)
+    viper.SetDefault("mqtt.ingestQueueSize", 2000)
+    viper.SetDefault("mqtt.ingestBatchSize", 200)
+    viper.SetDefault("mqtt.ingestFlushIntervalMs", 1000)
+    viper.SetDefault("mqtt.lastSeenDebounceSeconds", 10)
+    // -127 and 85 are the DS18B20 fault and power-on values; pH 0 is a
+    // disconnected probe.
+    viper.SetDefault("quality.channels.temperature.min", -20)
+    viper.SetDefault("quality.channels.temperature.max", 60)
+    viper.SetDefault("quality.channels.ph.min", 1)
+    viper.SetDefault("quality.channels.ph.max", 14)
+    viper.SetDefault("quality.channels.ec.min", 0)
+    viper.SetDefault("quality.channels.ec.max", 20000)
+    viper.SetDefault("quality.spikeWindow", 15)
+    viper.SetDefault("quality.spikeMinSamples", 5)
+    viper.SetDefault("quality.spikeThreshold", 6)
+    viper.SetDefault("firmware.storagePath", "./firmware")
+    viper.SetDefault("firmware.maxUploadMB", 16)
+    viper.SetDefault("firmware.downloadURLTTLMinutes", 60)
+    viper.SetDefault("firmware.updateTimeoutMinutes", 30)
+    viper.SetDefault("firmware.failureThreshold", 0.2)
+    viper.SetDefault("firmware.minFailureSamples", 3)
+    viper.SetDefault("liveness.sweepIntervalSeconds", 30)
+    viper.SetDefault("liveness.defaultIntervalSeconds", 20)
+    viper.SetDefault("liveness.graceMultiplier", 3)
+    viper.SetDefault("liveness.minTimeoutSeconds", 60)
     viper.AutomaticEnv()
     viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
     
@@ -60,7 +216,20 @@ func GetConfig() *Config {
     if err := viper.Unmarshal(&configInstance); err != nil {
       panic(err)
     }
+    
+    if err := configInstance.validate(); err != nil {
+      panic(err)
+    }
   })
   
   return configInstance
-}
\ No newline at end of file
+}
+
+// validate rejects settings that would silently turn a feature off.
+func (c *Config) validate() error {
+  // quality.go judges no reading before it has at least 3 samples.
+  if q := c.Quality; q != nil && q.SpikeWindow > 0 && max(q.SpikeMinSamples, 3) > q.SpikeWindow {
+    return fmt.Errorf("quality.spikeMinSamples (%d, at least 3) must not exceed quality.spikeWindow (%d), or spike detection never runs", q.SpikeMinSamples, q.SpikeWindow)
+  }
+  return nil
+}
@@ -0,0 +1,86 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AlertConditionEnum string
+type AlertIncidentStateEnum string
+
+const (
+	AlertConditionBelowMin     AlertConditionEnum = "below_min"
+	AlertConditionAboveMax     AlertConditionEnum = "above_max"
+	AlertConditionRateOfChange AlertConditionEnum = "rate_of_change"
+)
+
+const (
+	AlertIncidentOpen   AlertIncidentStateEnum = "open"
+	AlertIncidentClosed AlertIncidentStateEnum = "closed"
+)
+
+// AlertRule is a per-board, per-metric limit. A reading breaches the rule when
+// it leaves [MinValue, MaxValue] or changes faster than MaxRatePerMinute, and
+// the breach has to last HoldSeconds before an incident is opened. The
+// incident only closes once the value is back inside the limits by at least
+// Hysteresis.
+type AlertRule struct {
+	gorm.Model
+	BoardID          string     `json:"board_id" gorm:"not null;index"`
+	Metric           MetricEnum `json:"metric" gorm:"type:varchar(32);not null"`
+	MinValue         *float64   `json:"min_value"`
+	MaxValue         *float64   `json:"max_value"`
+	MaxRatePerMinute *float64   `json:"max_rate_per_minute"`
+	HoldSeconds      int64      `json:"hold_seconds"`
+	Hysteresis       float64    `json:"hysteresis"`
+	Enabled          bool       `json:"enabled"`
+}
+
+type AlertIncident struct {
+	gorm.Model
+	BoardID      string                 `json:"board_id" gorm:"not null;index"`
+	AlertRuleID  *uint                  `json:"alert_rule_id"`
+	Metric       MetricEnum             `json:"metric" gorm:"type:varchar(32);not null"`
+	Condition    AlertConditionEnum     `json:"condition" gorm:"type:varchar(20);check:condition IN ('below_min','above_max','rate_of_change')"`
+	State        AlertIncidentStateEnum `json:"state" gorm:"type:varchar(10);index;check:state IN ('open','closed')"`
+	TriggerValue float64                `json:"trigger_value"`
+	PeakValue    float64                `json:"peak_value"`
+	OpenedAt     time.Time              `json:"opened_at"`
+	ClosedAt     *time.Time             `json:"closed_at"`
+}
+
+type InsertAlertRuleDto struct {
+	Metric           MetricEnum `json:"metric" validate:"required,max=32"`
+	MinValue         *float64   `json:"min_value"`
+	MaxValue         *float64   `json:"max_value"`
+	MaxRatePerMinute *float64   `json:"max_rate_per_minute" validate:"omitempty,gt=0"`
+	HoldSeconds      int64      `json:"hold_seconds" validate:"gte=0"`
+	Hysteresis       float64    `json:"hysteresis" validate:"gte=0"`
+	Enabled          *bool      `json:"enabled"`
+}
+
+type AlertRuleResponseDto struct {
+	ID               uint       `json:"id"`
+	BoardID          string     `json:"board_id"`
+	Metric           MetricEnum `json:"metric"`
+	MinValue         *float64   `json:"min_value"`
+	MaxValue         *float64   `json:"max_value"`
+	MaxRatePerMinute *float64   `json:"max_rate_per_minute"`
+	HoldSeconds      int64      `json:"hold_seconds"`
+	Hysteresis       float64    `json:"hysteresis"`
+	Enabled          bool       `json:"enabled"`
+}
+
+type AlertIncidentResponseDto struct {
+	ID           uint                   `json:"id"`
+	BoardID      string                 `json:"board_id"`
+	AlertRuleID  *uint                  `json:"alert_rule_id"`
+	Metric       MetricEnum             `json:"metric"`
+	Condition    AlertConditionEnum     `json:"condition"`
+	State        AlertIncidentStateEnum `json:"state"`
+	TriggerValue float64                `json:"trigger_value"`
+	PeakValue    float64                `json:"peak_value"`
+	OpenedAt     time.Time              `json:"opened_at"`
+	ClosedAt     *time.Time             `json:"closed_at"`
+}
@@ -0,0 +1,32 @@
+package entities
+
+import (
+	"gorm.io/gorm"
+)
+
+// BoardChannel ties a channel name a board publishes to the Sensor definition
+// that describes it. Channels without a mapping fall back to the Sensor whose
+// SensorType equals the channel name.
+type BoardChannel struct {
+	gorm.Model
+	BoardID  string     `json:"board_id" gorm:"not null;uniqueIndex:idx_board_channels_board_channel"`
+	Channel  MetricEnum `json:"channel" gorm:"type:varchar(32);not null;uniqueIndex:idx_board_channels_board_channel"`
+	SensorID *uint      `json:"sensor_id"`
+	Label    *string    `json:"label"`
+	Sensor   *Sensor    `json:"-" gorm:"foreignKey:SensorID;references:SensorID"`
+}
+
+type InsertBoardChannelDto struct {
+	SensorID *uint   `json:"sensor_id" validate:"required"`
+	Label    *string `json:"label"`
+}
+
+// ChannelInfoDto describes a channel of a board with its sensor definition.
+type ChannelInfoDto struct {
+	Channel    MetricEnum `json:"channel"`
+	Label      *string    `json:"label,omitempty"`
+	SensorID   *uint      `json:"sensor_id,omitempty"`
+	SensorType *string    `json:"sensor_type,omitempty"`
+	Unit       *string    `json:"unit,omitempty"`
+	Precision  *int       `json:"precision,omitempty"`
+}
@@ -0,0 +1,68 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CommandStateEnum string
+
+const (
+	CommandStatePending  CommandStateEnum = "pending"
+	CommandStateAcked    CommandStateEnum = "acked"
+	CommandStateFailed   CommandStateEnum = "failed"
+	CommandStateTimedOut CommandStateEnum = "timed_out"
+)
+
+// BoardCommand is a downlink command published to a board. It stays pending
+// until the board acknowledges the CorrelationID on its ack topic or the
+// command timeout passes.
+type BoardCommand struct {
+	gorm.Model
+	CorrelationID string           `json:"correlation_id" gorm:"uniqueIndex;not null"`
+	BoardID       string           `json:"board_id" gorm:"not null;index"`
+	UserID        uint             `json:"user_id"`
+	Command       string           `json:"command" gorm:"type:varchar(64);not null"`
+	Params        *string          `json:"params" gorm:"type:jsonb"`
+	State         CommandStateEnum `json:"state" gorm:"type:varchar(20);index;check:state IN ('pending','acked','failed','timed_out')"`
+	SentAt        *time.Time       `json:"sent_at"`
+	AckedAt       *time.Time       `json:"acked_at"`
+	Result        *string          `json:"result" gorm:"type:jsonb"`
+	ErrorMessage  *string          `json:"error_message"`
+}
+
+type InsertBoardCommandDto struct {
+	Command string          `json:"command" validate:"required,max=64"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// BoardCommandMessage is the payload published on the board command topic.
+type BoardCommandMessage struct {
+	CorrelationID string          `json:"correlation_id"`
+	Command       string          `json:"command"`
+	Params        json.RawMessage `json:"params,omitempty"`
+}
+
+// BoardCommandAckDto is the payload a board publishes on its ack topic.
+// Status is "ok" when the command was applied and anything else otherwise.
+type BoardCommandAckDto struct {
+	CorrelationID string          `json:"correlation_id"`
+	Status        string          `json:"status"`
+	Error         string          `json:"error"`
+	Result        json.RawMessage `json:"result"`
+}
+
+type BoardCommandResponseDto struct {
+	CorrelationID string           `json:"correlation_id"`
+	BoardID       string           `json:"board_id"`
+	Command       string           `json:"command"`
+	Params        json.RawMessage  `json:"params,omitempty"`
+	State         CommandStateEnum `json:"state"`
+	SentAt        *time.Time       `json:"sent_at"`
+	AckedAt       *time.Time       `json:"acked_at"`
+	Result        json.RawMessage  `json:"result,omitempty"`
+	ErrorMessage  *string          `json:"error_message,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+}
@@ -11,6 +11,9 @@ type BoardStatusEnum string
 const (
 	BoardStatusActive   BoardStatusEnum = "active"
 	BoardStatusInactive BoardStatusEnum = "inactive"
+	// BoardStatusDisabled is set by the board's owner, never by the board.
+	// Telemetry of a disabled board is quarantined as dead letters and its
+	// heartbeats do not change its status.
 	BoardStatusDisabled BoardStatusEnum = "disabled"
 )
 
@@ -18,11 +21,47 @@ type Board struct {
 	gorm.Model
 	BoardID            string           `gorm:"unique;not null"`
 	BoardName          *string
+	// Location and Notes are free text the owner keeps about the board, such
+	// as where in the pond it hangs.
+	Location           *string
+	Notes              *string
+	// PondID is the Pond the board measures, if it was assigned to one.
+	PondID             *uint `gorm:"index"`
 	ConPassword *string          `json:"-"`
-	BoardStatus        *BoardStatusEnum `gorm:"type:varchar(20);check:board_status IN ('active','inactive','disabled')"`
+	BoardStatus        *BoardStatusEnum `gorm:"type:varchar(20);check:board_status IN ('active','inactive','disabled');index:idx_boards_status_last_seen,priority:1"`
 	BoardRegisterDate  *time.Time
-	LastSeen           *time.Time
+	// DisabledAt and DisabledReason are set while the board is disabled.
+	DisabledAt     *time.Time
+	DisabledReason *string
+	// LastSeen is when the board was last heard from. The liveness sweep
+	// finds overdue boards through idx_boards_status_last_seen.
+	LastSeen           *time.Time `gorm:"index:idx_boards_status_last_seen,priority:2"`
+	// RunTime is when the board last booted, derived from the uptime in its
+	// heartbeat, so the current uptime is time.Since(RunTime).
 	RunTime            *time.Time
+	// Fields reported by the last heartbeat on the status topic.
+	UptimeSeconds   *int64
+	FirmwareVersion *string
+	HardwareModel   *string
+	RSSI            *int
+	FreeHeap        *int64
+	ResetReason     *string
+	LastHeartbeatAt *time.Time
+	// DeviceTokenHash is the SHA-256 of the token devices without MQTT use
+	// on the HTTP ingest route. The token itself is only shown when issued.
+	DeviceTokenHash     *string `json:"-"`
+	DeviceTokenIssuedAt *time.Time
+	// ClaimCodeHash is the SHA-256 of the one-time code printed on the board
+	// at the factory. It is cleared when a user claims the board.
+	ClaimCodeHash *string `json:"-"`
+	ProvisionedAt *time.Time
+	ClaimedAt     *time.Time
+	// MqttPasswordHash is the SHA-256 of the board's own broker password.
+	// The board ID is its broker username.
+	MqttPasswordHash *string `json:"-"`
+	// Tenant is the {tenant} the board last published under, so commands
+	// reach it on tenant topic templates after a restart.
+	Tenant *string
 }
 
 type InsertBoardDto struct {
@@ -34,8 +73,59 @@ type BoardResponseDto struct {
 	ID                uint             `json:"id"`
 	BoardID           string           `json:"board_id"`
 	BoardName         *string          `json:"board_name"`
+	Location          *string          `json:"location"`
+	Notes             *string          `json:"notes"`
+	PondID            *uint            `json:"pond_id"`
 	BoardRegisterDate *time.Time       `json:"board_register_date"`
 	BoardStatus       *BoardStatusEnum `json:"board_status"`
+	DisabledAt        *time.Time       `json:"disabled_at"`
+	DisabledReason    *string          `json:"disabled_reason"`
 	LastSeen          *time.Time       `json:"last_seen"`
 	RunTime           *time.Time       `json:"run_time"`
+	UptimeSeconds     *int64           `json:"uptime_seconds"`
+	FirmwareVersion   *string          `json:"firmware_version"`
+	HardwareModel     *string          `json:"hardware_model"`
+	RSSI              *int             `json:"rssi"`
+	FreeHeap          *int64           `json:"free_heap"`
+	ResetReason       *string          `json:"reset_reason"`
+	LastHeartbeatAt   *time.Time       `json:"last_heartbeat_at"`
+}
+
+// DisableBoardDto takes a board out of service until it is enabled again.
+type DisableBoardDto struct {
+	Reason *string `json:"reason" validate:"omitempty,max=200"`
+}
+
+// UpdateBoardDto changes the user-editable fields of a board. Fields left out
+// are not changed; an empty string clears the field.
+type UpdateBoardDto struct {
+	BoardName *string `json:"board_name" validate:"omitempty,max=100"`
+	Location  *string `json:"location" validate:"omitempty,max=200"`
+	Notes     *string `json:"notes" validate:"omitempty,max=1000"`
+}
+
+// DeviceTokenResponseDto carries a newly issued device token. It is the only
+// time the token is returned.
+type DeviceTokenResponseDto struct {
+	BoardID  string    `json:"board_id"`
+	Token    string    `json:"token"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+type IngestTelemetryResponseDto struct {
+	BoardID  string `json:"board_id"`
+	Accepted int    `json:"accepted"`
+}
+
+// BoardHeartbeatDto is the JSON a board publishes on its status topic. Status
+// is "online" for a heartbeat and "offline" for the Last Will the broker sends
+// when the board drops off without disconnecting.
+type BoardHeartbeatDto struct {
+	Status      string  `json:"status"`
+	Uptime      *int64  `json:"uptime"`
+	Firmware    *string `json:"firmware"`
+	Hardware    *string `json:"hardware"`
+	RSSI        *int    `json:"rssi"`
+	FreeHeap    *int64  `json:"free_heap"`
+	ResetReason *string `json:"reset_reason"`
 }
@@ -0,0 +1,51 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type InvitationStatusEnum string
+
+const (
+	InvitationStatusPending  InvitationStatusEnum = "pending"
+	InvitationStatusAccepted InvitationStatusEnum = "accepted"
+	InvitationStatusDeclined InvitationStatusEnum = "declined"
+	InvitationStatusRevoked  InvitationStatusEnum = "revoked"
+	InvitationStatusExpired  InvitationStatusEnum = "expired"
+)
+
+// BoardInvitation offers a user a role on a board. The invitee is resolved
+// from the email or username the owner entered when the invitation is made,
+// and gets access only once they accept it.
+type BoardInvitation struct {
+	gorm.Model
+	BoardID         string               `json:"board_id" gorm:"not null;index"`
+	InvitedByUserID uint                 `json:"invited_by_user_id" gorm:"not null"`
+	InviteeUserID   uint                 `json:"invitee_user_id" gorm:"not null;index"`
+	Role            BoardRoleEnum        `json:"role" gorm:"type:varchar(20);not null;check:role IN ('operator','viewer')"`
+	Status          InvitationStatusEnum `json:"status" gorm:"type:varchar(20);not null;index;check:status IN ('pending','accepted','declined','revoked','expired')"`
+	ExpiresAt       time.Time            `json:"expires_at" gorm:"not null"`
+	RespondedAt     *time.Time           `json:"responded_at"`
+}
+
+// InsertBoardInvitationDto invites a user by email or by username.
+type InsertBoardInvitationDto struct {
+	Email    *string       `json:"email" validate:"required_without=Username,omitempty,email"`
+	Username *string       `json:"username" validate:"required_without=Email"`
+	Role     BoardRoleEnum `json:"role" validate:"required,oneof=operator viewer"`
+}
+
+type BoardInvitationResponseDto struct {
+	ID              uint                 `json:"id"`
+	BoardID         string               `json:"board_id"`
+	BoardName       *string              `json:"board_name,omitempty"`
+	InvitedByUserID uint                 `json:"invited_by_user_id"`
+	InviteeUserID   uint                 `json:"invitee_user_id"`
+	Role            BoardRoleEnum        `json:"role"`
+	Status          InvitationStatusEnum `json:"status"`
+	ExpiresAt       time.Time            `json:"expires_at"`
+	RespondedAt     *time.Time           `json:"responded_at"`
+	CreatedAt       time.Time            `json:"created_at"`
+}
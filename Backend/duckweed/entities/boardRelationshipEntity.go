@@ -17,35 +17,128 @@ const (
 const (
 	ConStatusActive   ConStatusEnum = "active"
 	ConStatusInactive ConStatusEnum = "inactive"
+	// ConStatusDisabled suspends one user's access to a board without
+	// removing them or touching the other users.
 	ConStatusDisabled ConStatusEnum = "disabled"
 )
 
+// BoardRoleEnum is what a user may do with a board. Each role includes the
+// rights of the roles below it.
+type BoardRoleEnum string
+
+const (
+	// BoardRoleViewer sees the board's readings, alerts and settings.
+	BoardRoleViewer BoardRoleEnum = "viewer"
+	// BoardRoleOperator also sends commands and changes alert rules,
+	// channels and calibrations.
+	BoardRoleOperator BoardRoleEnum = "operator"
+	// BoardRoleOwner also manages the board and who may use it. A claimed
+	// board has exactly one owner.
+	BoardRoleOwner BoardRoleEnum = "owner"
+)
+
+func (r BoardRoleEnum) rank() int {
+	switch r {
+	case BoardRoleViewer:
+		return 1
+	case BoardRoleOperator:
+		return 2
+	case BoardRoleOwner:
+		return 3
+	}
+	return 0
+}
+
+// Valid reports whether r is one of the known roles.
+func (r BoardRoleEnum) Valid() bool {
+	return r.rank() > 0
+}
+
+// Allows reports whether a user with role r has the rights of required.
+func (r BoardRoleEnum) Allows(required BoardRoleEnum) bool {
+	return r.rank() > 0 && r.rank() >= required.rank()
+}
+
 type BoardRelationship struct {
 	gorm.Model
 	ConStatus ConStatusEnum `gorm:"type:varchar(20);check:con_status IN ('active','inactive','disabled')"`
 	ConMethod ConMethodEnum `gorm:"type:varchar(20);check:con_method IN ('bluetooth','manual')"`
+	// Relationships made before roles existed default to owner; the migration
+	// then keeps only the earliest one per board as owner and makes the rest
+	// operators.
+	Role BoardRoleEnum `json:"role" gorm:"type:varchar(20);not null;default:'owner';check:role IN ('owner','operator','viewer')"`
 	BoardID string `json:"board_id"`
 	UserID  uint `json:"user_id"`
 	Board Board `gorm:"foreignKey:BoardID"`
 	User  User  `gorm:"foreignKey:UserID"`
 }
 
-// DTO for inserting a BoardRelationship
+// Grants reports whether the relationship currently gives the rights of
+// required: its role allows them and it is not disabled.
+func (r *BoardRelationship) Grants(required BoardRoleEnum) bool {
+	return r.ConStatus != ConStatusDisabled && r.Role.Allows(required)
+}
+
+// DTO for inserting a BoardRelationship. ClaimCode is the one-time code
+// that came with the board; UserID is taken from the JWT.
 type InsertBoardRelationshipDto struct {
-	BoardID   string          `json:"board_id"`
-	UserID    uint          `json:"user_id"`
+	BoardID   string        `json:"board_id" validate:"required"`
+	UserID    uint          `json:"-"`
 	ConMethod ConMethodEnum `json:"con_method"`
-	ConPassword *string `json:"con_password"`
-	BoardName *string `json:"board_name"`
-
+	ClaimCode string        `json:"claim_code" validate:"required"`
+	BoardName *string       `json:"board_name"`
 }
 
-// DTO for responding with BoardRelationship details
+// DTO for responding with BoardRelationship details. A board claimed after
+// a previous owner unclaimed it gets a new MQTT password, returned here the
+// only time it is shown; it has to be flashed onto the board before it can
+// connect again.
 type BoardRelationshipResponseDto struct {
 	BoardID   string          `json:"board_id"`
 	UserID    uint          `json:"user_id"`
+	Role      BoardRoleEnum `json:"role"`
 	ConStatus ConStatusEnum `json:"con_status"`
 	ConMethod ConMethodEnum `json:"con_method"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
+	MqttUsername *string    `json:"mqtt_username,omitempty"`
+	MqttPassword *string    `json:"mqtt_password,omitempty"`
+}
+
+// UserBoardResponseDto is one board in the signed-in user's board list, with
+// its live status and the latest reading it sent.
+type UserBoardResponseDto struct {
+	BoardResponseDto
+	Role          BoardRoleEnum         `json:"role"`
+	ConStatus     ConStatusEnum         `json:"con_status"`
+	ConMethod     ConMethodEnum         `json:"con_method"`
+	ConnectedAt   time.Time             `json:"connected_at"`
+	LatestReading *SensorLogResponseDto `json:"latest_reading"`
+}
+
+// UnclaimBoardResponseDto reports a removed relationship. When it was the
+// board's last one the board is unclaimed again, and the new claim code to
+// hand the board on with is returned here, the only time it is shown.
+type UnclaimBoardResponseDto struct {
+	BoardID   string  `json:"board_id"`
+	Unclaimed bool    `json:"unclaimed"`
+	ClaimCode *string `json:"claim_code,omitempty"`
+	QRPayload *string `json:"qr_payload,omitempty"`
+}
+
+// BoardMemberResponseDto is one user with access to a board.
+type BoardMemberResponseDto struct {
+	UserID      uint          `json:"user_id"`
+	UserName    *string       `json:"username"`
+	Role        BoardRoleEnum `json:"role"`
+	ConStatus   ConStatusEnum `json:"con_status"`
+	ConnectedAt time.Time     `json:"connected_at"`
+}
+
+type UpdateBoardMemberDto struct {
+	Role BoardRoleEnum `json:"role" validate:"required,oneof=operator viewer"`
+}
+
+type TransferBoardOwnershipDto struct {
+	UserID uint `json:"user_id" validate:"required"`
 }
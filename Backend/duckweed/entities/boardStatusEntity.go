@@ -6,11 +6,94 @@ import (
 	"gorm.io/gorm"
 )
 
+type BoardStatusCauseEnum string
+
+const (
+	// BoardStatusCauseHeartbeat is a status message published by the board.
+	BoardStatusCauseHeartbeat BoardStatusCauseEnum = "heartbeat"
+	// BoardStatusCauseLWT is the Last Will the broker publishes when the
+	// board drops off without disconnecting.
+	BoardStatusCauseLWT BoardStatusCauseEnum = "lwt"
+	// BoardStatusCauseTimeout is the backend marking a board offline that
+	// stopped reporting.
+	BoardStatusCauseTimeout BoardStatusCauseEnum = "timeout"
+	// BoardStatusCauseManualDisable and BoardStatusCauseManualEnable are the
+	// owner disabling the board and enabling it again.
+	BoardStatusCauseManualDisable BoardStatusCauseEnum = "manual_disable"
+	BoardStatusCauseManualEnable  BoardStatusCauseEnum = "manual_enable"
+)
+
+// BoardStatus is one change of a board's status. Board.BoardStatus holds only
+// the current status; these rows are its history.
 type BoardStatus struct {
 	gorm.Model
-	BoardID string `json:"boardId"`
-	UserID  int    `json:"userId"`
-	Status  string `json:"status"`
-	LastSeen time.Time `json:"lastSeen"`
-	User	User `gorm:"foreignKey:UserID"`
-}
\ No newline at end of file
+	BoardID    string               `json:"board_id" gorm:"not null;index:idx_board_statuses_board_changed,priority:1"`
+	FromStatus *BoardStatusEnum     `json:"from_status" gorm:"type:varchar(20)"`
+	Status     BoardStatusEnum      `json:"status" gorm:"type:varchar(20);not null"`
+	Cause      BoardStatusCauseEnum `json:"cause" gorm:"type:varchar(20);not null;check:cause IN ('heartbeat','lwt','timeout','manual_disable','manual_enable')"`
+	ChangedAt  time.Time            `json:"changed_at" gorm:"not null;index:idx_board_statuses_board_changed,priority:2"`
+}
+
+// NewBoardStatusTransition returns the history row for a board going from one
+// status to another, or nil when the status did not change.
+func NewBoardStatusTransition(boardID string, from *BoardStatusEnum, to BoardStatusEnum, cause BoardStatusCauseEnum, changedAt time.Time) *BoardStatus {
+	if from != nil && *from == to {
+		return nil
+	}
+	var previous *BoardStatusEnum
+	if from != nil {
+		value := *from
+		previous = &value
+	}
+	return &BoardStatus{
+		BoardID:    boardID,
+		FromStatus: previous,
+		Status:     to,
+		Cause:      cause,
+		ChangedAt:  changedAt,
+	}
+}
+
+type UptimeIntervalEnum string
+
+const (
+	UptimeIntervalDay  UptimeIntervalEnum = "day"
+	UptimeIntervalWeek UptimeIntervalEnum = "week"
+)
+
+type BoardStatusTransitionDto struct {
+	FromStatus *BoardStatusEnum     `json:"from_status"`
+	Status     BoardStatusEnum      `json:"status"`
+	Cause      BoardStatusCauseEnum `json:"cause"`
+	ChangedAt  time.Time            `json:"changed_at"`
+}
+
+// UptimeBucketDto is the uptime of one day or week. ObservedSeconds is the
+// time the board's status was known and it was not disabled; the percentage
+// is of that time, and nil when nothing was observed.
+type UptimeBucketDto struct {
+	BucketStart     time.Time `json:"bucket_start"`
+	BucketEnd       time.Time `json:"bucket_end"`
+	OnlineSeconds   int64     `json:"online_seconds"`
+	ObservedSeconds int64     `json:"observed_seconds"`
+	UptimePercent   *float64  `json:"uptime_percent"`
+	// Disconnects counts how often the board went from active to inactive,
+	// which is what flaky hardware shows up as.
+	Disconnects int `json:"disconnects"`
+}
+
+type BoardStatusHistoryResponseDto struct {
+	BoardID  string             `json:"board_id"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Interval UptimeIntervalEnum `json:"interval"`
+	// Timezone is where days and weeks start: the board's pond's timezone,
+	// or UTC.
+	Timezone string `json:"timezone"`
+	// InitialStatus is the status the board had at From, if known.
+	InitialStatus *BoardStatusEnum           `json:"initial_status"`
+	Transitions   []BoardStatusTransitionDto `json:"transitions"`
+	Uptime        []UptimeBucketDto          `json:"uptime"`
+	// UptimePercent is the uptime over the whole range.
+	UptimePercent *float64 `json:"uptime_percent"`
+}
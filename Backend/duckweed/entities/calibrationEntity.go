@@ -0,0 +1,76 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CalibrationMethodEnum string
+
+const (
+	// CalibrationOffset shifts every reading by Offset.
+	CalibrationOffset CalibrationMethodEnum = "offset"
+	// CalibrationLinear applies Slope and Offset as entered.
+	CalibrationLinear CalibrationMethodEnum = "linear"
+	// CalibrationTwoPoint derives Slope and Offset from two buffer solutions
+	// of known value and the raw readings the probe gave in each.
+	CalibrationTwoPoint CalibrationMethodEnum = "two_point"
+)
+
+// BoardCalibration corrects the raw readings of one channel of a board from
+// ValidFrom until the next calibration of the same channel. Every method is
+// stored in the normalised form calibrated = raw * Slope + Offset.
+type BoardCalibration struct {
+	gorm.Model
+	BoardID       string                `json:"board_id" gorm:"not null;index:idx_board_calibrations_lookup,priority:1"`
+	Channel       MetricEnum            `json:"channel" gorm:"type:varchar(32);not null;index:idx_board_calibrations_lookup,priority:2"`
+	Method        CalibrationMethodEnum `json:"method" gorm:"type:varchar(20);not null;check:method IN ('offset','linear','two_point')"`
+	Slope         float64               `json:"slope"`
+	Offset        float64               `json:"offset"`
+	RawLow        *float64              `json:"raw_low"`
+	ReferenceLow  *float64              `json:"reference_low"`
+	RawHigh       *float64              `json:"raw_high"`
+	ReferenceHigh *float64              `json:"reference_high"`
+	ValidFrom     time.Time             `json:"valid_from" gorm:"not null;index:idx_board_calibrations_lookup,priority:3"`
+	CalibratedBy  uint                  `json:"calibrated_by"`
+	Note          *string               `json:"note"`
+}
+
+// Apply returns the calibrated value of a raw reading.
+func (c *BoardCalibration) Apply(raw float64) float64 {
+	return raw*c.Slope + c.Offset
+}
+
+type InsertCalibrationDto struct {
+	Channel       MetricEnum            `json:"channel" validate:"required,max=32"`
+	Method        CalibrationMethodEnum `json:"method" validate:"required,oneof=offset linear two_point"`
+	Slope         *float64              `json:"slope"`
+	Offset        *float64              `json:"offset"`
+	RawLow        *float64              `json:"raw_low"`
+	ReferenceLow  *float64              `json:"reference_low"`
+	RawHigh       *float64              `json:"raw_high"`
+	ReferenceHigh *float64              `json:"reference_high"`
+}
+
+// InsertCalibrationSessionDto records the calibrations done during one visit
+// to a board. ValidFrom defaults to now and may lie in the past when the
+// session is entered after the fact.
+type InsertCalibrationSessionDto struct {
+	ValidFrom    *time.Time             `json:"valid_from"`
+	Note         *string                `json:"note"`
+	Calibrations []InsertCalibrationDto `json:"calibrations" validate:"required,min=1,dive"`
+}
+
+// RecomputeCalibrationDto selects the stored measurements to recalibrate.
+// Empty fields mean every channel and the whole history.
+type RecomputeCalibrationDto struct {
+	Channel *MetricEnum `json:"channel"`
+	From    *time.Time  `json:"from"`
+	To      *time.Time  `json:"to"`
+}
+
+type RecomputeCalibrationResponseDto struct {
+	Channels []MetricEnum `json:"channels"`
+	Updated  int64        `json:"updated"`
+}
@@ -0,0 +1,65 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeadLetterReasonEnum string
+
+const (
+	DeadLetterMalformedTopic DeadLetterReasonEnum = "malformed_topic"
+	DeadLetterInvalidPayload DeadLetterReasonEnum = "invalid_payload"
+	DeadLetterUnknownBoard   DeadLetterReasonEnum = "unknown_board"
+	DeadLetterUnclaimedBoard DeadLetterReasonEnum = "unclaimed_board"
+	DeadLetterInternalError  DeadLetterReasonEnum = "internal_error"
+	// DeadLetterDisabledBoard quarantines telemetry of a disabled board. It
+	// can be replayed once the board is enabled again.
+	DeadLetterDisabledBoard DeadLetterReasonEnum = "disabled_board"
+	// DeadLetterPersistFailed keeps telemetry that passed validation but
+	// could not be stored, for example during a database outage.
+	DeadLetterPersistFailed DeadLetterReasonEnum = "persist_failed"
+)
+
+// DeadLetterMessage keeps an MQTT message the ingestion path refused, so it
+// can be inspected and replayed once the cause is fixed.
+type DeadLetterMessage struct {
+	gorm.Model
+	Topic       string               `json:"topic" gorm:"not null"`
+	BoardID     *string              `json:"board_id" gorm:"index"`
+	Payload     []byte               `json:"payload" gorm:"type:bytea"`
+	ReasonCode  DeadLetterReasonEnum `json:"reason_code" gorm:"type:varchar(32);index;not null"`
+	Detail      string               `json:"detail"`
+	ReceivedAt  time.Time            `json:"received_at" gorm:"index"`
+	ReplayCount int                  `json:"replay_count"`
+	ReplayedAt  *time.Time           `json:"replayed_at"`
+}
+
+type DeadLetterFilterDto struct {
+	BoardID         string
+	ReasonCode      DeadLetterReasonEnum
+	Before          *time.Time
+	IncludeReplayed bool
+	Limit           int
+}
+
+type DeadLetterResponseDto struct {
+	ID            uint                 `json:"id"`
+	Topic         string               `json:"topic"`
+	BoardID       *string              `json:"board_id"`
+	ReasonCode    DeadLetterReasonEnum `json:"reason_code"`
+	Detail        string               `json:"detail"`
+	ReceivedAt    time.Time            `json:"received_at"`
+	ReplayCount   int                  `json:"replay_count"`
+	ReplayedAt    *time.Time           `json:"replayed_at"`
+	PayloadText   *string              `json:"payload_text,omitempty"`
+	PayloadBase64 string               `json:"payload_base64"`
+}
+
+type DeadLetterReplayResultDto struct {
+	ID       uint   `json:"id"`
+	Replayed bool   `json:"replayed"`
+	Readings int    `json:"readings"`
+	Error    string `json:"error,omitempty"`
+}
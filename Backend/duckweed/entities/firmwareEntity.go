@@ -0,0 +1,157 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FirmwareCommand is the board command that tells a board to install a
+// release. Its params are a FirmwareOtaParamsDto.
+const FirmwareCommand = "ota"
+
+type RolloutStateEnum string
+
+const (
+	RolloutStateActive    RolloutStateEnum = "active"
+	RolloutStateHalted    RolloutStateEnum = "halted"
+	RolloutStateCompleted RolloutStateEnum = "completed"
+	RolloutStateCancelled RolloutStateEnum = "cancelled"
+)
+
+type FirmwareUpdateStateEnum string
+
+const (
+	// FirmwareUpdatePending waits for the rollout to reach the board's cohort.
+	FirmwareUpdatePending     FirmwareUpdateStateEnum = "pending"
+	FirmwareUpdateNotified    FirmwareUpdateStateEnum = "notified"
+	FirmwareUpdateDownloading FirmwareUpdateStateEnum = "downloading"
+	FirmwareUpdateInstalling  FirmwareUpdateStateEnum = "installing"
+	FirmwareUpdateSucceeded   FirmwareUpdateStateEnum = "succeeded"
+	FirmwareUpdateFailed      FirmwareUpdateStateEnum = "failed"
+	FirmwareUpdateCancelled   FirmwareUpdateStateEnum = "cancelled"
+)
+
+// Finished reports whether the update has reached a final state.
+func (s FirmwareUpdateStateEnum) Finished() bool {
+	return s == FirmwareUpdateSucceeded || s == FirmwareUpdateFailed || s == FirmwareUpdateCancelled
+}
+
+// FirmwareRelease is an uploaded firmware image for one hardware model. The
+// image is stored under its SHA-256 checksum in the firmware storage path.
+type FirmwareRelease struct {
+	gorm.Model
+	HardwareModel string  `json:"hardware_model" gorm:"type:varchar(64);not null;uniqueIndex:idx_firmware_releases_model_version,priority:1"`
+	Version       string  `json:"version" gorm:"type:varchar(64);not null;uniqueIndex:idx_firmware_releases_model_version,priority:2"`
+	Checksum      string  `json:"checksum" gorm:"type:char(64);not null"`
+	Size          int64   `json:"size"`
+	FilePath      string  `json:"-" gorm:"not null"`
+	Notes         *string `json:"notes"`
+	UploadedBy    uint    `json:"uploaded_by"`
+}
+
+// FirmwareRollout brings a release to a set of boards in stages. Stages holds
+// the cumulative share of boards, in percent, reached at each stage, such as
+// [10,50,100]. The rollout halts by itself once more than FailureThreshold of
+// its finished updates have failed, judged after MinFailureSamples updates.
+// Resuming a halted rollout accepts the failures so far: only updates that
+// finish after AcknowledgedFinished count towards halting it again.
+type FirmwareRollout struct {
+	gorm.Model
+	ReleaseID            uint             `json:"release_id" gorm:"not null;index"`
+	Release              *FirmwareRelease `json:"release,omitempty"`
+	State                RolloutStateEnum `json:"state" gorm:"type:varchar(20);not null;index;check:state IN ('active','halted','completed','cancelled')"`
+	Stages               string           `json:"stages" gorm:"type:jsonb;not null"`
+	StageIndex           int              `json:"stage_index"`
+	StageStartedAt       *time.Time       `json:"stage_started_at"`
+	FailureThreshold     float64          `json:"failure_threshold"`
+	MinFailureSamples    int              `json:"min_failure_samples"`
+	AcknowledgedFailed   int              `json:"acknowledged_failed"`
+	AcknowledgedFinished int              `json:"acknowledged_finished"`
+	HaltReason           *string          `json:"halt_reason"`
+	CompletedAt          *time.Time       `json:"completed_at"`
+	CreatedBy            uint             `json:"created_by"`
+}
+
+// FirmwareUpdate tracks one board through a rollout. Cohort places the board
+// in the rollout order, from 0 to 99; the board is notified once the current
+// stage percentage exceeds it.
+type FirmwareUpdate struct {
+	gorm.Model
+	RolloutID     uint                    `json:"rollout_id" gorm:"not null;uniqueIndex:idx_firmware_updates_rollout_board,priority:1"`
+	ReleaseID     uint                    `json:"release_id" gorm:"not null"`
+	BoardID       string                  `json:"board_id" gorm:"not null;index;uniqueIndex:idx_firmware_updates_rollout_board,priority:2"`
+	Cohort        int                     `json:"cohort"`
+	State         FirmwareUpdateStateEnum `json:"state" gorm:"type:varchar(20);not null;index;check:state IN ('pending','notified','downloading','installing','succeeded','failed','cancelled')"`
+	Progress      int                     `json:"progress"`
+	FromVersion   *string                 `json:"from_version"`
+	CorrelationID *string                 `json:"correlation_id" gorm:"index"`
+	NotifiedAt    *time.Time              `json:"notified_at"`
+	FinishedAt    *time.Time              `json:"finished_at"`
+	ErrorMessage  *string                 `json:"error_message"`
+}
+
+// InsertFirmwareReleaseDto holds the form fields sent with an image upload.
+// Checksum is optional; when given, the upload must match it.
+type InsertFirmwareReleaseDto struct {
+	HardwareModel string  `form:"hardware_model" validate:"required,max=64"`
+	Version       string  `form:"version" validate:"required,max=64"`
+	Checksum      string  `form:"checksum" validate:"omitempty,len=64,hexadecimal"`
+	Notes         *string `form:"notes"`
+}
+
+// InsertFirmwareRolloutDto starts a rollout. Without BoardIDs it targets every
+// board of the release's hardware model. Stages defaults to [100], which
+// sends the release to all target boards at once.
+type InsertFirmwareRolloutDto struct {
+	ReleaseID        uint     `json:"release_id" validate:"required"`
+	BoardIDs         []string `json:"board_ids" validate:"omitempty,dive,required"`
+	Stages           []int    `json:"stages" validate:"omitempty,max=10,dive,min=1,max=100"`
+	FailureThreshold *float64 `json:"failure_threshold" validate:"omitempty,gt=0,lte=1"`
+}
+
+type FirmwareRolloutResponseDto struct {
+	ID                uint                            `json:"id"`
+	Release           *FirmwareRelease                `json:"release"`
+	State             RolloutStateEnum                `json:"state"`
+	Stages            []int                           `json:"stages"`
+	StageIndex        int                             `json:"stage_index"`
+	StagePercent      int                             `json:"stage_percent"`
+	StageStartedAt    *time.Time                      `json:"stage_started_at"`
+	FailureThreshold  float64                         `json:"failure_threshold"`
+	MinFailureSamples int                             `json:"min_failure_samples"`
+	FailureRate       float64                         `json:"failure_rate"`
+	Boards            int                             `json:"boards"`
+	Updates           map[FirmwareUpdateStateEnum]int `json:"updates"`
+	HaltReason        *string                         `json:"halt_reason"`
+	CreatedBy         uint                            `json:"created_by"`
+	CreatedAt         time.Time                       `json:"created_at"`
+	CompletedAt       *time.Time                      `json:"completed_at"`
+}
+
+// FirmwareOtaParamsDto is sent as the params of the ota command. URL is a
+// signed download link that stops working at ExpiresAt.
+type FirmwareOtaParamsDto struct {
+	UpdateID  uint      `json:"update_id"`
+	Version   string    `json:"version"`
+	URL       string    `json:"url"`
+	Checksum  string    `json:"checksum"`
+	Size      int64     `json:"size"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FirmwareProgressDto is what a board publishes on its OTA topic while it
+// installs a release. State is downloading, installing, succeeded or failed.
+type FirmwareProgressDto struct {
+	UpdateID *uint  `json:"update_id"`
+	State    string `json:"state"`
+	Progress int    `json:"progress"`
+	Error    string `json:"error"`
+}
+
+type BoardFirmwareDto struct {
+	BoardID         string           `json:"board_id"`
+	HardwareModel   *string          `json:"hardware_model"`
+	FirmwareVersion *string          `json:"firmware_version"`
+	Updates         []FirmwareUpdate `json:"updates"`
+}
@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+// IngestionMetricsDto is a snapshot of the telemetry ingestion pipeline used
+// to spot backpressure: a growing queue depth or dropped count means workers
+// or the database cannot keep up with the broker.
+type IngestionMetricsDto struct {
+	Workers       int    `json:"workers"`
+	QueueDepth    int64  `json:"queue_depth"`
+	QueueCapacity int64  `json:"queue_capacity"`
+	Received      uint64 `json:"received"`
+	Dropped       uint64 `json:"dropped"`
+	Rejected      uint64 `json:"rejected"`
+	Persisted     uint64 `json:"persisted"`
+	// Duplicates counts redelivered readings that were already stored.
+	Duplicates  uint64 `json:"duplicates"`
+	Suspect     uint64 `json:"suspect_measurements"`
+	PendingRows int64  `json:"pending_rows"`
+	Flushes     uint64 `json:"flushes"`
+	FlushErrors uint64 `json:"flush_errors"`
+	// PersistFailed counts messages that could not be stored and were
+	// dead-lettered instead.
+	PersistFailed    uint64     `json:"persist_failed"`
+	LastFlushAt      *time.Time `json:"last_flush_at"`
+	LastFlushMs      int64      `json:"last_flush_ms"`
+	BoardCacheHits   uint64     `json:"board_cache_hits"`
+	BoardCacheMisses uint64     `json:"board_cache_misses"`
+}
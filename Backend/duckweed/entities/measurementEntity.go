@@ -0,0 +1,81 @@
+package entities
+
+import (
+	"regexp"
+	"time"
+)
+
+// Measurement is the value of one channel within a SensorLog reading. BoardID
+// and CreatedAt are copied from the reading so history queries stay on this
+// table. Rows are append-only and are not soft-deleted. Value is the
+// calibrated reading; RawValue keeps what the board sent so the history can be
+// recalibrated later. Quality marks readings the ingestion filter did not
+// trust; they are kept for inspection but left out of aggregates and alerts.
+type Measurement struct {
+	ID            uint               `json:"-" gorm:"primaryKey"`
+	SensorLogID   uint               `json:"-" gorm:"not null;index"`
+	BoardID       string             `json:"-" gorm:"not null;index:idx_measurements_board_channel_time,priority:1"`
+	Channel       MetricEnum         `json:"channel" gorm:"type:varchar(32);not null;index:idx_measurements_board_channel_time,priority:2"`
+	Value         float64            `json:"value"`
+	RawValue      *float64           `json:"raw_value"`
+	CalibrationID *uint              `json:"calibration_id"`
+	Quality       QualityEnum        `json:"quality" gorm:"type:varchar(10);not null;default:good;check:quality IN ('good','suspect')"`
+	QualityReason *QualityReasonEnum `json:"quality_reason"`
+	CreatedAt     time.Time          `json:"-" gorm:"not null;index:idx_measurements_board_channel_time,priority:3"`
+}
+
+// MeasurementBucketRow is one downsampled bucket of a channel as scanned from
+// the measurements table.
+type MeasurementBucketRow struct {
+	BucketStart time.Time
+	Channel     MetricEnum
+	Count       int64
+	Min         *float64
+	Avg         *float64
+	Max         *float64
+}
+
+// MeasurementDto is a channel value decorated with its sensor definition, as
+// sent over the WebSocket.
+type MeasurementDto struct {
+	Channel  MetricEnum `json:"channel"`
+	Value    float64    `json:"value"`
+	RawValue *float64   `json:"raw_value,omitempty"`
+	// Quality and QualityReason are only set on suspect readings.
+	Quality       QualityEnum        `json:"quality,omitempty"`
+	QualityReason *QualityReasonEnum `json:"quality_reason,omitempty"`
+	SensorType    *string            `json:"sensor_type,omitempty"`
+	Unit          *string            `json:"unit,omitempty"`
+}
+
+// TelemetryPointDto is a reading in the generic channel form.
+type TelemetryPointDto struct {
+	ID           uint             `json:"id"`
+	BoardID      string           `json:"board_id"`
+	Sequence     *int64           `json:"seq,omitempty"`
+	Measurements []MeasurementDto `json:"measurements"`
+	CreatedAt    time.Time        `json:"created_at"`
+}
+
+type QualityEnum string
+type QualityReasonEnum string
+
+const (
+	QualityGood    QualityEnum = "good"
+	QualitySuspect QualityEnum = "suspect"
+)
+
+const (
+	// QualityOutOfRange is a value outside the plausible range of the channel.
+	QualityOutOfRange QualityReasonEnum = "out_of_range"
+	// QualitySpike is a value far from the recent median of the channel.
+	QualitySpike QualityReasonEnum = "spike"
+)
+
+var channelNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// IsValidChannel reports whether the name is usable as a channel: lowercase
+// letters, digits and underscores, at most 32 characters.
+func (m MetricEnum) IsValidChannel() bool {
+	return channelNamePattern.MatchString(string(m))
+}
@@ -0,0 +1,14 @@
+package entities
+
+// MqttAuthRequestDto is what the broker sends to the auth hook, as JSON or as
+// a form. mosquitto-go-auth sends Acc, EMQX sends Action.
+type MqttAuthRequestDto struct {
+	Username string `json:"username" form:"username"`
+	Password string `json:"password" form:"password"`
+	ClientID string `json:"clientid" form:"clientid"`
+	Topic    string `json:"topic" form:"topic"`
+	// Acc is 1 for read, 2 for write, 3 for both and 4 for subscribe.
+	Acc int `json:"acc" form:"acc"`
+	// Action is publish or subscribe.
+	Action string `json:"action" form:"action"`
+}
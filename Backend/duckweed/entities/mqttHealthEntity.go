@@ -0,0 +1,24 @@
+package entities
+
+import "time"
+
+type MqttSubscriptionDto struct {
+	Topic         string     `json:"topic"`
+	QoS           byte       `json:"qos"`
+	Subscribed    bool       `json:"subscribed"`
+	Error         *string    `json:"error,omitempty"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty"`
+}
+
+// MqttHealthDto describes the backend's connection to the broker. It is
+// healthy while connected with every subscription in place.
+type MqttHealthDto struct {
+	Healthy        bool                  `json:"healthy"`
+	Connected      bool                  `json:"connected"`
+	Broker         string                `json:"broker"`
+	ConnectedSince *time.Time            `json:"connected_since"`
+	Reconnects     uint64                `json:"reconnects"`
+	LastError      *string               `json:"last_error"`
+	LastErrorAt    *time.Time            `json:"last_error_at"`
+	Subscriptions  []MqttSubscriptionDto `json:"subscriptions"`
+}
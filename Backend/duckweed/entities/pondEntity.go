@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Pond is a physical pond or tank of a user. Boards are assigned to the pond
+// they measure, and pond-health scans to the pond they were taken of.
+type Pond struct {
+	gorm.Model
+	UserID uint   `json:"user_id" gorm:"not null;index"`
+	Name   string `json:"name" gorm:"not null"`
+	// VolumeLiters and SurfaceAreaM2 size the pond for dosing and stocking.
+	VolumeLiters  *float64 `json:"volume_liters"`
+	SurfaceAreaM2 *float64 `json:"surface_area_m2"`
+	Location      *string  `json:"location"`
+	Latitude      *float64 `json:"latitude"`
+	Longitude     *float64 `json:"longitude"`
+	// Species and Strain name what is grown, such as "Wolffia globosa".
+	Species *string `json:"species"`
+	Strain  *string `json:"strain"`
+	// Timezone is the IANA name of the pond's local time, used to show its
+	// readings by local day.
+	Timezone string `json:"timezone" gorm:"type:varchar(64);not null;default:'UTC'"`
+}
+
+type InsertPondDto struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	VolumeLiters  *float64 `json:"volume_liters" validate:"omitempty,gt=0"`
+	SurfaceAreaM2 *float64 `json:"surface_area_m2" validate:"omitempty,gt=0"`
+	Location      *string  `json:"location" validate:"omitempty,max=200"`
+	Latitude      *float64 `json:"latitude" validate:"omitempty,min=-90,max=90"`
+	Longitude     *float64 `json:"longitude" validate:"omitempty,min=-180,max=180"`
+	Species       *string  `json:"species" validate:"omitempty,max=100"`
+	Strain        *string  `json:"strain" validate:"omitempty,max=100"`
+	Timezone      *string  `json:"timezone" validate:"omitempty,max=64"`
+}
+
+// UpdatePondDto changes the fields that are set and leaves the rest as they
+// are.
+type UpdatePondDto struct {
+	Name          *string  `json:"name" validate:"omitempty,min=1,max=100"`
+	VolumeLiters  *float64 `json:"volume_liters" validate:"omitempty,gt=0"`
+	SurfaceAreaM2 *float64 `json:"surface_area_m2" validate:"omitempty,gt=0"`
+	Location      *string  `json:"location" validate:"omitempty,max=200"`
+	Latitude      *float64 `json:"latitude" validate:"omitempty,min=-90,max=90"`
+	Longitude     *float64 `json:"longitude" validate:"omitempty,min=-180,max=180"`
+	Species       *string  `json:"species" validate:"omitempty,max=100"`
+	Strain        *string  `json:"strain" validate:"omitempty,max=100"`
+	Timezone      *string  `json:"timezone" validate:"omitempty,max=64"`
+}
+
+type AssignBoardPondDto struct {
+	PondID uint `json:"pond_id" validate:"required"`
+}
+
+type PondResponseDto struct {
+	ID            uint      `json:"id"`
+	UserID        uint      `json:"user_id"`
+	Name          string    `json:"name"`
+	VolumeLiters  *float64  `json:"volume_liters"`
+	SurfaceAreaM2 *float64  `json:"surface_area_m2"`
+	Location      *string   `json:"location"`
+	Latitude      *float64  `json:"latitude"`
+	Longitude     *float64  `json:"longitude"`
+	Species       *string   `json:"species"`
+	Strain        *string   `json:"strain"`
+	Timezone      string    `json:"timezone"`
+	BoardIDs      []string  `json:"board_ids"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// PondBoardDto is a board of the pond with its latest reading.
+type PondBoardDto struct {
+	BoardResponseDto
+	LatestReading *SensorLogResponseDto `json:"latest_reading"`
+}
+
+// PondOverviewDto is everything known about a pond right now: its boards and
+// their latest readings, the alerts open on them and the latest health scan.
+type PondOverviewDto struct {
+	Pond             PondResponseDto            `json:"pond"`
+	Boards           []PondBoardDto             `json:"boards"`
+	ActiveAlerts     []AlertIncidentResponseDto `json:"active_alerts"`
+	LatestHealthScan *PondHealth                `json:"latest_health_scan"`
+	GeneratedAt      time.Time                  `json:"generated_at"`
+}
@@ -17,12 +17,17 @@ type PondHealth struct {
 	Result   *string    
 	Data     time.Time `json:"data" gorm:"autoCreateTime"`
 	User      User      `gorm:"foreignKey:UserID"`
+	// ScannedPondID is the Pond the scan was taken of. PondID, despite its
+	// name, is the scan's own ID.
+	ScannedPondID *uint `json:"scanned_pond_id" gorm:"index"`
 }
+// InsertPondHealthDto is a new scan. UserID is taken from the JWT.
 type InsertPondHealthDto struct {
-	UserID  uint    `json:"user_id"`
+	UserID  uint    `json:"-"`
 	Picture string  `json:"picture"`
 	Result  string  `json:"result"`
 	Data    time.Time `json:"data" gorm:"autoCreateTime"`
+	ScannedPondID *uint `json:"scanned_pond_id"`
 }
 
 type PondHealthResponseDto struct {
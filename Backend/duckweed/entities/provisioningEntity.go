@@ -0,0 +1,28 @@
+package entities
+
+import "time"
+
+// ProvisionBoardDto is one board in a factory import. Board IDs end up in
+// MQTT topics, so they may not contain topic separators or wildcards.
+type ProvisionBoardDto struct {
+	BoardID       string  `json:"board_id" validate:"required,max=64,printascii,excludesall=/+# "`
+	BoardName     *string `json:"board_name"`
+	HardwareModel *string `json:"hardware_model" validate:"omitempty,max=64"`
+}
+
+type ProvisionBoardsDto struct {
+	Boards []ProvisionBoardDto `json:"boards" validate:"required,min=1,max=1000,dive"`
+}
+
+// ProvisionedBoardDto carries the secrets of a newly provisioned board. It is
+// the only time the claim code and MQTT password are returned; the factory
+// prints the claim code, or QRPayload as a QR code, on the board's label and
+// flashes the MQTT credentials into the board.
+type ProvisionedBoardDto struct {
+	BoardID      string    `json:"board_id"`
+	ClaimCode    string    `json:"claim_code,omitempty"`
+	QRPayload    string    `json:"qr_payload,omitempty"`
+	MqttUsername string    `json:"mqtt_username,omitempty"`
+	MqttPassword string    `json:"mqtt_password,omitempty"`
+	IssuedAt     time.Time `json:"issued_at"`
+}
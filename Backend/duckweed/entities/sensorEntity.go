@@ -16,6 +16,9 @@ type Sensor struct {
 	SensorStatus    *string
 	SensorThreshold *float64
 	SensorFrequency *int64
+	// SensorUnit and SensorPrecision describe how readings of this type are shown.
+	SensorUnit      *string
+	SensorPrecision *int
 }
 
 type InsertSensorDto struct {
@@ -24,6 +27,8 @@ type InsertSensorDto struct {
 	SensorStatus    string  `json:"sensor_status"`
 	SensorThreshold float64 `json:"sensor_threshold"`
 	SensorFrequency int64   `json:"sensor_frequency"`
+	SensorUnit      *string `json:"sensor_unit"`
+	SensorPrecision *int    `json:"sensor_precision"`
 }
 
 type SensorResponseDto struct {
@@ -33,4 +38,6 @@ type SensorResponseDto struct {
 	SensorStatus    string  `json:"sensor_status"`
 	SensorThreshold float64 `json:"sensor_threshold"`
 	SensorFrequency int64   `json:"sensor_frequency"`
+	SensorUnit      *string `json:"sensor_unit"`
+	SensorPrecision *int    `json:"sensor_precision"`
 }
\ No newline at end of file
@@ -1,32 +1,206 @@
 package entities
 
 import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// SensorLog is one reading of a board at a point in time. The values of the
+// reading are stored as Measurement rows, one per channel.
 type SensorLog struct {
 	gorm.Model
-	Temperature *float64 `json:"temperature"`
-	Ec          *float64 `json:"ec"`
-	Ph          *float64 `json:"ph"`
-	BoardID *string `json:"board_id"`
-	Board Board `gorm:"foreignKey:BoardID"`
+	BoardID *string `json:"board_id" gorm:"uniqueIndex:idx_sensor_logs_board_dedup,priority:1"`
+	Board   Board   `gorm:"foreignKey:BoardID"`
+	// Sequence is the board's counter for the reading, when it sends one.
+	Sequence *int64 `json:"seq,omitempty"`
+	// DedupKey identifies the reading within its board, from the message ID
+	// or the device timestamp and sequence, so a redelivered message is not
+	// stored twice. Readings without either have no key and are not
+	// deduplicated.
+	DedupKey     *string       `json:"-" gorm:"type:varchar(100);uniqueIndex:idx_sensor_logs_board_dedup,priority:2"`
+	ReceivedAt   *time.Time    `json:"received_at,omitempty"`
+	Measurements []Measurement `json:"measurements" gorm:"foreignKey:SensorLogID"`
 }
 
+// InsertSensorLogDto is one reading as published by a board. Channel values go
+// in Values; the flat temperature/ec/ph fields of the original firmware are
+// still accepted and mapped onto channels of the same name.
 type InsertSensorLogDto struct {
-	BoardID     string     `json:"board_id" validate:"required"`
-	Temperature float64 `json:"temperature"`
-	Ec          float64 `json:"ec"`
-	Ph          float64 `json:"ph"`
+	BoardID     string                 `json:"board_id" validate:"required"`
+	Temperature *float64               `json:"temperature"`
+	Ec          *float64               `json:"ec"`
+	Ph          *float64               `json:"ph"`
+	Values      map[MetricEnum]float64 `json:"values"`
+	// Timestamp and Sequence are set by boards that buffer readings offline.
+	Timestamp *DeviceTime `json:"ts"`
+	Sequence  *int64      `json:"seq"`
+	// MessageID is a unique ID of the reading, for boards that assign one.
+	MessageID *string `json:"msg_id"`
+}
+
+// DedupKey returns the key a redelivery of the reading would repeat: the
+// message ID when the board sends one, otherwise the device timestamp and
+// sequence. It returns nil when the reading carries neither.
+func (dto *InsertSensorLogDto) DedupKey() *string {
+	var key string
+	switch {
+	case dto.MessageID != nil && *dto.MessageID != "":
+		key = "id:" + *dto.MessageID
+	case dto.Timestamp != nil && !dto.Timestamp.IsZero():
+		key = fmt.Sprintf("ts:%d", dto.Timestamp.UnixMilli())
+		if dto.Sequence != nil {
+			key += fmt.Sprintf(":seq:%d", *dto.Sequence)
+		}
+	default:
+		return nil
+	}
+	return &key
+}
+
+// ChannelValues merges the legacy fields and Values into one channel map.
+// Values wins when both carry the same channel.
+func (dto *InsertSensorLogDto) ChannelValues() map[MetricEnum]float64 {
+	values := make(map[MetricEnum]float64, len(dto.Values)+3)
+	if dto.Temperature != nil {
+		values[MetricTemperature] = *dto.Temperature
+	}
+	if dto.Ec != nil {
+		values[MetricEc] = *dto.Ec
+	}
+	if dto.Ph != nil {
+		values[MetricPh] = *dto.Ph
+	}
+	for channel, value := range dto.Values {
+		values[channel] = value
+	}
+	return values
+}
+
+// DeviceTime is a board-supplied timestamp. Boards send either Unix seconds,
+// Unix milliseconds or an RFC3339 string.
+type DeviceTime struct {
+	time.Time
+}
+
+func (t *DeviceTime) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+	if strings.HasPrefix(raw, `"`) {
+		parsed, err := time.Parse(`"`+time.RFC3339+`"`, raw)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+
+	epoch, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return fmt.Errorf("invalid device timestamp %s", raw)
+	}
+	if epoch > 1e12 {
+		t.Time = time.UnixMilli(int64(epoch))
+	} else {
+		t.Time = time.Unix(int64(epoch), int64((epoch-math.Floor(epoch))*1e9))
+	}
+	return nil
 }
 
 type SensorLogResponseDto struct {
-	ID          uint     `json:"id"`
-	BoardID     string      `json:"board_id"`
-	Temperature float64  `json:"temperature"`
-	Ec          float64  `json:"ec"`
-	Ph          float64  `json:"ph"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID       uint                   `json:"id"`
+	BoardID  string                 `json:"board_id"`
+	Sequence *int64                 `json:"seq,omitempty"`
+	Values   map[MetricEnum]float64 `json:"values"`
+	// RawValues holds the uncalibrated reading of calibrated channels.
+	RawValues map[MetricEnum]float64 `json:"raw_values,omitempty"`
+	// Suspect lists the channels the ingestion filter flagged, with the reason.
+	Suspect   map[MetricEnum]QualityReasonEnum `json:"suspect,omitempty"`
+	CreatedAt time.Time                        `json:"created_at"`
+}
+
+// MetricEnum names a measurement channel of a board, such as "temperature" or
+// "do". The constants are the channels of the original fixed payload.
+type MetricEnum string
+
+const (
+	MetricTemperature MetricEnum = "temperature"
+	MetricEc          MetricEnum = "ec"
+	MetricPh          MetricEnum = "ph"
+)
+
+// MetricValues returns the trusted readings on the log keyed by channel.
+// Suspect measurements are left out.
+func (l *SensorLog) MetricValues() map[MetricEnum]float64 {
+	values := make(map[MetricEnum]float64, len(l.Measurements))
+	for _, measurement := range l.Measurements {
+		if measurement.Quality == QualitySuspect {
+			continue
+		}
+		values[measurement.Channel] = measurement.Value
+	}
+	return values
+}
+
+type TelemetryIntervalEnum string
+
+const (
+	TelemetryIntervalRaw   TelemetryIntervalEnum = "raw"
+	TelemetryInterval1Min  TelemetryIntervalEnum = "1m"
+	TelemetryInterval5Min  TelemetryIntervalEnum = "5m"
+	TelemetryInterval1Hour TelemetryIntervalEnum = "1h"
+	TelemetryInterval1Day  TelemetryIntervalEnum = "1d"
+)
+
+// Duration returns the bucket width of the interval, or 0 for raw.
+func (i TelemetryIntervalEnum) Duration() time.Duration {
+	switch i {
+	case TelemetryInterval1Min:
+		return time.Minute
+	case TelemetryInterval5Min:
+		return 5 * time.Minute
+	case TelemetryInterval1Hour:
+		return time.Hour
+	case TelemetryInterval1Day:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+type TelemetryQueryDto struct {
+	From     time.Time
+	To       time.Time
+	Interval TelemetryIntervalEnum
+	// Channels limits the result to these channels when not empty.
+	Channels []MetricEnum
+	// IncludeSuspect adds readings flagged as suspect to the aggregates.
+	IncludeSuspect bool
+}
+
+type MetricAggregateDto struct {
+	Count int64    `json:"count"`
+	Min   *float64 `json:"min"`
+	Avg   *float64 `json:"avg"`
+	Max   *float64 `json:"max"`
+}
+
+type TelemetryBucketDto struct {
+	BucketStart time.Time                         `json:"bucket_start"`
+	Channels    map[MetricEnum]MetricAggregateDto `json:"channels"`
+}
+
+type TelemetryHistoryResponseDto struct {
+	BoardID  string                 `json:"board_id"`
+	From     time.Time              `json:"from"`
+	To       time.Time              `json:"to"`
+	Interval TelemetryIntervalEnum  `json:"interval"`
+	Channels []ChannelInfoDto       `json:"channels"`
+	Points   []SensorLogResponseDto `json:"points,omitempty"`
+	Buckets  []TelemetryBucketDto   `json:"buckets,omitempty"`
 }
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlertHandler struct {
+	useCase   usecases.AlertUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewAlertHandler(uc usecases.AlertUseCaseInterface) *AlertHandler {
+	return &AlertHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+func (h *AlertHandler) CreateAlertRule(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertAlertRuleDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	rule, err := h.useCase.CreateAlertRule(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not create alert rule.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Alert rule created successfully.",
+		"data":    rule,
+	})
+}
+
+func (h *AlertHandler) GetAlertRules(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	rules, err := h.useCase.GetAlertRules(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve alert rules.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Alert rules retrieved successfully.",
+		"data":    rules,
+	})
+}
+
+func (h *AlertHandler) DeleteAlertRule(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	ruleID, err := strconv.ParseUint(c.Params("rule_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid rule ID.",
+		})
+	}
+
+	if err := h.useCase.DeleteAlertRule(userID, c.Params("board_id"), uint(ruleID)); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not delete alert rule.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Alert rule deleted successfully.",
+		"data":    nil,
+	})
+}
+
+// GetAlertIncidents lists the incidents of a board, optionally filtered with
+// ?state=open or ?state=closed.
+func (h *AlertHandler) GetAlertIncidents(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	state := entities.AlertIncidentStateEnum(c.Query("state"))
+	switch state {
+	case "", entities.AlertIncidentOpen, entities.AlertIncidentClosed:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "State must be 'open' or 'closed'.",
+		})
+	}
+
+	incidents, err := h.useCase.GetAlertIncidents(userID, c.Params("board_id"), state)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve alerts.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Alerts retrieved successfully.",
+		"data":    incidents,
+	})
+}
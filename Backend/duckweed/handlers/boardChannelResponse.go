@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BoardChannelHandler struct {
+	useCase   usecases.BoardChannelUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewBoardChannelHandler(uc usecases.BoardChannelUseCaseInterface) *BoardChannelHandler {
+	return &BoardChannelHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+func (h *BoardChannelHandler) GetChannels(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	channels, err := h.useCase.GetChannels(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve board channels.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board channels retrieved successfully.",
+		"data":    channels,
+	})
+}
+
+// SetChannel maps a channel of the board onto a Sensor definition, replacing
+// any previous mapping.
+func (h *BoardChannelHandler) SetChannel(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertBoardChannelDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	channel, err := h.useCase.SetChannel(userID, c.Params("board_id"), entities.MetricEnum(c.Params("channel")), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not update board channel.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board channel updated successfully.",
+		"data":    channel,
+	})
+}
+
+func (h *BoardChannelHandler) DeleteChannel(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.useCase.DeleteChannel(userID, c.Params("board_id"), entities.MetricEnum(c.Params("channel"))); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not delete board channel.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board channel deleted successfully.",
+		"data":    nil,
+	})
+}
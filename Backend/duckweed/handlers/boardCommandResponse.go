@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BoardCommandHandler struct {
+	useCase   usecases.BoardCommandUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewBoardCommandHandler(uc usecases.BoardCommandUseCaseInterface) *BoardCommandHandler {
+	return &BoardCommandHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+// SendCommand publishes a command to the board and answers 202 with the
+// pending command; the app polls GetCommand until the board acks it.
+func (h *BoardCommandHandler) SendCommand(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertBoardCommandDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	command, err := h.useCase.SendCommand(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not send command.",
+			"data":    err.Error(),
+		})
+	}
+
+	if command.State == entities.CommandStateFailed {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Command could not be published to the board.",
+			"data":    command,
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Command sent to board.",
+		"data":    command,
+	})
+}
+
+func (h *BoardCommandHandler) GetCommands(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	commands, err := h.useCase.GetCommands(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve commands.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Commands retrieved successfully.",
+		"data":    commands,
+	})
+}
+
+func (h *BoardCommandHandler) GetCommand(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	command, err := h.useCase.GetCommand(userID, c.Params("board_id"), c.Params("correlation_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve command.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Command retrieved successfully.",
+		"data":    command,
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"main/duckweed/entities"
 	"main/duckweed/usecases"
+	"main/duckweed/utils"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -20,7 +21,18 @@ func NewBoardRelationshipHandler(uc usecases.BoardRelationshipUseCaseInterface)
 	}
 }
 
+// CreateBoardRelationship claims a board for the calling user with the claim
+// code that came with it.
 func (h *BoardRelationshipHandler) CreateBoardRelationship(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
 	dto := new(entities.InsertBoardRelationshipDto)
 
 	if err := c.BodyParser(dto); err != nil {
@@ -39,9 +51,11 @@ func (h *BoardRelationshipHandler) CreateBoardRelationship(c *fiber.Ctx) error {
 		})
 	}
 
+	dto.UserID = userID
+
 	response, err := h.useCase.CreateBoardRelationship(*dto)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"status":  "error",
 			"message": "Could not create board relationship.",
 			"data":    err.Error(),
@@ -54,3 +68,61 @@ func (h *BoardRelationshipHandler) CreateBoardRelationship(c *fiber.Ctx) error {
 		"data":    response,
 	})
 }
+
+// GetUserBoards lists the boards of the calling user.
+func (h *BoardRelationshipHandler) GetUserBoards(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	boards, err := h.useCase.GetUserBoards(userID)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve boards.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Boards retrieved successfully.",
+		"data":    boards,
+	})
+}
+
+// DeleteBoardRelationship disconnects the calling user from the board.
+func (h *BoardRelationshipHandler) DeleteBoardRelationship(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	response, err := h.useCase.DeleteBoardRelationship(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not delete board relationship.",
+			"data":    err.Error(),
+		})
+	}
+
+	message := "Board relationship deleted successfully."
+	if response.Unclaimed {
+		message = "Board unclaimed successfully. Store the new claim code now, it is not shown again."
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    response,
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"main/duckweed/entities"
 	"main/duckweed/usecases"
+	"main/duckweed/utils"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -71,7 +72,17 @@ func (h *BoardHandler) GetAllBoards(c *fiber.Ctx) error {
 	})
 }
 
+// GetBoardByBoardID returns a board the calling user has access to.
 func (h *BoardHandler) GetBoardByBoardID(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
 	boardID := c.Params("board_id")
 	if boardID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
@@ -80,25 +91,137 @@ func (h *BoardHandler) GetBoardByBoardID(c *fiber.Ctx) error {
 		})
 	}
 
-	board, err := h.useCase.GetBoardByBoardID(boardID)
+	board, err := h.useCase.GetBoardByBoardID(userID, boardID)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
 			"status":  "error",
 			"message": "An error occurred while retrieving the board.",
 			"data":    err.Error(),
 		})
 	}
 
-	if board == nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board retrieved successfully.",
+		"data":    board,
+	})
+}
+
+// UpdateBoard changes the name, location or notes of a board of the calling
+// user.
+func (h *BoardHandler) UpdateBoard(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status":  "error",
-			"message": "Board not found.",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.UpdateBoardDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	board, err := h.useCase.UpdateBoard(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not update board.",
+			"data":    err.Error(),
 		})
 	}
 
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"status":  "success",
-		"message": "Board retrieved successfully.",
+		"message": "Board updated successfully.",
+		"data":    board,
+	})
+}
+
+// DisableBoard takes a board out of service until its owner enables it
+// again. The reason in the body is optional.
+func (h *BoardHandler) DisableBoard(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.DisableBoardDto)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body.",
+				"data":    err.Error(),
+			})
+		}
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	board, err := h.useCase.DisableBoard(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not disable board.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board disabled successfully.",
+		"data":    board,
+	})
+}
+
+// EnableBoard puts a disabled board back in service.
+func (h *BoardHandler) EnableBoard(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	board, err := h.useCase.EnableBoard(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not enable board.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board enabled successfully.",
 		"data":    board,
 	})
 }
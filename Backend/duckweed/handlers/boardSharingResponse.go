@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BoardSharingHandler struct {
+	useCase   usecases.BoardSharingUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewBoardSharingHandler(uc usecases.BoardSharingUseCaseInterface) *BoardSharingHandler {
+	return &BoardSharingHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+// InviteUser invites a user, by email or username, to the board as an
+// operator or a viewer. Only the owner can invite.
+func (h *BoardSharingHandler) InviteUser(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertBoardInvitationDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitation, err := h.useCase.InviteUser(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not invite user.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation sent successfully.",
+		"data":    invitation,
+	})
+}
+
+func (h *BoardSharingHandler) GetBoardInvitations(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitations, err := h.useCase.GetBoardInvitations(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve invitations.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitations retrieved successfully.",
+		"data":    invitations,
+	})
+}
+
+func (h *BoardSharingHandler) RevokeInvitation(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitationID, err := strconv.ParseUint(c.Params("invitation_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid invitation ID.",
+		})
+	}
+
+	if err := h.useCase.RevokeInvitation(userID, c.Params("board_id"), uint(invitationID)); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not revoke invitation.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation revoked successfully.",
+		"data":    nil,
+	})
+}
+
+// GetMyInvitations lists the pending invitations of the calling user.
+func (h *BoardSharingHandler) GetMyInvitations(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitations, err := h.useCase.GetMyInvitations(userID)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve invitations.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitations retrieved successfully.",
+		"data":    invitations,
+	})
+}
+
+func (h *BoardSharingHandler) AcceptInvitation(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitationID, err := strconv.ParseUint(c.Params("invitation_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid invitation ID.",
+		})
+	}
+
+	relationship, err := h.useCase.AcceptInvitation(userID, uint(invitationID))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not accept invitation.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation accepted successfully.",
+		"data":    relationship,
+	})
+}
+
+func (h *BoardSharingHandler) DeclineInvitation(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	invitationID, err := strconv.ParseUint(c.Params("invitation_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid invitation ID.",
+		})
+	}
+
+	if err := h.useCase.DeclineInvitation(userID, uint(invitationID)); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not decline invitation.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Invitation declined successfully.",
+		"data":    nil,
+	})
+}
+
+func (h *BoardSharingHandler) GetBoardMembers(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	members, err := h.useCase.GetBoardMembers(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve board members.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board members retrieved successfully.",
+		"data":    members,
+	})
+}
+
+func (h *BoardSharingHandler) UpdateMemberRole(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	memberUserID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID.",
+		})
+	}
+
+	dto := new(entities.UpdateBoardMemberDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	member, err := h.useCase.UpdateMemberRole(userID, c.Params("board_id"), uint(memberUserID), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not update member role.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member role updated successfully.",
+		"data":    member,
+	})
+}
+
+// DisableMember suspends one user's access to the board without
+// removing them or affecting the other users.
+func (h *BoardSharingHandler) DisableMember(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	memberUserID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID.",
+		})
+	}
+
+	member, err := h.useCase.DisableMember(userID, c.Params("board_id"), uint(memberUserID))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not disable member.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member disabled successfully.",
+		"data":    member,
+	})
+}
+
+// EnableMember gives a disabled user their access to the board back.
+func (h *BoardSharingHandler) EnableMember(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	memberUserID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID.",
+		})
+	}
+
+	member, err := h.useCase.EnableMember(userID, c.Params("board_id"), uint(memberUserID))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not enable member.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member enabled successfully.",
+		"data":    member,
+	})
+}
+
+// RemoveMember revokes another user's access to the board.
+func (h *BoardSharingHandler) RemoveMember(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	memberUserID, err := strconv.ParseUint(c.Params("user_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid user ID.",
+		})
+	}
+
+	if err := h.useCase.RemoveMember(userID, c.Params("board_id"), uint(memberUserID)); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not remove member.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Member removed successfully.",
+		"data":    nil,
+	})
+}
+
+// TransferOwnership makes another member the owner of the board. The calling
+// user stays on as an operator.
+func (h *BoardSharingHandler) TransferOwnership(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.TransferBoardOwnershipDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.useCase.TransferOwnership(userID, c.Params("board_id"), *dto); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not transfer ownership.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Ownership transferred successfully.",
+		"data":    nil,
+	})
+}
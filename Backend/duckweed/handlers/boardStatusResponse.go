@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultStatusHistoryRange = 7 * 24 * time.Hour
+
+type BoardStatusHandler struct {
+	useCase usecases.BoardStatusUseCaseInterface
+}
+
+func NewBoardStatusHandler(uc usecases.BoardStatusUseCaseInterface) *BoardStatusHandler {
+	return &BoardStatusHandler{useCase: uc}
+}
+
+// GetStatusHistory serves GET /v1/boards/:board_id/status-history?from=&to=&interval=
+// where from/to are RFC3339 timestamps, defaulting to the last seven days,
+// and interval is day or week.
+func (h *BoardStatusHandler) GetStatusHistory(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	to := time.Now()
+	if raw := c.Query("to"); raw != "" {
+		if to, err = time.Parse(time.RFC3339, raw); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid 'to' parameter. Use RFC3339 format.",
+				"data":    err.Error(),
+			})
+		}
+	}
+	from := to.Add(-defaultStatusHistoryRange)
+	if raw := c.Query("from"); raw != "" {
+		if from, err = time.Parse(time.RFC3339, raw); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid 'from' parameter. Use RFC3339 format.",
+				"data":    err.Error(),
+			})
+		}
+	}
+	interval := entities.UptimeIntervalEnum(c.Query("interval", string(entities.UptimeIntervalDay)))
+
+	history, err := h.useCase.GetStatusHistory(userID, c.Params("board_id"), from, to, interval)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve status history.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Status history retrieved successfully.",
+		"data":    history,
+	})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type CalibrationHandler struct {
+	useCase   usecases.CalibrationUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewCalibrationHandler(uc usecases.CalibrationUseCaseInterface) *CalibrationHandler {
+	return &CalibrationHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+// CreateSession records the calibrations done during one session on a board.
+func (h *CalibrationHandler) CreateSession(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertCalibrationSessionDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	calibrations, err := h.useCase.CreateSession(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not record calibration session.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calibration session recorded successfully.",
+		"data":    calibrations,
+	})
+}
+
+// GetCalibrations lists the calibrations of a board, optionally filtered with
+// ?channel=.
+func (h *CalibrationHandler) GetCalibrations(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	calibrations, err := h.useCase.GetCalibrations(userID, c.Params("board_id"), entities.MetricEnum(c.Query("channel")))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve calibrations.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calibrations retrieved successfully.",
+		"data":    calibrations,
+	})
+}
+
+// Recompute recalibrates stored measurements after a calibration was entered
+// after the fact. The body may narrow it to a channel and a time range.
+func (h *CalibrationHandler) Recompute(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.RecomputeCalibrationDto)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(dto); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body.",
+				"data":    err.Error(),
+			})
+		}
+	}
+
+	result, err := h.useCase.Recompute(userID, c.Params("board_id"), *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not recompute calibrated values.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Calibrated values recomputed successfully.",
+		"data":    result,
+	})
+}
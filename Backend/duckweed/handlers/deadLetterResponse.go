@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeadLetterHandler struct {
+	useCase usecases.DeadLetterUseCaseInterface
+}
+
+func NewDeadLetterHandler(uc usecases.DeadLetterUseCaseInterface) *DeadLetterHandler {
+	return &DeadLetterHandler{useCase: uc}
+}
+
+// GetDeadLetters lists dead letters filtered by ?board_id=&reason=&before=&limit=.
+// Replayed messages are hidden unless ?include_replayed=true.
+func (h *DeadLetterHandler) GetDeadLetters(c *fiber.Ctx) error {
+	filter, err := parseDeadLetterFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid filter parameters.",
+			"data":    err.Error(),
+		})
+	}
+
+	messages, err := h.useCase.GetDeadLetters(filter)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve dead letters.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letters retrieved successfully.",
+		"data":    messages,
+	})
+}
+
+func (h *DeadLetterHandler) GetDeadLetter(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid dead letter ID.",
+		})
+	}
+
+	message, err := h.useCase.GetDeadLetter(uint(id))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve dead letter.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letter retrieved successfully.",
+		"data":    message,
+	})
+}
+
+// ReplayDeadLetter replays one dead letter. A message that was replayed
+// already is only replayed again with ?force=true.
+func (h *DeadLetterHandler) ReplayDeadLetter(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid dead letter ID.",
+		})
+	}
+
+	result, err := h.useCase.ReplayDeadLetter(uint(id), c.QueryBool("force", false))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not replay dead letter.",
+			"data":    err.Error(),
+		})
+	}
+
+	if !result.Replayed {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Dead letter was rejected again.",
+			"data":    result,
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letter replayed successfully.",
+		"data":    result,
+	})
+}
+
+// ReplayBoardDeadLetters replays all pending dead letters of ?board_id=.
+func (h *DeadLetterHandler) ReplayBoardDeadLetters(c *fiber.Ctx) error {
+	boardID := c.Query("board_id")
+	if boardID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Board ID parameter is required.",
+		})
+	}
+
+	results, err := h.useCase.ReplayBoardDeadLetters(boardID)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not replay dead letters.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letters replayed.",
+		"data":    results,
+	})
+}
+
+func (h *DeadLetterHandler) DeleteDeadLetter(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid dead letter ID.",
+		})
+	}
+
+	if err := h.useCase.DeleteDeadLetter(uint(id)); err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not delete dead letter.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letter deleted successfully.",
+		"data":    nil,
+	})
+}
+
+// PurgeDeadLetters deletes every dead letter matching ?board_id=&reason=&before=.
+func (h *DeadLetterHandler) PurgeDeadLetters(c *fiber.Ctx) error {
+	filter, err := parseDeadLetterFilter(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid filter parameters.",
+			"data":    err.Error(),
+		})
+	}
+
+	purged, err := h.useCase.PurgeDeadLetters(filter)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not purge dead letters.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Dead letters purged successfully.",
+		"data":    fiber.Map{"purged": purged},
+	})
+}
+
+func parseDeadLetterFilter(c *fiber.Ctx) (entities.DeadLetterFilterDto, error) {
+	filter := entities.DeadLetterFilterDto{
+		BoardID:         c.Query("board_id"),
+		ReasonCode:      entities.DeadLetterReasonEnum(c.Query("reason")),
+		IncludeReplayed: c.QueryBool("include_replayed", false),
+		Limit:           c.QueryInt("limit", 0),
+	}
+	if before := c.Query("before"); before != "" {
+		parsed, err := time.Parse(time.RFC3339, before)
+		if err != nil {
+			return filter, err
+		}
+		filter.Before = &parsed
+	}
+	return filter, nil
+}
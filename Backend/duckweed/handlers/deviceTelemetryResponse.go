@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceTelemetryHandler struct {
+	useCase usecases.DeviceTelemetryUseCaseInterface
+}
+
+func NewDeviceTelemetryHandler(uc usecases.DeviceTelemetryUseCaseInterface) *DeviceTelemetryHandler {
+	return &DeviceTelemetryHandler{useCase: uc}
+}
+
+// IssueDeviceToken creates the token a board uses on the HTTP ingest route.
+// Any previous token stops working.
+func (h *DeviceTelemetryHandler) IssueDeviceToken(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	token, err := h.useCase.IssueDeviceToken(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not issue device token.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Device token issued successfully. Store it now, it is not shown again.",
+		"data":    token,
+	})
+}
+
+// IngestTelemetry serves POST /ingest/boards/:board_id/telemetry for devices
+// that cannot use MQTT. The body is the same JSON a board publishes on its
+// telemetry topic and the device token goes in "Authorization: Bearer <token>"
+// or "X-Device-Token". It replies once the readings are stored; on 503 the
+// device should retry later.
+func (h *DeviceTelemetryHandler) IngestTelemetry(c *fiber.Ctx) error {
+	token := c.Get("X-Device-Token")
+	if auth := c.Get(fiber.HeaderAuthorization); token == "" && strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+
+	result, err := h.useCase.IngestTelemetry(c.Params("board_id"), token, c.Body())
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Telemetry was rejected.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Telemetry accepted.",
+		"data":    result,
+	})
+}
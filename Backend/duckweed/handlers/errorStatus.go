@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"main/duckweed/usecases"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// errorStatus maps the use case sentinel errors onto HTTP status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecases.ErrBoardNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, usecases.ErrBoardAccessDenied),
+		errors.Is(err, usecases.ErrBoardRoleForbidden),
+		errors.Is(err, usecases.ErrBoardAccessDisabled):
+		return fiber.StatusForbidden
+	case errors.Is(err, usecases.ErrInvalidDeviceToken):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, usecases.ErrInvalidDownloadLink), errors.Is(err, usecases.ErrInvalidClaimCode):
+		return fiber.StatusForbidden
+	case errors.Is(err, usecases.ErrBoardUnclaimed),
+		errors.Is(err, usecases.ErrBoardDisabled),
+		errors.Is(err, usecases.ErrBoardExists),
+		errors.Is(err, usecases.ErrBoardAlreadyClaimed),
+		errors.Is(err, usecases.ErrFirmwareExists),
+		errors.Is(err, usecases.ErrRolloutConflict),
+		errors.Is(err, usecases.ErrRolloutState),
+		errors.Is(err, usecases.ErrInvitationState),
+		errors.Is(err, usecases.ErrInvitationExists),
+		errors.Is(err, usecases.ErrAlreadyBoardMember),
+		errors.Is(err, usecases.ErrOwnerMustTransfer),
+		errors.Is(err, usecases.ErrBoardMemberDisabled),
+		errors.Is(err, usecases.ErrDeadLetterReplayed):
+		return fiber.StatusConflict
+	case errors.Is(err, usecases.ErrAlertRuleNotFound),
+		errors.Is(err, usecases.ErrCommandNotFound),
+		errors.Is(err, usecases.ErrDeadLetterNotFound),
+		errors.Is(err, usecases.ErrChannelNotFound),
+		errors.Is(err, usecases.ErrSensorNotFound),
+		errors.Is(err, usecases.ErrNoCalibrations),
+		errors.Is(err, usecases.ErrFirmwareNotFound),
+		errors.Is(err, usecases.ErrRolloutNotFound),
+		errors.Is(err, usecases.ErrFirmwareUpdateNotFound),
+		errors.Is(err, usecases.ErrInviteeNotFound),
+		errors.Is(err, usecases.ErrInvitationNotFound),
+		errors.Is(err, usecases.ErrBoardMemberNotFound),
+		errors.Is(err, usecases.ErrPondNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, usecases.ErrCommandChannelUnavailable),
+		errors.Is(err, usecases.ErrIngestionUnavailable),
+		errors.Is(err, usecases.ErrIngestionBusy),
+		errors.Is(err, usecases.ErrMqttAuthUnavailable):
+		return fiber.StatusServiceUnavailable
+	case errors.Is(err, usecases.ErrInvalidTimeRange),
+		errors.Is(err, usecases.ErrInvalidInterval),
+		errors.Is(err, usecases.ErrInvalidAlertRule),
+		errors.Is(err, usecases.ErrPurgeFilterRequired),
+		errors.Is(err, usecases.ErrInvalidChannel),
+		errors.Is(err, usecases.ErrInvalidCalibration),
+		errors.Is(err, usecases.ErrInvalidTelemetry),
+		errors.Is(err, usecases.ErrInvalidFirmware),
+		errors.Is(err, usecases.ErrFirmwareChecksum),
+		errors.Is(err, usecases.ErrInvalidRollout),
+		errors.Is(err, usecases.ErrInvalidProvisioning),
+		errors.Is(err, usecases.ErrInvalidInvitation),
+		errors.Is(err, usecases.ErrInvalidBoardRole),
+		errors.Is(err, usecases.ErrInvalidPond),
+		errors.Is(err, usecases.ErrTooManyTransitions):
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type FirmwareHandler struct {
+	useCase   usecases.FirmwareUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewFirmwareHandler(uc usecases.FirmwareUseCaseInterface) *FirmwareHandler {
+	return &FirmwareHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+// UploadRelease takes a multipart form with the image in "file" and the
+// hardware_model, version, optional checksum and notes fields.
+func (h *FirmwareHandler) UploadRelease(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertFirmwareReleaseDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Firmware image is missing from the \"file\" field.",
+			"data":    err.Error(),
+		})
+	}
+	image, err := header.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not read firmware image.",
+			"data":    err.Error(),
+		})
+	}
+	defer image.Close()
+
+	release, err := h.useCase.UploadRelease(userID, *dto, image)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not upload firmware.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Firmware uploaded successfully.",
+		"data":    release,
+	})
+}
+
+func (h *FirmwareHandler) GetReleases(c *fiber.Ctx) error {
+	releases, err := h.useCase.GetReleases()
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve firmware releases.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Firmware releases retrieved successfully.",
+		"data":    releases,
+	})
+}
+
+func (h *FirmwareHandler) GetRelease(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("release_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid release ID.",
+		})
+	}
+
+	release, err := h.useCase.GetRelease(uint(id))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve firmware release.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Firmware release retrieved successfully.",
+		"data":    release,
+	})
+}
+
+// CreateRollout starts a rollout and notifies the boards of its first stage.
+func (h *FirmwareHandler) CreateRollout(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	dto := new(entities.InsertFirmwareRolloutDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	rollout, err := h.useCase.CreateRollout(userID, *dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not start rollout.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Rollout started.",
+		"data":    rollout,
+	})
+}
+
+func (h *FirmwareHandler) GetRollouts(c *fiber.Ctx) error {
+	rollouts, err := h.useCase.GetRollouts()
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve rollouts.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Rollouts retrieved successfully.",
+		"data":    rollouts,
+	})
+}
+
+func (h *FirmwareHandler) GetRollout(c *fiber.Ctx) error {
+	return h.rolloutAction(c, "Rollout retrieved successfully.", h.useCase.GetRollout)
+}
+
+// GetRolloutUpdates lists the OTA progress of every board in the rollout.
+func (h *FirmwareHandler) GetRolloutUpdates(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("rollout_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid rollout ID.",
+		})
+	}
+
+	updates, err := h.useCase.GetRolloutUpdates(uint(id))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve rollout updates.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Rollout updates retrieved successfully.",
+		"data":    updates,
+	})
+}
+
+func (h *FirmwareHandler) AdvanceRollout(c *fiber.Ctx) error {
+	return h.rolloutAction(c, "Rollout advanced to its next stage.", h.useCase.AdvanceRollout)
+}
+
+// HaltRollout takes an optional {"reason": "..."} body.
+func (h *FirmwareHandler) HaltRollout(c *fiber.Ctx) error {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body.",
+				"data":    err.Error(),
+			})
+		}
+	}
+
+	return h.rolloutAction(c, "Rollout halted.", func(id uint) (*entities.FirmwareRolloutResponseDto, error) {
+		return h.useCase.HaltRollout(id, body.Reason)
+	})
+}
+
+func (h *FirmwareHandler) ResumeRollout(c *fiber.Ctx) error {
+	return h.rolloutAction(c, "Rollout resumed.", h.useCase.ResumeRollout)
+}
+
+func (h *FirmwareHandler) CancelRollout(c *fiber.Ctx) error {
+	return h.rolloutAction(c, "Rollout cancelled.", h.useCase.CancelRollout)
+}
+
+func (h *FirmwareHandler) rolloutAction(c *fiber.Ctx, message string, action func(id uint) (*entities.FirmwareRolloutResponseDto, error)) error {
+	id, err := strconv.ParseUint(c.Params("rollout_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid rollout ID.",
+		})
+	}
+
+	rollout, err := action(uint(id))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not update rollout.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": message,
+		"data":    rollout,
+	})
+}
+
+// GetBoardFirmware returns the version a board runs and its recent updates.
+func (h *FirmwareHandler) GetBoardFirmware(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	firmware, err := h.useCase.GetBoardFirmware(userID, c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve board firmware.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Board firmware retrieved successfully.",
+		"data":    firmware,
+	})
+}
+
+// DownloadFirmware serves GET /ota/firmware/:release_id to boards. It needs no
+// JWT; the ?board=&expires=&signature= query sent in the ota command is the
+// authorization.
+func (h *FirmwareHandler) DownloadFirmware(c *fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("release_id"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid release ID.",
+		})
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid download link.",
+			"data":    usecases.ErrInvalidDownloadLink.Error(),
+		})
+	}
+
+	release, err := h.useCase.OpenDownload(uint(id), c.Query("board"), expires, c.Query("signature"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid download link.",
+			"data":    err.Error(),
+		})
+	}
+
+	c.Set("X-Firmware-Version", release.Version)
+	c.Set("X-Firmware-SHA256", release.Checksum)
+	return c.Download(release.FilePath, fmt.Sprintf("%s-%s.bin", release.HardwareModel, release.Version))
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"log"
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MqttAuthHandler serves the broker's HTTP auth hook. The responses follow
+// what the brokers expect rather than the usual status/message/data shape:
+// the mosquitto-go-auth routes answer with the status code, and the EMQX
+// routes answer 200 with {"result": "allow"} or {"result": "deny"}.
+type MqttAuthHandler struct {
+	useCase usecases.MqttAuthUseCaseInterface
+}
+
+func NewMqttAuthHandler(uc usecases.MqttAuthUseCaseInterface) *MqttAuthHandler {
+	return &MqttAuthHandler{useCase: uc}
+}
+
+// mosquitto-go-auth access levels.
+const (
+	mosquittoAccRead      = 1
+	mosquittoAccWrite     = 2
+	mosquittoAccReadWrite = 3
+	mosquittoAccSubscribe = 4
+)
+
+// MosquittoUser is the getuser_uri of mosquitto-go-auth's HTTP backend.
+func (h *MqttAuthHandler) MosquittoUser(c *fiber.Ctx) error {
+	dto := new(entities.MqttAuthRequestDto)
+	if err := c.BodyParser(dto); err != nil {
+		return mosquittoResult(c, false, err)
+	}
+
+	ok, err := h.useCase.Authenticate(dto.Username, dto.Password, dto.ClientID)
+	return mosquittoResult(c, ok, err)
+}
+
+// MosquittoSuperuser is the superuser_uri. Nobody is a superuser; the backend
+// gets its rights through the ACL check instead.
+func (h *MqttAuthHandler) MosquittoSuperuser(c *fiber.Ctx) error {
+	return mosquittoResult(c, false, nil)
+}
+
+// MosquittoAcl is the aclcheck_uri.
+func (h *MqttAuthHandler) MosquittoAcl(c *fiber.Ctx) error {
+	dto := new(entities.MqttAuthRequestDto)
+	if err := c.BodyParser(dto); err != nil {
+		return mosquittoResult(c, false, err)
+	}
+
+	var accesses []usecases.MqttAccessEnum
+	switch dto.Acc {
+	case mosquittoAccRead:
+		accesses = []usecases.MqttAccessEnum{usecases.MqttAccessRead}
+	case mosquittoAccWrite:
+		accesses = []usecases.MqttAccessEnum{usecases.MqttAccessPublish}
+	case mosquittoAccReadWrite:
+		accesses = []usecases.MqttAccessEnum{usecases.MqttAccessRead, usecases.MqttAccessPublish}
+	case mosquittoAccSubscribe:
+		accesses = []usecases.MqttAccessEnum{usecases.MqttAccessSubscribe}
+	default:
+		return mosquittoResult(c, false, nil)
+	}
+
+	for _, access := range accesses {
+		ok, err := h.useCase.Authorize(dto.Username, dto.ClientID, dto.Topic, access)
+		if !ok || err != nil {
+			return mosquittoResult(c, false, err)
+		}
+	}
+	return mosquittoResult(c, true, nil)
+}
+
+// EmqxAuthn is the URL of EMQX's HTTP authenticator. Its body should be
+// {"username": "${username}", "password": "${password}", "clientid": "${clientid}"}.
+func (h *MqttAuthHandler) EmqxAuthn(c *fiber.Ctx) error {
+	dto := new(entities.MqttAuthRequestDto)
+	if err := c.BodyParser(dto); err != nil {
+		return emqxResult(c, false, nil)
+	}
+
+	ok, err := h.useCase.Authenticate(dto.Username, dto.Password, dto.ClientID)
+	return emqxResult(c, ok, err)
+}
+
+// EmqxAuthz is the URL of EMQX's HTTP authorizer. Its body should be
+// {"username": "${username}", "clientid": "${clientid}", "topic": "${topic}", "action": "${action}"}.
+// Messages delivered to a subscriber are not checked again by EMQX, so only
+// publish and subscribe are expected.
+func (h *MqttAuthHandler) EmqxAuthz(c *fiber.Ctx) error {
+	dto := new(entities.MqttAuthRequestDto)
+	if err := c.BodyParser(dto); err != nil {
+		return emqxResult(c, false, nil)
+	}
+
+	var access usecases.MqttAccessEnum
+	switch dto.Action {
+	case "publish":
+		access = usecases.MqttAccessPublish
+	case "subscribe":
+		access = usecases.MqttAccessSubscribe
+	default:
+		return emqxResult(c, false, nil)
+	}
+
+	ok, err := h.useCase.Authorize(dto.Username, dto.ClientID, dto.Topic, access)
+	return emqxResult(c, ok, err)
+}
+
+// mosquittoResult answers 200 to allow and 403 to deny. mosquitto-go-auth
+// reads the status code, or the ok field when its response mode is json.
+func mosquittoResult(c *fiber.Ctx, ok bool, err error) error {
+	if err != nil {
+		log.Printf("MQTT auth hook failed: %v", err)
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"ok": false, "error": err.Error()})
+	}
+	if !ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"ok": false, "error": "denied"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"ok": true, "error": ""})
+}
+
+// emqxResult answers 200 with the result in the body. A failure is answered
+// with a 5xx status, which EMQX treats as "ignore" and passes on to the next
+// authenticator or authorizer in its chain.
+func emqxResult(c *fiber.Ctx, ok bool, err error) error {
+	if err != nil {
+		log.Printf("MQTT auth hook failed: %v", err)
+		status := errorStatus(err)
+		if status < fiber.StatusInternalServerError {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{"result": "ignore"})
+	}
+	if !ok {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"result": "deny"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"result": "allow", "is_superuser": false})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"main/duckweed/entities"
 	"main/duckweed/usecases"
+	"main/duckweed/utils"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -51,14 +52,20 @@ func (h *PondHealthHandler) GetPondHealthByUserID(c *fiber.Ctx) error {
 }
 
 func (h *PondHealthHandler) PostPondHealth(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	dto := new(entities.InsertPondHealthDto)
 	if err := c.BodyParser(dto); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	dto.UserID = userID
 
 	pond, err := h.UseCase.PostPondHealth(dto)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return c.Status(errorStatus(err)).JSON(fiber.Map{"error": err.Error()})
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(pond)
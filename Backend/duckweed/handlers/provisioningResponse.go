@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ProvisioningHandler struct {
+	useCase   usecases.ProvisioningUseCaseInterface
+	validator *validator.Validate
+}
+
+func NewProvisioningHandler(uc usecases.ProvisioningUseCaseInterface) *ProvisioningHandler {
+	return &ProvisioningHandler{
+		useCase:   uc,
+		validator: validator.New(),
+	}
+}
+
+// ProvisionBoards imports factory boards and returns their claim codes and
+// MQTT credentials.
+func (h *ProvisioningHandler) ProvisionBoards(c *fiber.Ctx) error {
+	dto := new(entities.ProvisionBoardsDto)
+	if err := c.BodyParser(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body.",
+			"data":    err.Error(),
+		})
+	}
+
+	if err := h.validator.Struct(dto); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Validation failed.",
+			"data":    err.Error(),
+		})
+	}
+
+	boards, err := h.useCase.ProvisionBoards(*dto)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not provision boards.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Boards provisioned successfully. Store the claim codes and MQTT passwords now, they are not shown again.",
+		"data":    boards,
+	})
+}
+
+func (h *ProvisioningHandler) IssueClaimCode(c *fiber.Ctx) error {
+	claim, err := h.useCase.IssueClaimCode(c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not issue claim code.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Claim code issued successfully. Store it now, it is not shown again.",
+		"data":    claim,
+	})
+}
+
+func (h *ProvisioningHandler) RotateMqttCredentials(c *fiber.Ctx) error {
+	credentials, err := h.useCase.RotateMqttCredentials(c.Params("board_id"))
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not rotate MQTT credentials.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"status":  "success",
+		"message": "MQTT credentials rotated successfully. Store the password now, it is not shown again.",
+		"data":    credentials,
+	})
+}
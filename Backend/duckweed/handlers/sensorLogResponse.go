@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultTelemetryRange = 24 * time.Hour
+
+type SensorLogHandler struct {
+	useCase usecases.SensorLogUseCaseInterface
+}
+
+func NewSensorLogHandler(uc usecases.SensorLogUseCaseInterface) *SensorLogHandler {
+	return &SensorLogHandler{useCase: uc}
+}
+
+// GetTelemetryHistory serves GET /v1/boards/:board_id/telemetry?from=&to=&interval=&channels=&include_suspect=
+// where from/to are RFC3339 timestamps, interval is raw, 1m, 5m, 1h or 1d and
+// channels is an optional comma-separated list of channel names. Readings
+// flagged as suspect are left out of aggregates unless include_suspect=true.
+func (h *SensorLogHandler) GetTelemetryHistory(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	boardID := c.Params("board_id")
+	if boardID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Board ID parameter is required.",
+		})
+	}
+
+	query := entities.TelemetryQueryDto{
+		To:             time.Now(),
+		Interval:       entities.TelemetryIntervalEnum(c.Query("interval", string(entities.TelemetryInterval5Min))),
+		IncludeSuspect: c.QueryBool("include_suspect"),
+	}
+	if to := c.Query("to"); to != "" {
+		if query.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid 'to' parameter. Use RFC3339 format.",
+				"data":    err.Error(),
+			})
+		}
+	}
+	query.From = query.To.Add(-defaultTelemetryRange)
+	if from := c.Query("from"); from != "" {
+		if query.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid 'from' parameter. Use RFC3339 format.",
+				"data":    err.Error(),
+			})
+		}
+	}
+
+	if channels := c.Query("channels"); channels != "" {
+		for _, channel := range strings.Split(channels, ",") {
+			if channel = strings.TrimSpace(channel); channel != "" {
+				query.Channels = append(query.Channels, entities.MetricEnum(channel))
+			}
+		}
+	}
+
+	history, err := h.useCase.GetTelemetryHistory(userID, boardID, query)
+	if err != nil {
+		return c.Status(errorStatus(err)).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not retrieve telemetry history.",
+			"data":    err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status":  "success",
+		"message": "Telemetry history retrieved successfully.",
+		"data":    history,
+	})
+}
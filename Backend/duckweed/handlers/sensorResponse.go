@@ -19,6 +19,8 @@ func CreateSensor(db *gorm.DB) fiber.Handler {
 			SensorStatus:    &dto.SensorStatus,
 			SensorThreshold: &dto.SensorThreshold,
 			SensorFrequency: &dto.SensorFrequency,
+			SensorUnit:      dto.SensorUnit,
+			SensorPrecision: dto.SensorPrecision,
 		}
 
 		if err := db.Create(&sensor).Error; err != nil {
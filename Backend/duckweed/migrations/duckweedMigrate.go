@@ -5,6 +5,8 @@ import (
     "main/config"
     "main/database"
     "main/duckweed/entities"
+
+    "gorm.io/gorm"
 )
 
 func main() {
@@ -30,6 +32,13 @@ func migrate(db database.Database) {
     }
     log.Println("Migrated User")
 
+    err = gormDB.AutoMigrate(&entities.Pond{})
+    if err != nil {
+        log.Fatalf("Failed to migrate Pond: %v", err)
+        return
+    }
+    log.Println("Migrated Pond")
+
     err = gormDB.AutoMigrate(&entities.Board{})
     if err != nil {
         log.Fatalf("Failed to migrate Board: %v", err)
@@ -58,6 +67,21 @@ func migrate(db database.Database) {
     }
     log.Println("Migrated BoardRelationship")
 
+    // Adding the role column made every relationship an owner, including the
+    // co-members of a shared board. A board has exactly one owner, so keep the
+    // earliest owner of each board and make the others operators.
+    err = gormDB.Exec(`UPDATE board_relationships br SET role = ?
+        WHERE br.role = ? AND br.deleted_at IS NULL AND EXISTS (
+            SELECT 1 FROM board_relationships earlier
+            WHERE earlier.board_id = br.board_id AND earlier.role = ? AND earlier.deleted_at IS NULL
+                AND (earlier.created_at, earlier.id) < (br.created_at, br.id))`,
+        entities.BoardRoleOperator, entities.BoardRoleOwner, entities.BoardRoleOwner).Error
+    if err != nil {
+        log.Fatalf("Failed to backfill board relationship roles: %v", err)
+        return
+    }
+    log.Println("Backfilled board relationship roles")
+
     err = gormDB.AutoMigrate(&entities.SensorLog{})
     if err != nil {
         log.Fatalf("Failed to migrate SensorLog: %v", err)
@@ -65,4 +89,99 @@ func migrate(db database.Database) {
     }
     log.Println("Migrated sensorLog") 
 
+    err = gormDB.Exec("CREATE INDEX IF NOT EXISTS idx_sensor_logs_board_created ON sensor_logs (board_id, created_at)").Error
+    if err != nil {
+        log.Fatalf("Failed to create sensor_logs history index: %v", err)
+        return
+    }
+    log.Println("Created sensor_logs history index")
+
+    err = gormDB.AutoMigrate(&entities.AlertRule{}, &entities.AlertIncident{})
+    if err != nil {
+        log.Fatalf("Failed to migrate alert tables: %v", err)
+        return
+    }
+    log.Println("Migrated AlertRule and AlertIncident")
+
+    err = gormDB.AutoMigrate(&entities.BoardCommand{})
+    if err != nil {
+        log.Fatalf("Failed to migrate BoardCommand: %v", err)
+        return
+    }
+    log.Println("Migrated BoardCommand")
+
+    err = gormDB.AutoMigrate(&entities.DeadLetterMessage{})
+    if err != nil {
+        log.Fatalf("Failed to migrate DeadLetterMessage: %v", err)
+        return
+    }
+    log.Println("Migrated DeadLetterMessage")
+
+    err = gormDB.AutoMigrate(&entities.Measurement{}, &entities.BoardChannel{})
+    if err != nil {
+        log.Fatalf("Failed to migrate measurement tables: %v", err)
+        return
+    }
+    log.Println("Migrated Measurement and BoardChannel")
+
+    // Readings stored before channels existed keep their values in fixed
+    // columns on sensor_logs. Copy them into measurements once, then drop the
+    // columns so the backfill does not run again.
+    for _, channel := range []string{"temperature", "ec", "ph"} {
+        if !gormDB.Migrator().HasColumn("sensor_logs", channel) {
+            continue
+        }
+        err = gormDB.Transaction(func(tx *gorm.DB) error {
+            err := tx.Exec(`INSERT INTO measurements (sensor_log_id, board_id, channel, value, raw_value, created_at)
+                SELECT id, board_id, ?, `+channel+`, `+channel+`, created_at FROM sensor_logs
+                WHERE `+channel+` IS NOT NULL AND board_id IS NOT NULL`, channel).Error
+            if err != nil {
+                return err
+            }
+            return tx.Migrator().DropColumn("sensor_logs", channel)
+        })
+        if err != nil {
+            log.Fatalf("Failed to backfill %s measurements: %v", channel, err)
+            return
+        }
+        log.Printf("Backfilled %s measurements from sensor_logs", channel)
+    }
+
+    err = gormDB.AutoMigrate(&entities.BoardCalibration{})
+    if err != nil {
+        log.Fatalf("Failed to migrate BoardCalibration: %v", err)
+        return
+    }
+    log.Println("Migrated BoardCalibration")
+
+    // Measurements stored before calibration support were never corrected, so
+    // what they hold is the raw reading.
+    err = gormDB.Exec("UPDATE measurements SET raw_value = value WHERE raw_value IS NULL").Error
+    if err != nil {
+        log.Fatalf("Failed to backfill raw measurement values: %v", err)
+        return
+    }
+    log.Println("Backfilled raw measurement values")
+
+    err = gormDB.AutoMigrate(&entities.FirmwareRelease{}, &entities.FirmwareRollout{}, &entities.FirmwareUpdate{})
+    if err != nil {
+        log.Fatalf("Failed to migrate FirmwareRelease, FirmwareRollout and FirmwareUpdate: %v", err)
+        return
+    }
+    log.Println("Migrated FirmwareRelease, FirmwareRollout and FirmwareUpdate")
+
+    err = gormDB.AutoMigrate(&entities.BoardInvitation{})
+    if err != nil {
+        log.Fatalf("Failed to migrate BoardInvitation: %v", err)
+        return
+    }
+    log.Println("Migrated BoardInvitation")
+
+    err = gormDB.AutoMigrate(&entities.BoardStatus{})
+    if err != nil {
+        log.Fatalf("Failed to migrate BoardStatus: %v", err)
+        return
+    }
+    log.Println("Migrated BoardStatus")
+
 }
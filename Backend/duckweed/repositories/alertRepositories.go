@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+)
+
+type AlertRepositoryInterface interface {
+	CreateRule(rule *entities.AlertRule) (*entities.AlertRule, error)
+	FindRulesByBoardID(boardID string) ([]entities.AlertRule, error)
+	FindRuleByID(id uint) (*entities.AlertRule, error)
+	DeleteRule(id uint) error
+	CreateIncident(incident *entities.AlertIncident) error
+	SaveIncident(incident *entities.AlertIncident) error
+	FindOpenIncidentsByBoardID(boardID string) ([]entities.AlertIncident, error)
+	FindOpenIncidentsByBoardIDs(boardIDs []string) ([]entities.AlertIncident, error)
+	FindIncidentsByBoardID(boardID string, state entities.AlertIncidentStateEnum, limit int) ([]entities.AlertIncident, error)
+}
+
+type AlertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepositoryInterface {
+	return &AlertRepository{db}
+}
+
+func (r *AlertRepository) CreateRule(rule *entities.AlertRule) (*entities.AlertRule, error) {
+	if err := r.db.Create(rule).Error; err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (r *AlertRepository) FindRulesByBoardID(boardID string) ([]entities.AlertRule, error) {
+	var rules []entities.AlertRule
+	err := r.db.Where("board_id = ?", boardID).Order("id ASC").Find(&rules).Error
+	return rules, err
+}
+
+func (r *AlertRepository) FindRuleByID(id uint) (*entities.AlertRule, error) {
+	var rule entities.AlertRule
+	err := r.db.First(&rule, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *AlertRepository) DeleteRule(id uint) error {
+	return r.db.Delete(&entities.AlertRule{}, id).Error
+}
+
+func (r *AlertRepository) CreateIncident(incident *entities.AlertIncident) error {
+	return r.db.Create(incident).Error
+}
+
+func (r *AlertRepository) SaveIncident(incident *entities.AlertIncident) error {
+	return r.db.Save(incident).Error
+}
+
+func (r *AlertRepository) FindOpenIncidentsByBoardID(boardID string) ([]entities.AlertIncident, error) {
+	var incidents []entities.AlertIncident
+	err := r.db.Where("board_id = ? AND state = ?", boardID, entities.AlertIncidentOpen).Find(&incidents).Error
+	return incidents, err
+}
+
+// FindOpenIncidentsByBoardIDs returns the open incidents of several boards,
+// newest first.
+func (r *AlertRepository) FindOpenIncidentsByBoardIDs(boardIDs []string) ([]entities.AlertIncident, error) {
+	var incidents []entities.AlertIncident
+	err := r.db.
+		Where("board_id IN ? AND state = ?", boardIDs, entities.AlertIncidentOpen).
+		Order("opened_at DESC").
+		Find(&incidents).Error
+	return incidents, err
+}
+
+func (r *AlertRepository) FindIncidentsByBoardID(boardID string, state entities.AlertIncidentStateEnum, limit int) ([]entities.AlertIncident, error) {
+	var incidents []entities.AlertIncident
+	query := r.db.Where("board_id = ?", boardID)
+	if state != "" {
+		query = query.Where("state = ?", state)
+	}
+	err := query.Order("opened_at DESC").Limit(limit).Find(&incidents).Error
+	return incidents, err
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BoardChannelRepositoryInterface interface {
+	FindByBoardID(boardID string) ([]entities.BoardChannel, error)
+	Upsert(channel *entities.BoardChannel) (*entities.BoardChannel, error)
+	Delete(boardID string, channel entities.MetricEnum) (bool, error)
+}
+
+type BoardChannelRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardChannelRepository(db *gorm.DB) BoardChannelRepositoryInterface {
+	return &BoardChannelRepository{db}
+}
+
+func (r *BoardChannelRepository) FindByBoardID(boardID string) ([]entities.BoardChannel, error) {
+	var channels []entities.BoardChannel
+	err := r.db.Preload("Sensor").
+		Where("board_id = ?", boardID).
+		Order("channel ASC").
+		Find(&channels).Error
+	return channels, err
+}
+
+// Upsert creates the mapping of a channel or replaces the sensor and label of
+// an existing one.
+func (r *BoardChannelRepository) Upsert(channel *entities.BoardChannel) (*entities.BoardChannel, error) {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "board_id"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sensor_id", "label", "updated_at"}),
+	}).Create(channel).Error
+	if err != nil {
+		return nil, err
+	}
+	return channel, nil
+}
+
+// Delete removes the mapping for good so the channel can be mapped again
+// without tripping the unique index.
+func (r *BoardChannelRepository) Delete(boardID string, channel entities.MetricEnum) (bool, error) {
+	result := r.db.Unscoped().
+		Where("board_id = ? AND channel = ?", boardID, channel).
+		Delete(&entities.BoardChannel{})
+	return result.RowsAffected > 0, result.Error
+}
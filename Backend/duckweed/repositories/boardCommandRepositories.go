@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BoardCommandRepositoryInterface interface {
+	Create(command *entities.BoardCommand) (*entities.BoardCommand, error)
+	MarkSent(id uint, sentAt time.Time) error
+	MarkPublishFailed(id uint, errorMessage string) error
+	RecordAck(command *entities.BoardCommand) (bool, error)
+	FindByCorrelationID(correlationID string) (*entities.BoardCommand, error)
+	FindByBoardID(boardID string, limit int) ([]entities.BoardCommand, error)
+	MarkTimedOut(sentBefore time.Time) (int64, error)
+}
+
+type BoardCommandRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardCommandRepository(db *gorm.DB) BoardCommandRepositoryInterface {
+	return &BoardCommandRepository{db}
+}
+
+func (r *BoardCommandRepository) Create(command *entities.BoardCommand) (*entities.BoardCommand, error) {
+	if err := r.db.Create(command).Error; err != nil {
+		return nil, err
+	}
+	return command, nil
+}
+
+// MarkSent records when the command was published. Only sent_at is written, so
+// an ack that arrived first is kept.
+func (r *BoardCommandRepository) MarkSent(id uint, sentAt time.Time) error {
+	return r.db.Model(&entities.BoardCommand{}).
+		Where("id = ?", id).
+		Update("sent_at", sentAt).Error
+}
+
+// MarkPublishFailed fails a command that could not be published, unless the
+// board already answered it.
+func (r *BoardCommandRepository) MarkPublishFailed(id uint, errorMessage string) error {
+	return r.db.Model(&entities.BoardCommand{}).
+		Where("id = ? AND state = ?", id, entities.CommandStatePending).
+		Updates(map[string]interface{}{
+			"state":         entities.CommandStateFailed,
+			"error_message": errorMessage,
+		}).Error
+}
+
+// RecordAck stores the outcome the board reported, if the command is still
+// pending, and reports whether it was.
+func (r *BoardCommandRepository) RecordAck(command *entities.BoardCommand) (bool, error) {
+	result := r.db.Model(&entities.BoardCommand{}).
+		Where("id = ? AND state = ?", command.ID, entities.CommandStatePending).
+		Updates(map[string]interface{}{
+			"state":         command.State,
+			"acked_at":      command.AckedAt,
+			"error_message": command.ErrorMessage,
+			"result":        command.Result,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *BoardCommandRepository) FindByCorrelationID(correlationID string) (*entities.BoardCommand, error) {
+	var command entities.BoardCommand
+	err := r.db.Where("correlation_id = ?", correlationID).First(&command).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &command, nil
+}
+
+func (r *BoardCommandRepository) FindByBoardID(boardID string, limit int) ([]entities.BoardCommand, error) {
+	var commands []entities.BoardCommand
+	err := r.db.Where("board_id = ?", boardID).Order("created_at DESC").Limit(limit).Find(&commands).Error
+	return commands, err
+}
+
+// MarkTimedOut moves every command still pending since before sentBefore to
+// timed_out. A command whose sent time was never recorded counts from when it
+// was created.
+func (r *BoardCommandRepository) MarkTimedOut(sentBefore time.Time) (int64, error) {
+	result := r.db.Model(&entities.BoardCommand{}).
+		Where("state = ? AND COALESCE(sent_at, created_at) < ?", entities.CommandStatePending, sentBefore).
+		Update("state", entities.CommandStateTimedOut)
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BoardInvitationRepositoryInterface interface {
+	Create(invitation *entities.BoardInvitation) error
+	FindByID(id uint) (*entities.BoardInvitation, error)
+	FindByBoardID(boardID string) ([]entities.BoardInvitation, error)
+	FindPendingByBoardIDAndInvitee(boardID string, inviteeUserID uint, now time.Time) (*entities.BoardInvitation, error)
+	FindPendingByInvitee(inviteeUserID uint, now time.Time) ([]entities.BoardInvitation, error)
+	UpdateStatus(id uint, from entities.InvitationStatusEnum, to entities.InvitationStatusEnum) (bool, error)
+	Accept(invitation *entities.BoardInvitation, relationship *entities.BoardRelationship) (bool, error)
+}
+
+type BoardInvitationRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardInvitationRepository(db *gorm.DB) BoardInvitationRepositoryInterface {
+	return &BoardInvitationRepository{db: db}
+}
+
+func (r *BoardInvitationRepository) Create(invitation *entities.BoardInvitation) error {
+	return r.db.Create(invitation).Error
+}
+
+func (r *BoardInvitationRepository) FindByID(id uint) (*entities.BoardInvitation, error) {
+	var invitation entities.BoardInvitation
+	err := r.db.First(&invitation, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *BoardInvitationRepository) FindByBoardID(boardID string) ([]entities.BoardInvitation, error) {
+	var invitations []entities.BoardInvitation
+	err := r.db.Where("board_id = ?", boardID).Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *BoardInvitationRepository) FindPendingByBoardIDAndInvitee(boardID string, inviteeUserID uint, now time.Time) (*entities.BoardInvitation, error) {
+	var invitation entities.BoardInvitation
+	err := r.db.
+		Where("board_id = ? AND invitee_user_id = ? AND status = ? AND expires_at > ?",
+			boardID, inviteeUserID, entities.InvitationStatusPending, now).
+		First(&invitation).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// FindPendingByInvitee returns the invitations the user can still accept,
+// newest first.
+func (r *BoardInvitationRepository) FindPendingByInvitee(inviteeUserID uint, now time.Time) ([]entities.BoardInvitation, error) {
+	var invitations []entities.BoardInvitation
+	err := r.db.
+		Where("invitee_user_id = ? AND status = ? AND expires_at > ?", inviteeUserID, entities.InvitationStatusPending, now).
+		Order("created_at DESC").
+		Find(&invitations).Error
+	return invitations, err
+}
+
+// UpdateStatus moves the invitation from one status to another. It reports
+// false when the invitation was no longer in the from status.
+func (r *BoardInvitationRepository) UpdateStatus(id uint, from entities.InvitationStatusEnum, to entities.InvitationStatusEnum) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&entities.BoardInvitation{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":       to,
+			"responded_at": &now,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Accept marks the invitation accepted and creates the relationship it grants
+// in one transaction. It reports false, and creates nothing, when the
+// invitation is no longer pending or has expired.
+func (r *BoardInvitationRepository) Accept(invitation *entities.BoardInvitation, relationship *entities.BoardRelationship) (bool, error) {
+	accepted := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Serializes with Unclaim, which decides from the board's members
+		// whether it may unclaim it.
+		var board entities.Board
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("board_id = ?", invitation.BoardID).First(&board).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&entities.BoardInvitation{}).
+			Where("id = ? AND status = ? AND expires_at > ?", invitation.ID, entities.InvitationStatusPending, now).
+			Updates(map[string]interface{}{
+				"status":       entities.InvitationStatusAccepted,
+				"responded_at": &now,
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Omit("Board", "User").Create(relationship).Error; err != nil {
+			return err
+		}
+		invitation.Status = entities.InvitationStatusAccepted
+		invitation.RespondedAt = &now
+		accepted = true
+		return nil
+	})
+	return accepted, err
+}
@@ -0,0 +1,110 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LivenessPolicy decides when a board counts as offline. A board is expected
+// to report as often as the sensors mapped to its channels, or every
+// DefaultInterval without such a frequency, and goes offline after
+// GraceMultiplier times that without a message, but never sooner than
+// MinTimeout.
+type LivenessPolicy struct {
+	DefaultInterval time.Duration
+	GraceMultiplier float64
+	MinTimeout      time.Duration
+}
+
+type BoardLivenessRepositoryInterface interface {
+	MarkOverdueInactive(now time.Time, policy LivenessPolicy) ([]entities.Board, error)
+}
+
+type BoardLivenessRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardLivenessRepository(db *gorm.DB) BoardLivenessRepositoryInterface {
+	return &BoardLivenessRepository{db: db}
+}
+
+// overdueBoard is a board the sweep marked inactive and the status it had.
+type overdueBoard struct {
+	entities.Board
+	PreviousStatus *entities.BoardStatusEnum
+}
+
+// markOverdueInactiveSQL sets every active board that is overdue to inactive
+// in one statement. The expected interval of a board is the shortest
+// SensorFrequency, in seconds, of the sensors mapped to its channels. A board
+// without mappings, or whose sensors have no frequency, uses the default.
+// The last_seen < @cutoff bound is the shortest possible timeout, so
+// idx_boards_status_last_seen skips the boards that reported recently. Rows a
+// heartbeat holds locked are skipped; that board was just heard from.
+const markOverdueInactiveSQL = `
+WITH overdue AS (
+	SELECT b.id, b.board_status
+	FROM boards b
+	WHERE b.deleted_at IS NULL
+		AND (b.board_status = @active OR b.board_status IS NULL)
+		AND b.last_seen < @cutoff
+		AND b.last_seen < CAST(@now AS timestamptz) - make_interval(secs => GREATEST(
+			COALESCE(
+				(SELECT MIN(s.sensor_frequency)
+				FROM board_channels bc
+				JOIN sensors s ON s.sensor_id = bc.sensor_id AND s.deleted_at IS NULL
+				WHERE bc.board_id = b.board_id AND bc.deleted_at IS NULL AND s.sensor_frequency > 0),
+				CAST(@default_interval AS double precision)
+			) * CAST(@grace AS double precision),
+			CAST(@min_timeout AS double precision)
+		))
+	FOR UPDATE OF b SKIP LOCKED
+)
+UPDATE boards
+SET board_status = @inactive, updated_at = @now
+FROM overdue
+WHERE boards.id = overdue.id
+RETURNING boards.*, overdue.board_status AS previous_status`
+
+// MarkOverdueInactive marks the boards that stopped reporting as inactive and
+// records a timeout transition for each, returning the boards it changed.
+func (r *BoardLivenessRepository) MarkOverdueInactive(now time.Time, policy LivenessPolicy) ([]entities.Board, error) {
+	var rows []overdueBoard
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Raw(markOverdueInactiveSQL, map[string]interface{}{
+			"active":           entities.BoardStatusActive,
+			"inactive":         entities.BoardStatusInactive,
+			"now":              now,
+			"cutoff":           now.Add(-policy.MinTimeout),
+			"default_interval": policy.DefaultInterval.Seconds(),
+			"grace":            policy.GraceMultiplier,
+			"min_timeout":      policy.MinTimeout.Seconds(),
+		}).Scan(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		transitions := make([]entities.BoardStatus, 0, len(rows))
+		for _, row := range rows {
+			transition := entities.NewBoardStatusTransition(row.BoardID, row.PreviousStatus, entities.BoardStatusInactive, entities.BoardStatusCauseTimeout, now)
+			if transition != nil {
+				transitions = append(transitions, *transition)
+			}
+		}
+		if len(transitions) == 0 {
+			return nil
+		}
+		return tx.Create(&transitions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	boards := make([]entities.Board, 0, len(rows))
+	for _, row := range rows {
+		boards = append(boards, row.Board)
+	}
+	return boards, nil
+}
@@ -1,17 +1,37 @@
 package repositories
 
 import (
+	"errors"
 	"log"
 	"main/duckweed/entities"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BoardRelationshipRepositoryInterface interface {
 	Create(relationship *entities.BoardRelationship) (*entities.BoardRelationship, error)
 	FindByBoardIDAndUserID(boardID string, userID uint) (*entities.BoardRelationship, error)
+	FindByUserID(userID uint) ([]entities.BoardRelationship, error)
+	FindByBoardID(boardID string) ([]entities.BoardRelationship, error)
+	UpdateRole(relationship *entities.BoardRelationship, role entities.BoardRoleEnum) error
+	UpdateConStatus(relationship *entities.BoardRelationship, status entities.ConStatusEnum) error
+	Delete(relationship *entities.BoardRelationship) error
+	TransferOwnership(boardID string, fromUserID uint, toUserID uint) (bool, error)
+	Claim(relationship *entities.BoardRelationship, claimCodeHash string, boardName *string, mqttPasswordHash *string) (bool, error)
+	Unclaim(relationship *entities.BoardRelationship, claimCodeHash string) (bool, error)
 }
 
+// ErrBoardStillShared is returned by Unclaim when the owner would leave a
+// board other users still have access to.
+var ErrBoardStillShared = errors.New("board is still shared with other users")
+
+var (
+	errTransferTargetMissing = errors.New("new owner has no relationship with the board")
+	errBoardHasOwner         = errors.New("board already has an owner")
+)
+
 type BoardRelationshipRepository struct {
 	db *gorm.DB
 }
@@ -43,3 +63,203 @@ func (r *BoardRelationshipRepository) FindByBoardIDAndUserID(boardID string, use
 	}
 	return &relationship, nil
 }
+
+func (r *BoardRelationshipRepository) FindByUserID(userID uint) ([]entities.BoardRelationship, error) {
+	var relationships []entities.BoardRelationship
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&relationships).Error
+	return relationships, err
+}
+
+func (r *BoardRelationshipRepository) FindByBoardID(boardID string) ([]entities.BoardRelationship, error) {
+	var relationships []entities.BoardRelationship
+	err := r.db.Where("board_id = ?", boardID).Order("created_at ASC").Find(&relationships).Error
+	return relationships, err
+}
+
+func (r *BoardRelationshipRepository) UpdateRole(relationship *entities.BoardRelationship, role entities.BoardRoleEnum) error {
+	err := r.db.Model(relationship).Update("role", role).Error
+	if err == nil {
+		relationship.Role = role
+	}
+	return err
+}
+
+func (r *BoardRelationshipRepository) UpdateConStatus(relationship *entities.BoardRelationship, status entities.ConStatusEnum) error {
+	err := r.db.Model(relationship).Update("con_status", status).Error
+	if err == nil {
+		relationship.ConStatus = status
+	}
+	return err
+}
+
+func (r *BoardRelationshipRepository) Delete(relationship *entities.BoardRelationship) error {
+	return r.db.Unscoped().Delete(relationship).Error
+}
+
+// TransferOwnership makes toUserID the owner of the board and fromUserID an
+// operator in one transaction. It reports false, and changes nothing, when
+// fromUserID is not the owner or toUserID has no relationship with the board.
+func (r *BoardRelationshipRepository) TransferOwnership(boardID string, fromUserID uint, toUserID uint) (bool, error) {
+	transferred := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&entities.BoardRelationship{}).
+			Where("board_id = ? AND user_id = ? AND role = ?", boardID, fromUserID, entities.BoardRoleOwner).
+			Update("role", entities.BoardRoleOperator)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		result = tx.Model(&entities.BoardRelationship{}).
+			Where("board_id = ? AND user_id = ?", boardID, toUserID).
+			Update("role", entities.BoardRoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Undo the demotion above.
+			return errTransferTargetMissing
+		}
+		transferred = true
+		return nil
+	})
+	if errors.Is(err, errTransferTargetMissing) {
+		return false, nil
+	}
+	return transferred, err
+}
+
+// Claim uses up the board's claim code and creates the relationship in one
+// transaction. It reports false, and creates nothing, when the code does not
+// match, including when another user claimed the board first, or when the
+// board still has an owner. A non-nil mqttPasswordHash becomes the board's
+// broker password.
+func (r *BoardRelationshipRepository) Claim(relationship *entities.BoardRelationship, claimCodeHash string, boardName *string, mqttPasswordHash *string) (bool, error) {
+	claimed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		updates := map[string]interface{}{
+			"claim_code_hash":     nil,
+			"claimed_at":          &now,
+			"board_register_date": &now,
+		}
+		if boardName != nil {
+			updates["board_name"] = boardName
+		}
+		if mqttPasswordHash != nil {
+			updates["mqtt_password_hash"] = mqttPasswordHash
+		}
+		result := tx.Model(&entities.Board{}).
+			Where("board_id = ? AND claim_code_hash = ?", relationship.BoardID, claimCodeHash).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		// A claim code issued again for a board someone still owns must not
+		// make a second owner.
+		var owners int64
+		err := tx.Model(&entities.BoardRelationship{}).
+			Where("board_id = ? AND role = ?", relationship.BoardID, entities.BoardRoleOwner).
+			Count(&owners).Error
+		if err != nil {
+			return err
+		}
+		if owners > 0 {
+			// Undo using up the claim code above.
+			return errBoardHasOwner
+		}
+		if err := tx.Omit("Board", "User").Create(relationship).Error; err != nil {
+			return err
+		}
+		claimed = true
+		return nil
+	})
+	if errors.Is(err, errBoardHasOwner) {
+		return false, nil
+	}
+	return claimed, err
+}
+
+// Unclaim deletes the relationship. When no other user is left on the board
+// it also returns the board to the state it was provisioned in, with
+// claimCodeHash as its new claim code and no broker password until it is
+// claimed again, drops its device token and alert rules, revokes the
+// invitations still pending for it and reports true. The owner cannot leave
+// while other users remain; that returns ErrBoardStillShared. The board row
+// is locked, so an invitation accepted meanwhile waits for the outcome.
+func (r *BoardRelationshipRepository) Unclaim(relationship *entities.BoardRelationship, claimCodeHash string) (bool, error) {
+	unclaimed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var board entities.Board
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("board_id = ?", relationship.BoardID).First(&board).Error
+		if err != nil {
+			return err
+		}
+
+		var others int64
+		err = tx.Model(&entities.BoardRelationship{}).
+			Where("board_id = ? AND id <> ?", relationship.BoardID, relationship.ID).
+			Count(&others).Error
+		if err != nil {
+			return err
+		}
+		if others > 0 && relationship.Role == entities.BoardRoleOwner {
+			return ErrBoardStillShared
+		}
+
+		if err := tx.Unscoped().Delete(relationship).Error; err != nil {
+			return err
+		}
+		if others > 0 {
+			return nil
+		}
+
+		err = tx.Model(&entities.Board{}).
+			Where("board_id = ?", relationship.BoardID).
+			Updates(map[string]interface{}{
+				"claim_code_hash":        claimCodeHash,
+				"mqtt_password_hash":     nil,
+				"device_token_hash":      nil,
+				"device_token_issued_at": nil,
+				"claimed_at":             nil,
+				"board_register_date":    nil,
+				"board_name":             nil,
+				"location":               nil,
+				"notes":                  nil,
+				"pond_id":                nil,
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		// Alert rules are the previous owner's limits for their pond. Channel
+		// maps and calibrations describe the board's probes, which go with
+		// the board, so they are kept.
+		err = tx.Where("board_id = ?", relationship.BoardID).Delete(&entities.AlertRule{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&entities.AlertIncident{}).
+			Where("board_id = ? AND state = ?", relationship.BoardID, entities.AlertIncidentOpen).
+			Updates(map[string]interface{}{
+				"state":     entities.AlertIncidentClosed,
+				"closed_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&entities.BoardInvitation{}).
+			Where("board_id = ? AND status = ?", relationship.BoardID, entities.InvitationStatusPending).
+			Update("status", entities.InvitationStatusRevoked).Error
+		if err != nil {
+			return err
+		}
+		unclaimed = true
+		return nil
+	})
+	return unclaimed, err
+}
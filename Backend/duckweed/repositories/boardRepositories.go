@@ -1,7 +1,9 @@
 package repositories
 
 import (
+	"errors"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"main/duckweed/entities"
 	"time"
 )
@@ -10,7 +12,18 @@ type BoardRepositoryInterface interface {
 	FindAll() ([]entities.Board, error)
 	FindByID(id uint) (*entities.Board, error)
 	FindByBoardID(boardID string) (*entities.Board, error)
+	FindByBoardIDs(boardIDs []string) ([]entities.Board, error)
+	FindByHardwareModel(hardwareModel string) ([]entities.Board, error)
+	FindByPondID(pondID uint) ([]entities.Board, error)
 	Create(board *entities.Board) (*entities.Board, error)
+	UpdateDeviceToken(boardID string, tokenHash *string, issuedAt *time.Time) error
+	FindExistingBoardIDs(boardIDs []string) ([]string, error)
+	CreateProvisioned(boards []entities.Board) error
+	UpdateClaimCode(boardID string, claimCodeHash *string) error
+	UpdateMqttPassword(boardID string, passwordHash *string) error
+	UpdateDetails(boardID string, updates map[string]interface{}) error
+	UpdatePond(boardID string, pondID *uint) error
+	SetDisabled(boardID string, disabled bool, reason *string, now time.Time) (*entities.Board, error)
 }
 
 type BoardRepository struct {
@@ -33,6 +46,12 @@ func (r *BoardRepository) FindByBoardID(boardID string) (*entities.Board, error)
 	return &board, nil
 }
 
+func (r *BoardRepository) FindByBoardIDs(boardIDs []string) ([]entities.Board, error) {
+	var boards []entities.Board
+	err := r.db.Where("board_id IN ?", boardIDs).Find(&boards).Error
+	return boards, err
+}
+
 func (r *BoardRepository) Create(board *entities.Board) (*entities.Board, error) {
 	now := time.Now()
 	activeStatus := entities.BoardStatusActive
@@ -57,3 +76,121 @@ func (r *BoardRepository) FindByID(id uint) (*entities.Board, error) {
 	err := r.db.Preload("Sensors").First(&board, id).Error
 	return &board, err
 }
+
+func (r *BoardRepository) FindByHardwareModel(hardwareModel string) ([]entities.Board, error) {
+	var boards []entities.Board
+	err := r.db.Where("hardware_model = ?", hardwareModel).Order("board_id").Find(&boards).Error
+	return boards, err
+}
+
+func (r *BoardRepository) FindByPondID(pondID uint) ([]entities.Board, error) {
+	var boards []entities.Board
+	err := r.db.Where("pond_id = ?", pondID).Order("board_id").Find(&boards).Error
+	return boards, err
+}
+
+func (r *BoardRepository) UpdateDeviceToken(boardID string, tokenHash *string, issuedAt *time.Time) error {
+	return r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		Updates(map[string]interface{}{
+			"device_token_hash":      tokenHash,
+			"device_token_issued_at": issuedAt,
+		}).Error
+}
+
+func (r *BoardRepository) FindExistingBoardIDs(boardIDs []string) ([]string, error) {
+	var existing []string
+	err := r.db.Model(&entities.Board{}).Where("board_id IN ?", boardIDs).Pluck("board_id", &existing).Error
+	return existing, err
+}
+
+// CreateProvisioned stores factory-provisioned boards in one transaction, so
+// an import either adds every board or none. Unlike Create it leaves the
+// boards inactive and unregistered until a user claims them.
+func (r *BoardRepository) CreateProvisioned(boards []entities.Board) error {
+	inactiveStatus := entities.BoardStatusInactive
+	for i := range boards {
+		boards[i].BoardStatus = &inactiveStatus
+	}
+	return r.db.CreateInBatches(boards, 200).Error
+}
+
+func (r *BoardRepository) UpdateClaimCode(boardID string, claimCodeHash *string) error {
+	return r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		Update("claim_code_hash", claimCodeHash).Error
+}
+
+func (r *BoardRepository) UpdateMqttPassword(boardID string, passwordHash *string) error {
+	return r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		Update("mqtt_password_hash", passwordHash).Error
+}
+
+// UpdateDetails sets the user-editable columns in updates, keyed by column
+// name.
+func (r *BoardRepository) UpdateDetails(boardID string, updates map[string]interface{}) error {
+	return r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		Updates(updates).Error
+}
+
+func (r *BoardRepository) UpdatePond(boardID string, pondID *uint) error {
+	return r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		Update("pond_id", pondID).Error
+}
+
+// SetDisabled disables the board, or enables it again, and records the status
+// change. An enabled board is inactive until it is heard from again, and
+// disabling a disabled board only replaces the reason. The row is locked so a
+// heartbeat arriving meanwhile cannot undo the change. It returns nil when the
+// board does not exist.
+func (r *BoardRepository) SetDisabled(boardID string, disabled bool, reason *string, now time.Time) (*entities.Board, error) {
+	var board entities.Board
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("board_id = ?", boardID).First(&board).Error
+		if err != nil {
+			return err
+		}
+
+		wasDisabled := board.BoardStatus != nil && *board.BoardStatus == entities.BoardStatusDisabled
+		var transition *entities.BoardStatus
+		switch {
+		case disabled:
+			board.DisabledReason = reason
+			if !wasDisabled {
+				status := entities.BoardStatusDisabled
+				transition = entities.NewBoardStatusTransition(boardID, board.BoardStatus, status, entities.BoardStatusCauseManualDisable, now)
+				board.BoardStatus = &status
+				board.DisabledAt = &now
+			}
+		case wasDisabled:
+			status := entities.BoardStatusInactive
+			transition = entities.NewBoardStatusTransition(boardID, board.BoardStatus, status, entities.BoardStatusCauseManualEnable, now)
+			board.BoardStatus = &status
+			board.DisabledAt = nil
+			board.DisabledReason = nil
+		default:
+			return nil
+		}
+
+		err = tx.Model(&board).
+			Select("board_status", "disabled_at", "disabled_reason", "updated_at").
+			Updates(&board).Error
+		if err != nil {
+			return err
+		}
+		if transition != nil {
+			return tx.Create(transition).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &board, nil
+}
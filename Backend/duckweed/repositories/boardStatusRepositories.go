@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BoardStatusRepositoryInterface interface {
+	FindByBoardIDInRange(boardID string, from, to time.Time, limit int) ([]entities.BoardStatus, error)
+	FindLastBefore(boardID string, before time.Time) (*entities.BoardStatus, error)
+}
+
+type BoardStatusRepository struct {
+	db *gorm.DB
+}
+
+func NewBoardStatusRepository(db *gorm.DB) BoardStatusRepositoryInterface {
+	return &BoardStatusRepository{db: db}
+}
+
+// FindByBoardIDInRange returns the status changes of a board in [from, to),
+// oldest first.
+func (r *BoardStatusRepository) FindByBoardIDInRange(boardID string, from, to time.Time, limit int) ([]entities.BoardStatus, error) {
+	var transitions []entities.BoardStatus
+	err := r.db.
+		Where("board_id = ? AND changed_at >= ? AND changed_at < ?", boardID, from, to).
+		Order("changed_at ASC, id ASC").
+		Limit(limit).
+		Find(&transitions).Error
+	return transitions, err
+}
+
+// FindLastBefore returns the last status change of a board before the given
+// time, which holds the status the board had then. It returns nil when there
+// is none.
+func (r *BoardStatusRepository) FindLastBefore(boardID string, before time.Time) (*entities.BoardStatus, error) {
+	var transitions []entities.BoardStatus
+	err := r.db.
+		Where("board_id = ? AND changed_at < ?", boardID, before).
+		Order("changed_at DESC, id DESC").
+		Limit(1).
+		Find(&transitions).Error
+	if err != nil || len(transitions) == 0 {
+		return nil, err
+	}
+	return &transitions[0], nil
+}
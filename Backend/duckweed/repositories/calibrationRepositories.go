@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CalibrationRepositoryInterface interface {
+	CreateSession(calibrations []*entities.BoardCalibration) error
+	FindByBoardID(boardID string, channel entities.MetricEnum) ([]entities.BoardCalibration, error)
+	FindChannelsByBoardID(boardID string) ([]entities.MetricEnum, error)
+	Recompute(boardID string, channel entities.MetricEnum, calibrations []entities.BoardCalibration, from, to *time.Time) (int64, error)
+}
+
+type CalibrationRepository struct {
+	db *gorm.DB
+}
+
+func NewCalibrationRepository(db *gorm.DB) CalibrationRepositoryInterface {
+	return &CalibrationRepository{db}
+}
+
+// CreateSession stores the calibrations of one session together, or none.
+func (r *CalibrationRepository) CreateSession(calibrations []*entities.BoardCalibration) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(calibrations).Error
+	})
+}
+
+// FindByBoardID returns the calibrations of a board ordered by ValidFrom,
+// limited to one channel when channel is not empty.
+func (r *CalibrationRepository) FindByBoardID(boardID string, channel entities.MetricEnum) ([]entities.BoardCalibration, error) {
+	query := r.db.Where("board_id = ?", boardID)
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	var calibrations []entities.BoardCalibration
+	err := query.Order("channel ASC, valid_from ASC, id ASC").Find(&calibrations).Error
+	return calibrations, err
+}
+
+func (r *CalibrationRepository) FindChannelsByBoardID(boardID string) ([]entities.MetricEnum, error) {
+	var channels []entities.MetricEnum
+	err := r.db.Model(&entities.BoardCalibration{}).
+		Where("board_id = ?", boardID).
+		Distinct("channel").
+		Order("channel ASC").
+		Pluck("channel", &channels).Error
+	return channels, err
+}
+
+// Recompute rewrites the calibrated value of the stored measurements of a
+// channel from their raw value. calibrations must be ordered by ValidFrom;
+// each one covers the readings up to the next, and readings older than the
+// first are reset to their raw value.
+func (r *CalibrationRepository) Recompute(boardID string, channel entities.MetricEnum, calibrations []entities.BoardCalibration, from, to *time.Time) (int64, error) {
+	var updated int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := -1; i < len(calibrations); i++ {
+			query := tx.Model(&entities.Measurement{}).
+				Where("board_id = ? AND channel = ? AND raw_value IS NOT NULL", boardID, channel)
+			if from != nil {
+				query = query.Where("created_at >= ?", *from)
+			}
+			if to != nil {
+				query = query.Where("created_at < ?", *to)
+			}
+			if i >= 0 {
+				query = query.Where("created_at >= ?", calibrations[i].ValidFrom)
+			}
+			if i+1 < len(calibrations) {
+				query = query.Where("created_at < ?", calibrations[i+1].ValidFrom)
+			}
+
+			updates := map[string]interface{}{
+				"value":          gorm.Expr("raw_value"),
+				"calibration_id": nil,
+			}
+			if i >= 0 {
+				updates["value"] = gorm.Expr("raw_value * ? + ?", calibrations[i].Slope, calibrations[i].Offset)
+				updates["calibration_id"] = calibrations[i].ID
+			}
+
+			result := query.Updates(updates)
+			if result.Error != nil {
+				return result.Error
+			}
+			updated += result.RowsAffected
+		}
+		return nil
+	})
+	return updated, err
+}
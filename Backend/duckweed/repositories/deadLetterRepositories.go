@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+)
+
+type DeadLetterRepositoryInterface interface {
+	Create(message *entities.DeadLetterMessage) error
+	Save(message *entities.DeadLetterMessage) error
+	FindByID(id uint) (*entities.DeadLetterMessage, error)
+	Find(filter entities.DeadLetterFilterDto) ([]entities.DeadLetterMessage, error)
+	Delete(id uint) error
+	Purge(filter entities.DeadLetterFilterDto) (int64, error)
+}
+
+type DeadLetterRepository struct {
+	db *gorm.DB
+}
+
+func NewDeadLetterRepository(db *gorm.DB) DeadLetterRepositoryInterface {
+	return &DeadLetterRepository{db}
+}
+
+func (r *DeadLetterRepository) Create(message *entities.DeadLetterMessage) error {
+	return r.db.Create(message).Error
+}
+
+func (r *DeadLetterRepository) Save(message *entities.DeadLetterMessage) error {
+	return r.db.Save(message).Error
+}
+
+func (r *DeadLetterRepository) FindByID(id uint) (*entities.DeadLetterMessage, error) {
+	var message entities.DeadLetterMessage
+	err := r.db.First(&message, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *DeadLetterRepository) Find(filter entities.DeadLetterFilterDto) ([]entities.DeadLetterMessage, error) {
+	var messages []entities.DeadLetterMessage
+	query := applyDeadLetterFilter(r.db, filter).Order("received_at ASC")
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	err := query.Find(&messages).Error
+	return messages, err
+}
+
+func (r *DeadLetterRepository) Delete(id uint) error {
+	return r.db.Unscoped().Delete(&entities.DeadLetterMessage{}, id).Error
+}
+
+// Purge permanently removes every dead letter matching the filter.
+func (r *DeadLetterRepository) Purge(filter entities.DeadLetterFilterDto) (int64, error) {
+	result := applyDeadLetterFilter(r.db.Unscoped(), filter).Delete(&entities.DeadLetterMessage{})
+	return result.RowsAffected, result.Error
+}
+
+func applyDeadLetterFilter(query *gorm.DB, filter entities.DeadLetterFilterDto) *gorm.DB {
+	query = query.Model(&entities.DeadLetterMessage{})
+	if filter.BoardID != "" {
+		query = query.Where("board_id = ?", filter.BoardID)
+	}
+	if filter.ReasonCode != "" {
+		query = query.Where("reason_code = ?", filter.ReasonCode)
+	}
+	if filter.Before != nil {
+		query = query.Where("received_at < ?", *filter.Before)
+	}
+	if !filter.IncludeReplayed {
+		query = query.Where("replayed_at IS NULL")
+	}
+	return query
+}
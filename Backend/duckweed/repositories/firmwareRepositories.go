@@ -0,0 +1,280 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// unfinishedUpdateStates are the states of an update that still needs work
+// from the board or the rollout.
+var unfinishedUpdateStates = []entities.FirmwareUpdateStateEnum{
+	entities.FirmwareUpdatePending,
+	entities.FirmwareUpdateNotified,
+	entities.FirmwareUpdateDownloading,
+	entities.FirmwareUpdateInstalling,
+}
+
+// inFlightUpdateStates are the states of an update the board has been told about.
+var inFlightUpdateStates = []entities.FirmwareUpdateStateEnum{
+	entities.FirmwareUpdateNotified,
+	entities.FirmwareUpdateDownloading,
+	entities.FirmwareUpdateInstalling,
+}
+
+type FirmwareRepositoryInterface interface {
+	CreateRelease(release *entities.FirmwareRelease) (*entities.FirmwareRelease, error)
+	FindReleases() ([]entities.FirmwareRelease, error)
+	FindReleaseByID(id uint) (*entities.FirmwareRelease, error)
+	FindReleaseByVersion(hardwareModel string, version string) (*entities.FirmwareRelease, error)
+
+	CreateRollout(rollout *entities.FirmwareRollout, updates []entities.FirmwareUpdate) error
+	AdvanceRolloutStage(id uint, stageIndex int, startedAt time.Time) (bool, error)
+	AcknowledgeRolloutResults(id uint, failed int, finished int) (bool, error)
+	TransitionRollout(id uint, from entities.RolloutStateEnum, to entities.RolloutStateEnum, reason *string) (bool, error)
+	FindRollouts(limit int) ([]entities.FirmwareRollout, error)
+	FindRolloutByID(id uint) (*entities.FirmwareRollout, error)
+
+	SaveUpdate(update *entities.FirmwareUpdate) error
+	FindUpdateByID(id uint) (*entities.FirmwareUpdate, error)
+	FindUpdateByCorrelationID(correlationID string) (*entities.FirmwareUpdate, error)
+	FindUpdatesByRolloutID(rolloutID uint) ([]entities.FirmwareUpdate, error)
+	FindUpdatesByBoardID(boardID string, limit int) ([]entities.FirmwareUpdate, error)
+	FindPendingUpdates(rolloutID uint, belowCohort int) ([]entities.FirmwareUpdate, error)
+	FindUnfinishedUpdate(boardID string) (*entities.FirmwareUpdate, error)
+	FindBoardsWithUnfinishedUpdates(boardIDs []string) ([]string, error)
+	FindStaleUpdates(notifiedBefore time.Time) ([]entities.FirmwareUpdate, error)
+	CountUpdatesByState(rolloutID uint) (map[entities.FirmwareUpdateStateEnum]int, error)
+	CancelPendingUpdates(rolloutID uint) (int64, error)
+}
+
+type FirmwareRepository struct {
+	db *gorm.DB
+}
+
+func NewFirmwareRepository(db *gorm.DB) FirmwareRepositoryInterface {
+	return &FirmwareRepository{db}
+}
+
+func (r *FirmwareRepository) CreateRelease(release *entities.FirmwareRelease) (*entities.FirmwareRelease, error) {
+	if err := r.db.Create(release).Error; err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+func (r *FirmwareRepository) FindReleases() ([]entities.FirmwareRelease, error) {
+	var releases []entities.FirmwareRelease
+	err := r.db.Order("created_at DESC").Find(&releases).Error
+	return releases, err
+}
+
+func (r *FirmwareRepository) FindReleaseByID(id uint) (*entities.FirmwareRelease, error) {
+	var release entities.FirmwareRelease
+	err := r.db.First(&release, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &release, nil
+}
+
+func (r *FirmwareRepository) FindReleaseByVersion(hardwareModel string, version string) (*entities.FirmwareRelease, error) {
+	var release entities.FirmwareRelease
+	err := r.db.Where("hardware_model = ? AND version = ?", hardwareModel, version).First(&release).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &release, nil
+}
+
+// CreateRollout stores the rollout together with the update row of every
+// target board.
+func (r *FirmwareRepository) CreateRollout(rollout *entities.FirmwareRollout, updates []entities.FirmwareUpdate) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Release").Create(rollout).Error; err != nil {
+			return err
+		}
+		for i := range updates {
+			updates[i].RolloutID = rollout.ID
+		}
+		return tx.CreateInBatches(updates, 200).Error
+	})
+}
+
+// AdvanceRolloutStage moves an active rollout from the stage before
+// stageIndex to stageIndex and reports whether it did. It does not, and
+// leaves the state alone, when the rollout was halted or advanced meanwhile.
+func (r *FirmwareRepository) AdvanceRolloutStage(id uint, stageIndex int, startedAt time.Time) (bool, error) {
+	result := r.db.Model(&entities.FirmwareRollout{}).
+		Where("id = ? AND state = ? AND stage_index = ?", id, entities.RolloutStateActive, stageIndex-1).
+		Updates(map[string]interface{}{
+			"stage_index":      stageIndex,
+			"stage_started_at": startedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// AcknowledgeRolloutResults records the update results an admin has seen on a
+// halted rollout and reports whether it was still halted.
+func (r *FirmwareRepository) AcknowledgeRolloutResults(id uint, failed int, finished int) (bool, error) {
+	result := r.db.Model(&entities.FirmwareRollout{}).
+		Where("id = ? AND state = ?", id, entities.RolloutStateHalted).
+		Updates(map[string]interface{}{
+			"acknowledged_failed":   failed,
+			"acknowledged_finished": finished,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// TransitionRollout moves a rollout from one state to another and reports
+// whether it was still in the from state, so two writers cannot both halt
+// or complete it.
+func (r *FirmwareRepository) TransitionRollout(id uint, from entities.RolloutStateEnum, to entities.RolloutStateEnum, reason *string) (bool, error) {
+	updates := map[string]interface{}{"state": to, "halt_reason": reason}
+	if to == entities.RolloutStateCompleted || to == entities.RolloutStateCancelled {
+		updates["completed_at"] = time.Now()
+	}
+	result := r.db.Model(&entities.FirmwareRollout{}).
+		Where("id = ? AND state = ?", id, from).
+		Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *FirmwareRepository) FindRollouts(limit int) ([]entities.FirmwareRollout, error) {
+	var rollouts []entities.FirmwareRollout
+	err := r.db.Preload("Release").Order("created_at DESC").Limit(limit).Find(&rollouts).Error
+	return rollouts, err
+}
+
+func (r *FirmwareRepository) FindRolloutByID(id uint) (*entities.FirmwareRollout, error) {
+	var rollout entities.FirmwareRollout
+	err := r.db.Preload("Release").First(&rollout, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+func (r *FirmwareRepository) SaveUpdate(update *entities.FirmwareUpdate) error {
+	return r.db.Save(update).Error
+}
+
+func (r *FirmwareRepository) FindUpdateByID(id uint) (*entities.FirmwareUpdate, error) {
+	var update entities.FirmwareUpdate
+	err := r.db.First(&update, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &update, nil
+}
+
+func (r *FirmwareRepository) FindUpdateByCorrelationID(correlationID string) (*entities.FirmwareUpdate, error) {
+	var update entities.FirmwareUpdate
+	err := r.db.Where("correlation_id = ?", correlationID).First(&update).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &update, nil
+}
+
+func (r *FirmwareRepository) FindUpdatesByRolloutID(rolloutID uint) ([]entities.FirmwareUpdate, error) {
+	var updates []entities.FirmwareUpdate
+	err := r.db.Where("rollout_id = ?", rolloutID).Order("cohort, board_id").Find(&updates).Error
+	return updates, err
+}
+
+func (r *FirmwareRepository) FindUpdatesByBoardID(boardID string, limit int) ([]entities.FirmwareUpdate, error) {
+	var updates []entities.FirmwareUpdate
+	err := r.db.Where("board_id = ?", boardID).Order("created_at DESC").Limit(limit).Find(&updates).Error
+	return updates, err
+}
+
+// FindPendingUpdates returns the updates of a rollout still waiting to be
+// sent whose cohort falls below belowCohort.
+func (r *FirmwareRepository) FindPendingUpdates(rolloutID uint, belowCohort int) ([]entities.FirmwareUpdate, error) {
+	var updates []entities.FirmwareUpdate
+	err := r.db.Where("rollout_id = ? AND state = ? AND cohort < ?", rolloutID, entities.FirmwareUpdatePending, belowCohort).
+		Order("cohort, board_id").
+		Find(&updates).Error
+	return updates, err
+}
+
+// FindUnfinishedUpdate returns the newest update of the board that has not
+// reached a final state.
+func (r *FirmwareRepository) FindUnfinishedUpdate(boardID string) (*entities.FirmwareUpdate, error) {
+	var update entities.FirmwareUpdate
+	err := r.db.Where("board_id = ? AND state IN ?", boardID, unfinishedUpdateStates).
+		Order("created_at DESC").
+		First(&update).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &update, nil
+}
+
+func (r *FirmwareRepository) FindBoardsWithUnfinishedUpdates(boardIDs []string) ([]string, error) {
+	var busy []string
+	err := r.db.Model(&entities.FirmwareUpdate{}).
+		Distinct("board_id").
+		Where("board_id IN ? AND state IN ?", boardIDs, unfinishedUpdateStates).
+		Pluck("board_id", &busy).Error
+	return busy, err
+}
+
+// FindStaleUpdates returns the updates sent before notifiedBefore that the
+// board has not finished yet.
+func (r *FirmwareRepository) FindStaleUpdates(notifiedBefore time.Time) ([]entities.FirmwareUpdate, error) {
+	var updates []entities.FirmwareUpdate
+	err := r.db.Where("state IN ? AND notified_at < ?", inFlightUpdateStates, notifiedBefore).Find(&updates).Error
+	return updates, err
+}
+
+func (r *FirmwareRepository) CountUpdatesByState(rolloutID uint) (map[entities.FirmwareUpdateStateEnum]int, error) {
+	var rows []struct {
+		State entities.FirmwareUpdateStateEnum
+		Count int
+	}
+	err := r.db.Model(&entities.FirmwareUpdate{}).
+		Select("state, COUNT(*) AS count").
+		Where("rollout_id = ?", rolloutID).
+		Group("state").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[entities.FirmwareUpdateStateEnum]int, len(rows))
+	for _, row := range rows {
+		counts[row.State] = row.Count
+	}
+	return counts, nil
+}
+
+// CancelPendingUpdates cancels the updates of a rollout that were never sent.
+// Boards already notified are left to finish.
+func (r *FirmwareRepository) CancelPendingUpdates(rolloutID uint) (int64, error) {
+	now := time.Now()
+	result := r.db.Model(&entities.FirmwareUpdate{}).
+		Where("rollout_id = ? AND state = ?", rolloutID, entities.FirmwareUpdatePending).
+		Updates(map[string]interface{}{"state": entities.FirmwareUpdateCancelled, "finished_at": &now})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const leaderLockTimeout = 5 * time.Second
+
+// LeaderLockInterface elects one replica to run a background job.
+type LeaderLockInterface interface {
+	// TryAcquire reports whether this replica is the leader, taking the lock
+	// when no other replica holds it.
+	TryAcquire() (bool, error)
+}
+
+// AdvisoryLeaderLock is a Postgres session advisory lock. The session, and so
+// the lock, lives on one connection kept out of the pool while this replica
+// leads. If the connection drops, Postgres releases the lock and another
+// replica takes over on its next attempt.
+type AdvisoryLeaderLock struct {
+	db    *gorm.DB
+	key   int64
+	mutex sync.Mutex
+	conn  *sql.Conn
+}
+
+func NewAdvisoryLeaderLock(db *gorm.DB, key int64) LeaderLockInterface {
+	return &AdvisoryLeaderLock{db: db, key: key}
+}
+
+func (l *AdvisoryLeaderLock) TryAcquire() (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaderLockTimeout)
+	defer cancel()
+
+	if l.conn != nil {
+		// Still leading as long as the session that holds the lock is alive.
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return false, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !acquired {
+		conn.Close()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
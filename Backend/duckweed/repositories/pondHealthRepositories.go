@@ -32,6 +32,16 @@ func (r *PondHealthRepository) FindByUserID(userID uint) ([]entities.PondHealth,
 	return ponds, err
 }
 
+// FindLatestByScannedPondID returns the most recent scan of a pond, or nil
+// when it has none.
+func (r *PondHealthRepository) FindLatestByScannedPondID(pondID uint) (*entities.PondHealth, error) {
+	var scans []entities.PondHealth
+	err := r.db.Where("scanned_pond_id = ?", pondID).Order("data DESC").Limit(1).Find(&scans).Error
+	if err != nil || len(scans) == 0 {
+		return nil, err
+	}
+	return &scans[0], nil
+}
 
 // PostPondHealth
 func (r *PondHealthRepository) PostPondHealth(pond entities.PondHealth) error {
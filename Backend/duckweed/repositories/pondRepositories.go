@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+)
+
+type PondRepositoryInterface interface {
+	Create(pond *entities.Pond) error
+	FindByID(id uint) (*entities.Pond, error)
+	FindByUserID(userID uint) ([]entities.Pond, error)
+	Update(pond *entities.Pond, updates map[string]interface{}) error
+	Delete(pond *entities.Pond) error
+}
+
+type PondRepository struct {
+	db *gorm.DB
+}
+
+func NewPondRepository(db *gorm.DB) PondRepositoryInterface {
+	return &PondRepository{db: db}
+}
+
+func (r *PondRepository) Create(pond *entities.Pond) error {
+	return r.db.Create(pond).Error
+}
+
+func (r *PondRepository) FindByID(id uint) (*entities.Pond, error) {
+	var pond entities.Pond
+	err := r.db.First(&pond, id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pond, nil
+}
+
+func (r *PondRepository) FindByUserID(userID uint) ([]entities.Pond, error) {
+	var ponds []entities.Pond
+	err := r.db.Where("user_id = ?", userID).Order("name ASC").Find(&ponds).Error
+	return ponds, err
+}
+
+// Update sets the columns in updates, keyed by column name, and reloads the
+// pond.
+func (r *PondRepository) Update(pond *entities.Pond, updates map[string]interface{}) error {
+	if err := r.db.Model(pond).Updates(updates).Error; err != nil {
+		return err
+	}
+	return r.db.First(pond, pond.ID).Error
+}
+
+// Delete removes the pond and, in the same transaction, unassigns its boards
+// and health scans. The boards and scans themselves are kept.
+func (r *PondRepository) Delete(pond *entities.Pond) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entities.Board{}).
+			Where("pond_id = ?", pond.ID).
+			Update("pond_id", nil).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&entities.PondHealth{}).
+			Where("scanned_pond_id = ?", pond.ID).
+			Update("scanned_pond_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(pond).Error
+	})
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"main/duckweed/entities"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SensorLogRepositoryInterface interface {
+	FindByBoardIDInRange(boardID string, from, to time.Time, channels []entities.MetricEnum, limit int) ([]entities.SensorLog, error)
+	AggregateByBoardID(boardID string, from, to time.Time, bucket time.Duration, channels []entities.MetricEnum, includeSuspect bool) ([]entities.MeasurementBucketRow, error)
+	FindChannelsByBoardID(boardID string) ([]entities.MetricEnum, error)
+	FindLatestByBoardIDs(boardIDs []string) ([]entities.SensorLog, error)
+}
+
+type SensorLogRepository struct {
+	db *gorm.DB
+}
+
+func NewSensorLogRepository(db *gorm.DB) SensorLogRepositoryInterface {
+	return &SensorLogRepository{db}
+}
+
+// FindByBoardIDInRange returns the readings of a board with their
+// measurements, limited to the given channels when any are passed.
+func (r *SensorLogRepository) FindByBoardIDInRange(boardID string, from, to time.Time, channels []entities.MetricEnum, limit int) ([]entities.SensorLog, error) {
+	var logs []entities.SensorLog
+	err := r.db.
+		Preload("Measurements", func(db *gorm.DB) *gorm.DB {
+			if len(channels) > 0 {
+				db = db.Where("channel IN ?", channels)
+			}
+			return db.Order("channel ASC")
+		}).
+		Where("board_id = ? AND created_at >= ? AND created_at < ?", boardID, from, to).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+// AggregateByBoardID groups the measurements of a board into fixed-width
+// buckets aligned to the Unix epoch and returns min/avg/max per channel for
+// each one. Suspect readings are skipped unless includeSuspect is set.
+func (r *SensorLogRepository) AggregateByBoardID(boardID string, from, to time.Time, bucket time.Duration, channels []entities.MetricEnum, includeSuspect bool) ([]entities.MeasurementBucketRow, error) {
+	seconds := int64(bucket / time.Second)
+
+	query := r.db.Model(&entities.Measurement{}).
+		Select(`to_timestamp(floor(extract(epoch from created_at) / ?) * ?) AS bucket_start,
+			channel, count(*) AS count, min(value) AS min, avg(value) AS avg, max(value) AS max`, seconds, seconds).
+		Where("board_id = ? AND created_at >= ? AND created_at < ?", boardID, from, to)
+	if len(channels) > 0 {
+		query = query.Where("channel IN ?", channels)
+	}
+	if !includeSuspect {
+		query = query.Where("quality = ?", entities.QualityGood)
+	}
+
+	var rows []entities.MeasurementBucketRow
+	err := query.
+		Group("bucket_start, channel").
+		Order("bucket_start ASC, channel ASC").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// FindChannelsByBoardID lists every channel the board has ever reported.
+func (r *SensorLogRepository) FindChannelsByBoardID(boardID string) ([]entities.MetricEnum, error) {
+	var channels []entities.MetricEnum
+	err := r.db.Model(&entities.Measurement{}).
+		Where("board_id = ?", boardID).
+		Distinct("channel").
+		Order("channel ASC").
+		Pluck("channel", &channels).Error
+	return channels, err
+}
+
+// FindLatestByBoardIDs returns the most recent reading of each board with its
+// measurements. Boards that never sent one are left out.
+func (r *SensorLogRepository) FindLatestByBoardIDs(boardIDs []string) ([]entities.SensorLog, error) {
+	latest := r.db.Model(&entities.SensorLog{}).
+		Select("DISTINCT ON (board_id) id").
+		Where("board_id IN ?", boardIDs).
+		Order("board_id, created_at DESC")
+
+	var logs []entities.SensorLog
+	err := r.db.
+		Preload("Measurements", func(db *gorm.DB) *gorm.DB {
+			return db.Order("channel ASC")
+		}).
+		Where("id IN (?)", latest).
+		Find(&logs).Error
+	return logs, err
+}
@@ -22,5 +22,11 @@ func (r *SensorRepository) FindAll() ([]entities.Sensor, error) {
 func (r *SensorRepository) FindByID(id uint) (*entities.Sensor, error) {
 	var sensor entities.Sensor
 	err := r.db.First(&sensor, id).Error
-	return &sensor, err
-}
\ No newline at end of file
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sensor, nil
+}
@@ -72,3 +72,27 @@ func (r *UserRepository) CreateUser(username, email, password string) (*entities
 
 	return &user, err
 }
+
+// FindByEmailOrUserName returns the user with the email, or with the username
+// when email is empty. It returns nil, nil when there is no such user.
+func (r *UserRepository) FindByEmailOrUserName(email string, userName string) (*entities.User, error) {
+	var user entities.User
+	query := r.db.Where("user_name = ?", userName)
+	if email != "" {
+		query = r.db.Where("email = ?", email)
+	}
+	err := query.First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) FindByIDs(ids []uint) ([]entities.User, error) {
+	var users []entities.User
+	err := r.db.Where("user_id IN ?", ids).Find(&users).Error
+	return users, err
+}
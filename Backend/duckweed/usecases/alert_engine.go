@@ -0,0 +1,343 @@
+package usecases
+
+import (
+	"fmt"
+	"log"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"math"
+	"sync"
+	"time"
+)
+
+// alertRuleCacheTTL bounds how long rule edits made through the API take to
+// reach the engine that evaluates incoming telemetry.
+const alertRuleCacheTTL = 30 * time.Second
+
+// alertStateIdleTTL is how long the state of a board that sends no telemetry
+// is kept, so boards deleted or unclaimed through another replica do not
+// stay in memory.
+const alertStateIdleTTL = time.Hour
+
+type AlertEngineInterface interface {
+	Evaluate(boardID string, values map[entities.MetricEnum]float64, at time.Time)
+	// Forget drops the evaluation state of a board, for example once it was
+	// unclaimed. Open incidents are restored from the database if the board
+	// reports again.
+	Forget(boardID string)
+}
+
+// alertState is the per-rule evaluation state kept between readings.
+type alertState struct {
+	rule        entities.AlertRule
+	breachSince *time.Time
+	lastValue   float64
+	lastAt      time.Time
+	hasLast     bool
+	incident    *entities.AlertIncident
+}
+
+type boardAlertState struct {
+	loadedAt      time.Time
+	lastEvaluated time.Time
+	states        map[string]*alertState
+}
+
+// AlertEngine checks telemetry readings against the alert rules of a board and
+// opens or closes AlertIncident rows as limits are breached and recovered.
+// Boards without an explicit rule for a metric fall back to the Sensor
+// definition of the same type, using SensorThreshold as the upper limit.
+type AlertEngine struct {
+	alertRepo  repositories.AlertRepositoryInterface
+	sensorRepo *repositories.SensorRepository
+	notifier   AlertOutputPort
+	mutex      sync.Mutex
+	boards     map[string]*boardAlertState
+	prunedAt   time.Time
+}
+
+func NewAlertEngine(
+	alertRepo repositories.AlertRepositoryInterface,
+	sensorRepo *repositories.SensorRepository,
+	notifier AlertOutputPort,
+) AlertEngineInterface {
+	return &AlertEngine{
+		alertRepo:  alertRepo,
+		sensorRepo: sensorRepo,
+		notifier:   notifier,
+		boards:     make(map[string]*boardAlertState),
+	}
+}
+
+func (e *AlertEngine) Evaluate(boardID string, values map[entities.MetricEnum]float64, at time.Time) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.pruneIdle(time.Now())
+
+	board, err := e.loadBoard(boardID, at)
+	if err != nil {
+		log.Printf("Error loading alert rules for board %s: %v", boardID, err)
+		return
+	}
+	board.lastEvaluated = time.Now()
+
+	for _, state := range board.states {
+		value, ok := values[state.rule.Metric]
+		if !ok {
+			continue
+		}
+		e.evaluateRule(state, value, at)
+	}
+}
+
+func (e *AlertEngine) Forget(boardID string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	delete(e.boards, boardID)
+}
+
+// pruneIdle drops the state of boards that have not reported for
+// alertStateIdleTTL, checking at most once per alertRuleCacheTTL.
+func (e *AlertEngine) pruneIdle(now time.Time) {
+	if now.Sub(e.prunedAt) < alertRuleCacheTTL {
+		return
+	}
+	e.prunedAt = now
+	for boardID, board := range e.boards {
+		if now.Sub(board.lastEvaluated) > alertStateIdleTTL {
+			delete(e.boards, boardID)
+		}
+	}
+}
+
+func (e *AlertEngine) evaluateRule(state *alertState, value float64, at time.Time) {
+	rule := state.rule
+	// A backfilled reading older than the last one evaluated says nothing
+	// about the current state, and would distort the next rate.
+	if state.hasLast && at.Before(state.lastAt) {
+		return
+	}
+	rate := math.NaN()
+	if state.hasLast && at.After(state.lastAt) {
+		rate = math.Abs(value-state.lastValue) / at.Sub(state.lastAt).Minutes()
+	}
+	state.lastValue, state.lastAt, state.hasLast = value, at, true
+
+	if state.incident != nil {
+		trackPeak(state.incident, value)
+		if isCleared(rule, value, rate) {
+			e.closeIncident(state, at)
+		}
+		return
+	}
+
+	condition := breachCondition(rule, value, rate)
+	if condition == "" {
+		state.breachSince = nil
+		return
+	}
+	if state.breachSince == nil {
+		breachStart := at
+		state.breachSince = &breachStart
+	}
+	if at.Sub(*state.breachSince) < time.Duration(rule.HoldSeconds)*time.Second {
+		return
+	}
+
+	incident := &entities.AlertIncident{
+		BoardID:      rule.BoardID,
+		Metric:       rule.Metric,
+		Condition:    condition,
+		State:        entities.AlertIncidentOpen,
+		TriggerValue: value,
+		PeakValue:    value,
+		OpenedAt:     at,
+	}
+	if rule.ID != 0 {
+		ruleID := rule.ID
+		incident.AlertRuleID = &ruleID
+	}
+	if err := e.alertRepo.CreateIncident(incident); err != nil {
+		log.Printf("Failed to open %s alert for board %s: %v", rule.Metric, rule.BoardID, err)
+		return
+	}
+	state.incident = incident
+	state.breachSince = nil
+	log.Printf("Opened %s alert (%s) for board %s at value %.3f", rule.Metric, condition, rule.BoardID, value)
+
+	if e.notifier != nil {
+		e.notifier.BroadcastAlert(rule.BoardID, incident)
+	}
+}
+
+func (e *AlertEngine) closeIncident(state *alertState, at time.Time) {
+	incident := state.incident
+	closedAt := at
+	incident.State = entities.AlertIncidentClosed
+	incident.ClosedAt = &closedAt
+	if err := e.alertRepo.SaveIncident(incident); err != nil {
+		log.Printf("Failed to close alert %d for board %s: %v", incident.ID, incident.BoardID, err)
+		return
+	}
+	state.incident = nil
+	log.Printf("Closed %s alert %d for board %s", incident.Metric, incident.ID, incident.BoardID)
+
+	if e.notifier != nil {
+		e.notifier.BroadcastAlert(incident.BoardID, incident)
+	}
+}
+
+// loadBoard returns the evaluation state of a board, reloading its rules once
+// the cache has expired. Open incidents are restored from the database so a
+// restart does not open a duplicate for an ongoing breach.
+func (e *AlertEngine) loadBoard(boardID string, now time.Time) (*boardAlertState, error) {
+	board, ok := e.boards[boardID]
+	if ok && now.Sub(board.loadedAt) < alertRuleCacheTTL {
+		return board, nil
+	}
+
+	rules, err := e.rulesForBoard(boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		board = &boardAlertState{states: make(map[string]*alertState)}
+		openIncidents, err := e.alertRepo.FindOpenIncidentsByBoardID(boardID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range openIncidents {
+			incident := &openIncidents[i]
+			board.states[incidentKey(incident)] = &alertState{incident: incident}
+		}
+	}
+
+	active := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		key := ruleKey(rule)
+		active[key] = true
+		if state, exists := board.states[key]; exists {
+			state.rule = rule
+		} else {
+			board.states[key] = &alertState{rule: rule}
+		}
+	}
+
+	// Rules that were deleted or replaced close whatever incident they held.
+	for key, state := range board.states {
+		if active[key] {
+			continue
+		}
+		if state.incident != nil {
+			e.closeIncident(state, now)
+		}
+		if state.incident == nil {
+			delete(board.states, key)
+		}
+	}
+
+	board.loadedAt = now
+	e.boards[boardID] = board
+	return board, nil
+}
+
+func (e *AlertEngine) rulesForBoard(boardID string) ([]entities.AlertRule, error) {
+	rules, err := e.alertRepo.FindRulesByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	// A disabled rule still suppresses the sensor default for its metric.
+	covered := make(map[entities.MetricEnum]bool, len(rules))
+	enabled := rules[:0]
+	for _, rule := range rules {
+		covered[rule.Metric] = true
+		if rule.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	rules = enabled
+
+	if e.sensorRepo == nil {
+		return rules, nil
+	}
+	sensors, err := e.sensorRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, sensor := range sensors {
+		if sensor.SensorType == nil || sensor.SensorThreshold == nil {
+			continue
+		}
+		metric := entities.MetricEnum(*sensor.SensorType)
+		if covered[metric] {
+			continue
+		}
+		covered[metric] = true
+		threshold := *sensor.SensorThreshold
+		rules = append(rules, entities.AlertRule{
+			BoardID:  boardID,
+			Metric:   metric,
+			MaxValue: &threshold,
+			Enabled:  true,
+		})
+	}
+	return rules, nil
+}
+
+func breachCondition(rule entities.AlertRule, value, rate float64) entities.AlertConditionEnum {
+	switch {
+	case rule.MinValue != nil && value < *rule.MinValue:
+		return entities.AlertConditionBelowMin
+	case rule.MaxValue != nil && value > *rule.MaxValue:
+		return entities.AlertConditionAboveMax
+	case rule.MaxRatePerMinute != nil && !math.IsNaN(rate) && rate > *rule.MaxRatePerMinute:
+		return entities.AlertConditionRateOfChange
+	}
+	return ""
+}
+
+// isCleared reports whether the value is back inside the rule limits by at
+// least the rule's hysteresis band. While the rate is unknown, such as on the
+// first reading after a restart, a rule with a rate limit is not cleared.
+func isCleared(rule entities.AlertRule, value, rate float64) bool {
+	if rule.MinValue != nil && value < *rule.MinValue+rule.Hysteresis {
+		return false
+	}
+	if rule.MaxValue != nil && value > *rule.MaxValue-rule.Hysteresis {
+		return false
+	}
+	if rule.MaxRatePerMinute != nil && (math.IsNaN(rate) || rate > *rule.MaxRatePerMinute) {
+		return false
+	}
+	return true
+}
+
+func trackPeak(incident *entities.AlertIncident, value float64) {
+	switch incident.Condition {
+	case entities.AlertConditionBelowMin:
+		incident.PeakValue = math.Min(incident.PeakValue, value)
+	case entities.AlertConditionAboveMax:
+		incident.PeakValue = math.Max(incident.PeakValue, value)
+	default:
+		if math.Abs(value-incident.TriggerValue) > math.Abs(incident.PeakValue-incident.TriggerValue) {
+			incident.PeakValue = value
+		}
+	}
+}
+
+func ruleKey(rule entities.AlertRule) string {
+	if rule.ID == 0 {
+		return fmt.Sprintf("default:%s", rule.Metric)
+	}
+	return fmt.Sprintf("rule:%d", rule.ID)
+}
+
+func incidentKey(incident *entities.AlertIncident) string {
+	if incident.AlertRuleID == nil {
+		return fmt.Sprintf("default:%s", incident.Metric)
+	}
+	return fmt.Sprintf("rule:%d", *incident.AlertRuleID)
+}
@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+)
+
+const maxAlertIncidents = 200
+
+var (
+	ErrInvalidAlertRule  = errors.New("alert rule needs a min, max or rate limit, and min must be below max")
+	ErrAlertRuleNotFound = errors.New("alert rule not found")
+)
+
+type AlertUseCaseInterface interface {
+	CreateAlertRule(userID uint, boardID string, dto entities.InsertAlertRuleDto) (*entities.AlertRuleResponseDto, error)
+	GetAlertRules(userID uint, boardID string) ([]entities.AlertRuleResponseDto, error)
+	DeleteAlertRule(userID uint, boardID string, ruleID uint) error
+	GetAlertIncidents(userID uint, boardID string, state entities.AlertIncidentStateEnum) ([]entities.AlertIncidentResponseDto, error)
+}
+
+type AlertUseCase struct {
+	alertRepo             repositories.AlertRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+}
+
+func NewAlertUseCase(
+	alertRepo repositories.AlertRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+) AlertUseCaseInterface {
+	return &AlertUseCase{
+		alertRepo:             alertRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+	}
+}
+
+func (uc *AlertUseCase) CreateAlertRule(userID uint, boardID string, dto entities.InsertAlertRuleDto) (*entities.AlertRuleResponseDto, error) {
+	if !dto.Metric.IsValidChannel() {
+		return nil, ErrInvalidChannel
+	}
+	if dto.MinValue == nil && dto.MaxValue == nil && dto.MaxRatePerMinute == nil {
+		return nil, ErrInvalidAlertRule
+	}
+	if dto.MinValue != nil && dto.MaxValue != nil && *dto.MinValue >= *dto.MaxValue {
+		return nil, ErrInvalidAlertRule
+	}
+
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return nil, err
+	}
+
+	enabled := true
+	if dto.Enabled != nil {
+		enabled = *dto.Enabled
+	}
+	rule, err := uc.alertRepo.CreateRule(&entities.AlertRule{
+		BoardID:          boardID,
+		Metric:           dto.Metric,
+		MinValue:         dto.MinValue,
+		MaxValue:         dto.MaxValue,
+		MaxRatePerMinute: dto.MaxRatePerMinute,
+		HoldSeconds:      dto.HoldSeconds,
+		Hysteresis:       dto.Hysteresis,
+		Enabled:          enabled,
+	})
+	if err != nil {
+		return nil, err
+	}
+	response := toAlertRuleResponseDto(*rule)
+	return &response, nil
+}
+
+func (uc *AlertUseCase) GetAlertRules(userID uint, boardID string) ([]entities.AlertRuleResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	rules, err := uc.alertRepo.FindRulesByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.AlertRuleResponseDto, 0, len(rules))
+	for _, rule := range rules {
+		response = append(response, toAlertRuleResponseDto(rule))
+	}
+	return response, nil
+}
+
+func (uc *AlertUseCase) DeleteAlertRule(userID uint, boardID string, ruleID uint) error {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return err
+	}
+
+	rule, err := uc.alertRepo.FindRuleByID(ruleID)
+	if err != nil {
+		return err
+	}
+	if rule == nil || rule.BoardID != boardID {
+		return ErrAlertRuleNotFound
+	}
+	return uc.alertRepo.DeleteRule(ruleID)
+}
+
+func (uc *AlertUseCase) GetAlertIncidents(userID uint, boardID string, state entities.AlertIncidentStateEnum) ([]entities.AlertIncidentResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	incidents, err := uc.alertRepo.FindIncidentsByBoardID(boardID, state, maxAlertIncidents)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.AlertIncidentResponseDto, 0, len(incidents))
+	for _, incident := range incidents {
+		response = append(response, toAlertIncidentResponseDto(incident))
+	}
+	return response, nil
+}
+
+func toAlertRuleResponseDto(rule entities.AlertRule) entities.AlertRuleResponseDto {
+	return entities.AlertRuleResponseDto{
+		ID:               rule.ID,
+		BoardID:          rule.BoardID,
+		Metric:           rule.Metric,
+		MinValue:         rule.MinValue,
+		MaxValue:         rule.MaxValue,
+		MaxRatePerMinute: rule.MaxRatePerMinute,
+		HoldSeconds:      rule.HoldSeconds,
+		Hysteresis:       rule.Hysteresis,
+		Enabled:          rule.Enabled,
+	}
+}
+
+func toAlertIncidentResponseDto(incident entities.AlertIncident) entities.AlertIncidentResponseDto {
+	return entities.AlertIncidentResponseDto{
+		ID:           incident.ID,
+		BoardID:      incident.BoardID,
+		AlertRuleID:  incident.AlertRuleID,
+		Metric:       incident.Metric,
+		Condition:    incident.Condition,
+		State:        incident.State,
+		TriggerValue: incident.TriggerValue,
+		PeakValue:    incident.PeakValue,
+		OpenedAt:     incident.OpenedAt,
+		ClosedAt:     incident.ClosedAt,
+	}
+}
@@ -0,0 +1,133 @@
+package usecases
+
+import (
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"sort"
+)
+
+var (
+	ErrInvalidChannel  = errors.New("channel names use lowercase letters, digits and underscores, up to 32 characters")
+	ErrChannelNotFound = errors.New("channel mapping not found")
+	ErrSensorNotFound  = errors.New("sensor not found")
+)
+
+type BoardChannelUseCaseInterface interface {
+	GetChannels(userID uint, boardID string) ([]entities.ChannelInfoDto, error)
+	SetChannel(userID uint, boardID string, channel entities.MetricEnum, dto entities.InsertBoardChannelDto) (*entities.ChannelInfoDto, error)
+	DeleteChannel(userID uint, boardID string, channel entities.MetricEnum) error
+}
+
+type BoardChannelUseCase struct {
+	channelRepo           repositories.BoardChannelRepositoryInterface
+	sensorLogRepo         repositories.SensorLogRepositoryInterface
+	sensorRepo            *repositories.SensorRepository
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	channelResolver       ChannelResolverInterface
+}
+
+func NewBoardChannelUseCase(
+	channelRepo repositories.BoardChannelRepositoryInterface,
+	sensorLogRepo repositories.SensorLogRepositoryInterface,
+	sensorRepo *repositories.SensorRepository,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	channelResolver ChannelResolverInterface,
+) BoardChannelUseCaseInterface {
+	return &BoardChannelUseCase{
+		channelRepo:           channelRepo,
+		sensorLogRepo:         sensorLogRepo,
+		sensorRepo:            sensorRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		channelResolver:       channelResolver,
+	}
+}
+
+// GetChannels lists every channel the board has mapped or reported, with the
+// sensor definition each one resolves to.
+func (uc *BoardChannelUseCase) GetChannels(userID uint, boardID string) ([]entities.ChannelInfoDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	mapped, err := uc.channelRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	reported, err := uc.sensorLogRepo.FindChannelsByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[entities.MetricEnum]bool, len(mapped)+len(reported))
+	channels := make([]entities.MetricEnum, 0, len(mapped)+len(reported))
+	for _, mapping := range mapped {
+		if !seen[mapping.Channel] {
+			seen[mapping.Channel] = true
+			channels = append(channels, mapping.Channel)
+		}
+	}
+	for _, channel := range reported {
+		if !seen[channel] {
+			seen[channel] = true
+			channels = append(channels, channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+
+	return uc.channelResolver.Describe(boardID, channels)
+}
+
+func (uc *BoardChannelUseCase) SetChannel(userID uint, boardID string, channel entities.MetricEnum, dto entities.InsertBoardChannelDto) (*entities.ChannelInfoDto, error) {
+	if !channel.IsValidChannel() {
+		return nil, ErrInvalidChannel
+	}
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return nil, err
+	}
+
+	sensor, err := uc.sensorRepo.FindByID(*dto.SensorID)
+	if err != nil {
+		return nil, err
+	}
+	if sensor == nil {
+		return nil, ErrSensorNotFound
+	}
+
+	if _, err := uc.channelRepo.Upsert(&entities.BoardChannel{
+		BoardID:  boardID,
+		Channel:  channel,
+		SensorID: dto.SensorID,
+		Label:    dto.Label,
+	}); err != nil {
+		return nil, err
+	}
+
+	uc.channelResolver.Invalidate(boardID)
+	infos, err := uc.channelResolver.Describe(boardID, []entities.MetricEnum{channel})
+	if err != nil {
+		return nil, err
+	}
+	return &infos[0], nil
+}
+
+// DeleteChannel drops the explicit mapping so the channel falls back to the
+// sensor of the same type again.
+func (uc *BoardChannelUseCase) DeleteChannel(userID uint, boardID string, channel entities.MetricEnum) error {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return err
+	}
+
+	deleted, err := uc.channelRepo.Delete(boardID, channel)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrChannelNotFound
+	}
+	uc.channelResolver.Invalidate(boardID)
+	return nil
+}
@@ -0,0 +1,230 @@
+package usecases
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxBoardCommands = 100
+
+var (
+	ErrCommandNotFound           = errors.New("command not found")
+	ErrCommandChannelUnavailable = errors.New("command channel is not connected")
+)
+
+type BoardCommandUseCaseInterface interface {
+	SendCommand(userID uint, boardID string, dto entities.InsertBoardCommandDto) (*entities.BoardCommandResponseDto, error)
+	GetCommands(userID uint, boardID string) ([]entities.BoardCommandResponseDto, error)
+	GetCommand(userID uint, boardID string, correlationID string) (*entities.BoardCommandResponseDto, error)
+	HandleAck(boardID string, ack entities.BoardCommandAckDto) (*entities.BoardCommand, error)
+	ExpirePendingCommands(timeout time.Duration) (int64, error)
+}
+
+type BoardCommandUseCase struct {
+	commandRepo           repositories.BoardCommandRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	publisher             CommandPublisher
+}
+
+func NewBoardCommandUseCase(
+	commandRepo repositories.BoardCommandRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	publisher CommandPublisher,
+) BoardCommandUseCaseInterface {
+	return &BoardCommandUseCase{
+		commandRepo:           commandRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		publisher:             publisher,
+	}
+}
+
+func (uc *BoardCommandUseCase) SendCommand(userID uint, boardID string, dto entities.InsertBoardCommandDto) (*entities.BoardCommandResponseDto, error) {
+	if uc.publisher == nil {
+		return nil, ErrCommandChannelUnavailable
+	}
+
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return nil, err
+	}
+
+	command, err := dispatchCommand(uc.commandRepo, uc.publisher, userID, boardID, dto.Command, dto.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	response := toBoardCommandResponseDto(*command)
+	return &response, nil
+}
+
+func (uc *BoardCommandUseCase) GetCommands(userID uint, boardID string) ([]entities.BoardCommandResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	commands, err := uc.commandRepo.FindByBoardID(boardID, maxBoardCommands)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.BoardCommandResponseDto, 0, len(commands))
+	for _, command := range commands {
+		response = append(response, toBoardCommandResponseDto(command))
+	}
+	return response, nil
+}
+
+func (uc *BoardCommandUseCase) GetCommand(userID uint, boardID string, correlationID string) (*entities.BoardCommandResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	command, err := uc.commandRepo.FindByCorrelationID(correlationID)
+	if err != nil {
+		return nil, err
+	}
+	if command == nil || command.BoardID != boardID {
+		return nil, ErrCommandNotFound
+	}
+	response := toBoardCommandResponseDto(*command)
+	return &response, nil
+}
+
+// HandleAck records the outcome a board reported for one of its commands. Acks
+// for commands that already left the pending state are ignored.
+func (uc *BoardCommandUseCase) HandleAck(boardID string, ack entities.BoardCommandAckDto) (*entities.BoardCommand, error) {
+	command, err := uc.commandRepo.FindByCorrelationID(ack.CorrelationID)
+	if err != nil {
+		return nil, err
+	}
+	if command == nil || command.BoardID != boardID {
+		return nil, ErrCommandNotFound
+	}
+	if command.State != entities.CommandStatePending {
+		return command, nil
+	}
+
+	now := time.Now()
+	command.AckedAt = &now
+	if ack.Status == "ok" {
+		command.State = entities.CommandStateAcked
+	} else {
+		command.State = entities.CommandStateFailed
+		errorMessage := ack.Error
+		if errorMessage == "" {
+			errorMessage = fmt.Sprintf("board reported status %q", ack.Status)
+		}
+		command.ErrorMessage = &errorMessage
+	}
+	if len(ack.Result) > 0 && string(ack.Result) != "null" {
+		result := string(ack.Result)
+		command.Result = &result
+	}
+
+	// The command may have timed out since it was read; then the ack is
+	// ignored like any other late one.
+	recorded, err := uc.commandRepo.RecordAck(command)
+	if err != nil {
+		return nil, err
+	}
+	if !recorded {
+		command, err = uc.commandRepo.FindByCorrelationID(ack.CorrelationID)
+		if err != nil {
+			return nil, err
+		}
+		if command == nil {
+			return nil, ErrCommandNotFound
+		}
+	}
+	return command, nil
+}
+
+func (uc *BoardCommandUseCase) ExpirePendingCommands(timeout time.Duration) (int64, error) {
+	return uc.commandRepo.MarkTimedOut(time.Now().Add(-timeout))
+}
+
+// dispatchCommand stores a command and publishes it to the board. A failed
+// publish is recorded on the command, which is returned in the failed state.
+func dispatchCommand(
+	commandRepo repositories.BoardCommandRepositoryInterface,
+	publisher CommandPublisher,
+	userID uint,
+	boardID string,
+	name string,
+	params json.RawMessage,
+) (*entities.BoardCommand, error) {
+	message := entities.BoardCommandMessage{
+		CorrelationID: uuid.NewString(),
+		Command:       name,
+	}
+	command := &entities.BoardCommand{
+		CorrelationID: message.CorrelationID,
+		BoardID:       boardID,
+		UserID:        userID,
+		Command:       name,
+		State:         entities.CommandStatePending,
+	}
+	if len(params) > 0 && string(params) != "null" {
+		message.Params = params
+		value := string(params)
+		command.Params = &value
+	}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode command: %w", err)
+	}
+
+	// The row is written before publishing so a fast ack always finds it.
+	command, err = commandRepo.Create(command)
+	if err != nil {
+		return nil, fmt.Errorf("could not save command: %w", err)
+	}
+
+	// The board may ack before the outcome is written, so only the columns
+	// the publish decides are updated, and the row is read back.
+	if err := publisher.PublishCommand(boardID, payload); err != nil {
+		err = commandRepo.MarkPublishFailed(command.ID, err.Error())
+		if err != nil {
+			return nil, fmt.Errorf("could not update command state: %w", err)
+		}
+	} else if err := commandRepo.MarkSent(command.ID, time.Now()); err != nil {
+		return nil, fmt.Errorf("could not update command state: %w", err)
+	}
+
+	updated, err := commandRepo.FindByCorrelationID(command.CorrelationID)
+	if err != nil {
+		return nil, fmt.Errorf("could not load command: %w", err)
+	}
+	if updated == nil {
+		return nil, ErrCommandNotFound
+	}
+	return updated, nil
+}
+
+func toBoardCommandResponseDto(command entities.BoardCommand) entities.BoardCommandResponseDto {
+	dto := entities.BoardCommandResponseDto{
+		CorrelationID: command.CorrelationID,
+		BoardID:       command.BoardID,
+		Command:       command.Command,
+		State:         command.State,
+		SentAt:        command.SentAt,
+		AckedAt:       command.AckedAt,
+		ErrorMessage:  command.ErrorMessage,
+		CreatedAt:     command.CreatedAt,
+	}
+	if command.Params != nil {
+		dto.Params = json.RawMessage(*command.Params)
+	}
+	if command.Result != nil {
+		dto.Result = json.RawMessage(*command.Result)
+	}
+	return dto
+}
@@ -1,33 +1,45 @@
 package usecases
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"main/duckweed/entities"
 	"main/duckweed/repositories"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 type BoardRelationshipUseCaseInterface interface {
 	CreateBoardRelationship(dto entities.InsertBoardRelationshipDto) (*entities.BoardRelationshipResponseDto, error)
+	GetUserBoards(userID uint) ([]entities.UserBoardResponseDto, error)
+	DeleteBoardRelationship(userID uint, boardID string) (*entities.UnclaimBoardResponseDto, error)
 }
 
 type BoardRelationshipUseCase struct {
 	boardRepo             repositories.BoardRepositoryInterface
 	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	sensorLogRepo         repositories.SensorLogRepositoryInterface
+	ingestionPipeline     IngestionPipeline
+	accessNotifier        BoardAccessOutputPort
 }
 
 func NewBoardRelationshipUseCase(
 	boardRepo repositories.BoardRepositoryInterface,
 	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	sensorLogRepo repositories.SensorLogRepositoryInterface,
+	ingestionPipeline IngestionPipeline,
+	accessNotifier BoardAccessOutputPort,
 ) BoardRelationshipUseCaseInterface {
 	return &BoardRelationshipUseCase{
 		boardRepo:             boardRepo,
 		boardRelationshipRepo: boardRelationshipRepo,
+		sensorLogRepo:         sensorLogRepo,
+		ingestionPipeline:     ingestionPipeline,
+		accessNotifier:        accessNotifier,
 	}
 }
 
+// CreateBoardRelationship claims a provisioned board for the user. The claim
+// code is used up, so the board cannot be claimed again with the same code.
 func (uc *BoardRelationshipUseCase) CreateBoardRelationship(dto entities.InsertBoardRelationshipDto) (*entities.BoardRelationshipResponseDto, error) {
 	switch dto.ConMethod {
 	case entities.ConMethodManual, entities.ConMethodBluetooth:
@@ -39,37 +51,8 @@ func (uc *BoardRelationshipUseCase) CreateBoardRelationship(dto entities.InsertB
 	if err != nil {
 		return nil, fmt.Errorf("error checking for existing board: %w", err)
 	}
-
 	if board == nil {
-		var hashedPassword *string
-		if dto.ConPassword != nil && *dto.ConPassword != "" {
-			bytes, err := bcrypt.GenerateFromPassword([]byte(*dto.ConPassword), 14)
-			if err != nil {
-				return nil, fmt.Errorf("failed to hash password: %w", err)
-			}
-			pass := string(bytes)
-			hashedPassword = &pass
-		}
-
-		newBoard := &entities.Board{
-			BoardID:            dto.BoardID,
-			BoardName:          dto.BoardName,
-			ConPassword: hashedPassword,
-		}
-		board, err = uc.boardRepo.Create(newBoard)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create new board: %w", err)
-		}
-	} else {
-		if board.ConPassword != nil && *board.ConPassword != "" {
-			if dto.ConPassword == nil || *dto.ConPassword == "" {
-				return nil, errors.New("password is required for this board")
-			}
-			err := bcrypt.CompareHashAndPassword([]byte(*board.ConPassword), []byte(*dto.ConPassword))
-			if err != nil {
-				return nil, errors.New("invalid password") 
-			}
-		}
+		return nil, ErrBoardNotFound
 	}
 
 	existingRel, err := uc.boardRelationshipRepo.FindByBoardIDAndUserID(board.BoardID, dto.UserID)
@@ -80,25 +63,174 @@ func (uc *BoardRelationshipUseCase) CreateBoardRelationship(dto entities.InsertB
 		return nil, errors.New("a relationship for this user and board already exists")
 	}
 
+	if board.ClaimCodeHash == nil {
+		return nil, ErrBoardAlreadyClaimed
+	}
+	claimCodeHash := hashSecret(normalizeClaimCode(dto.ClaimCode))
+	if subtle.ConstantTimeCompare([]byte(claimCodeHash), []byte(*board.ClaimCodeHash)) != 1 {
+		return nil, ErrInvalidClaimCode
+	}
+
 	relationship := &entities.BoardRelationship{
 		UserID:    dto.UserID,
 		BoardID:   board.BoardID,
 		ConMethod: dto.ConMethod,
 		ConStatus: entities.ConStatusActive,
+		Role:      entities.BoardRoleOwner,
+	}
+
+	// The previous owner's broker password was dropped when they unclaimed
+	// the board, so its next owner gets a new one.
+	var mqttPassword, mqttPasswordHash *string
+	if board.MqttPasswordHash == nil {
+		password, err := generateMqttPassword()
+		if err != nil {
+			return nil, err
+		}
+		passwordHash := hashSecret(password)
+		mqttPassword, mqttPasswordHash = &password, &passwordHash
 	}
 
-	createdRelationship, err := uc.boardRelationshipRepo.Create(relationship)
+	claimed, err := uc.boardRelationshipRepo.Claim(relationship, claimCodeHash, dto.BoardName, mqttPasswordHash)
 	if err != nil {
 		return nil, fmt.Errorf("could not create board relationship: %w", err)
 	}
+	if !claimed {
+		// Someone else used the code between the check above and the claim,
+		// or the board still has an owner.
+		return nil, ErrBoardAlreadyClaimed
+	}
 
 	responseDto := &entities.BoardRelationshipResponseDto{
-		UserID:    createdRelationship.UserID,
-		BoardID:   createdRelationship.BoardID,
-		ConStatus: createdRelationship.ConStatus,
-		ConMethod: createdRelationship.ConMethod,
-		CreatedAt: createdRelationship.CreatedAt,
-		UpdatedAt: createdRelationship.UpdatedAt,
+		UserID:    relationship.UserID,
+		BoardID:   relationship.BoardID,
+		Role:      relationship.Role,
+		ConStatus: relationship.ConStatus,
+		ConMethod: relationship.ConMethod,
+		CreatedAt: relationship.CreatedAt,
+		UpdatedAt: relationship.UpdatedAt,
+	}
+	if mqttPassword != nil {
+		mqttUsername := board.BoardID
+		responseDto.MqttUsername = &mqttUsername
+		responseDto.MqttPassword = mqttPassword
 	}
 	return responseDto, nil
 }
+
+// GetUserBoards lists the boards the user is connected to, oldest connection
+// first, with each board's live status and latest reading. Disabled boards
+// and relationships are listed with their con_status and board_status.
+func (uc *BoardRelationshipUseCase) GetUserBoards(userID uint) ([]entities.UserBoardResponseDto, error) {
+	relationships, err := uc.boardRelationshipRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading board relationships: %w", err)
+	}
+	boardsResponse := make([]entities.UserBoardResponseDto, 0, len(relationships))
+	if len(relationships) == 0 {
+		return boardsResponse, nil
+	}
+
+	boardIDs := make([]string, 0, len(relationships))
+	for _, relationship := range relationships {
+		boardIDs = append(boardIDs, relationship.BoardID)
+	}
+
+	boards, err := uc.boardRepo.FindByBoardIDs(boardIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading boards: %w", err)
+	}
+	boardsByID := make(map[string]entities.Board, len(boards))
+	for _, board := range boards {
+		boardsByID[board.BoardID] = board
+	}
+
+	// A disabled relationship still lists the board, but without its
+	// readings.
+	readableBoardIDs := make([]string, 0, len(relationships))
+	for _, relationship := range relationships {
+		if relationship.Grants(entities.BoardRoleViewer) {
+			readableBoardIDs = append(readableBoardIDs, relationship.BoardID)
+		}
+	}
+	logs, err := uc.sensorLogRepo.FindLatestByBoardIDs(readableBoardIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading latest readings: %w", err)
+	}
+	latest := make(map[string]entities.SensorLogResponseDto, len(logs))
+	for _, log := range logs {
+		if log.BoardID != nil {
+			latest[*log.BoardID] = toSensorLogResponseDto(log, nil)
+		}
+	}
+
+	for _, relationship := range relationships {
+		board, ok := boardsByID[relationship.BoardID]
+		if !ok {
+			continue
+		}
+		boardResponse := entities.UserBoardResponseDto{
+			BoardResponseDto: toBoardResponseDto(board),
+			Role:             relationship.Role,
+			ConStatus:        relationship.ConStatus,
+			ConMethod:        relationship.ConMethod,
+			ConnectedAt:      relationship.CreatedAt,
+		}
+		if reading, ok := latest[board.BoardID]; ok {
+			boardResponse.LatestReading = &reading
+		}
+		boardsResponse = append(boardsResponse, boardResponse)
+	}
+	return boardsResponse, nil
+}
+
+// DeleteBoardRelationship disconnects the user from the board. Removing the
+// last relationship unclaims the board and issues it a new claim code, since
+// the old one was used up by the claim. Its MQTT password is dropped, as the
+// departing user may have kept it, and the next claimant gets a new one. The
+// owner can only leave a board other users still share after handing
+// ownership to one of them.
+func (uc *BoardRelationshipUseCase) DeleteBoardRelationship(userID uint, boardID string) (*entities.UnclaimBoardResponseDto, error) {
+	relationship, err := uc.boardRelationshipRepo.FindByBoardIDAndUserID(boardID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking board relationship: %w", err)
+	}
+	if relationship == nil {
+		board, err := uc.boardRepo.FindByBoardID(boardID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading board: %w", err)
+		}
+		if board == nil {
+			return nil, ErrBoardNotFound
+		}
+		return nil, ErrBoardAccessDenied
+	}
+	claimCode, err := generateClaimCode()
+	if err != nil {
+		return nil, err
+	}
+	unclaimed, err := uc.boardRelationshipRepo.Unclaim(relationship, hashSecret(normalizeClaimCode(claimCode)))
+	if errors.Is(err, repositories.ErrBoardStillShared) {
+		return nil, ErrOwnerMustTransfer
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not delete board relationship: %w", err)
+	}
+	if uc.accessNotifier != nil {
+		uc.accessNotifier.RevokeBoardAccess(userID, boardID)
+	}
+	if unclaimed && uc.ingestionPipeline != nil {
+		uc.ingestionPipeline.InvalidateBoard(boardID)
+	}
+
+	response := &entities.UnclaimBoardResponseDto{
+		BoardID:   boardID,
+		Unclaimed: unclaimed,
+	}
+	if unclaimed {
+		qrPayload := claimQRPayload(boardID, claimCode)
+		response.ClaimCode = &claimCode
+		response.QRPayload = &qrPayload
+	}
+	return response, nil
+}
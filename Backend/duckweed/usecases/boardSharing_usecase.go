@@ -0,0 +1,467 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"strings"
+	"time"
+)
+
+// invitationTTL is how long an invitation can be accepted.
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInviteeNotFound     = errors.New("no user with this email or username")
+	ErrInvalidInvitation   = errors.New("invalid invitation")
+	ErrInvalidBoardRole    = errors.New("invalid board role")
+	ErrInvitationNotFound  = errors.New("invitation not found")
+	ErrInvitationState     = errors.New("invitation is no longer pending")
+	ErrInvitationExists    = errors.New("user already has a pending invitation to this board")
+	ErrAlreadyBoardMember  = errors.New("user already has access to this board")
+	ErrBoardMemberNotFound = errors.New("user has no access to this board")
+	// ErrOwnerMustTransfer is returned when the owner would leave a shared
+	// board, or be demoted or removed, without handing ownership on.
+	ErrOwnerMustTransfer = errors.New("the owner must transfer ownership first")
+	// ErrBoardMemberDisabled is returned when ownership would go to a member
+	// whose access is disabled.
+	ErrBoardMemberDisabled = errors.New("this member's access to the board is disabled")
+)
+
+type BoardSharingUseCaseInterface interface {
+	InviteUser(userID uint, boardID string, dto entities.InsertBoardInvitationDto) (*entities.BoardInvitationResponseDto, error)
+	GetBoardInvitations(userID uint, boardID string) ([]entities.BoardInvitationResponseDto, error)
+	RevokeInvitation(userID uint, boardID string, invitationID uint) error
+	GetMyInvitations(userID uint) ([]entities.BoardInvitationResponseDto, error)
+	AcceptInvitation(userID uint, invitationID uint) (*entities.BoardRelationshipResponseDto, error)
+	DeclineInvitation(userID uint, invitationID uint) error
+	GetBoardMembers(userID uint, boardID string) ([]entities.BoardMemberResponseDto, error)
+	UpdateMemberRole(userID uint, boardID string, memberUserID uint, dto entities.UpdateBoardMemberDto) (*entities.BoardMemberResponseDto, error)
+	RemoveMember(userID uint, boardID string, memberUserID uint) error
+	DisableMember(userID uint, boardID string, memberUserID uint) (*entities.BoardMemberResponseDto, error)
+	EnableMember(userID uint, boardID string, memberUserID uint) (*entities.BoardMemberResponseDto, error)
+	TransferOwnership(userID uint, boardID string, dto entities.TransferBoardOwnershipDto) error
+}
+
+// BoardSharingUseCase lets the owner of a board share it with other users as
+// operators or viewers. Access is granted by invitation, so nobody gets a
+// board without accepting it.
+type BoardSharingUseCase struct {
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	invitationRepo        repositories.BoardInvitationRepositoryInterface
+	userRepo              repositories.UserRepository
+	accessNotifier        BoardAccessOutputPort
+}
+
+func NewBoardSharingUseCase(
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	invitationRepo repositories.BoardInvitationRepositoryInterface,
+	userRepo repositories.UserRepository,
+	accessNotifier BoardAccessOutputPort,
+) BoardSharingUseCaseInterface {
+	return &BoardSharingUseCase{
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		invitationRepo:        invitationRepo,
+		userRepo:              userRepo,
+		accessNotifier:        accessNotifier,
+	}
+}
+
+func (uc *BoardSharingUseCase) InviteUser(userID uint, boardID string, dto entities.InsertBoardInvitationDto) (*entities.BoardInvitationResponseDto, error) {
+	if dto.Role != entities.BoardRoleOperator && dto.Role != entities.BoardRoleViewer {
+		return nil, fmt.Errorf("%w: role must be operator or viewer", ErrInvalidBoardRole)
+	}
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner); err != nil {
+		return nil, err
+	}
+
+	var email, userName string
+	if dto.Email != nil {
+		email = strings.TrimSpace(*dto.Email)
+	}
+	if dto.Username != nil {
+		userName = strings.TrimSpace(*dto.Username)
+	}
+	if email == "" && userName == "" {
+		return nil, fmt.Errorf("%w: email or username is required", ErrInvalidInvitation)
+	}
+	invitee, err := uc.userRepo.FindByEmailOrUserName(email, userName)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up invitee: %w", err)
+	}
+	if invitee == nil || invitee.UserID == nil {
+		return nil, ErrInviteeNotFound
+	}
+	inviteeUserID := *invitee.UserID
+	if inviteeUserID == userID {
+		return nil, fmt.Errorf("%w: you cannot invite yourself", ErrInvalidInvitation)
+	}
+
+	member, err := uc.boardRelationshipRepo.FindByBoardIDAndUserID(boardID, inviteeUserID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking board relationship: %w", err)
+	}
+	if member != nil {
+		return nil, ErrAlreadyBoardMember
+	}
+
+	now := time.Now()
+	pending, err := uc.invitationRepo.FindPendingByBoardIDAndInvitee(boardID, inviteeUserID, now)
+	if err != nil {
+		return nil, fmt.Errorf("error checking pending invitations: %w", err)
+	}
+	if pending != nil {
+		return nil, ErrInvitationExists
+	}
+
+	invitation := &entities.BoardInvitation{
+		BoardID:         boardID,
+		InvitedByUserID: userID,
+		InviteeUserID:   inviteeUserID,
+		Role:            dto.Role,
+		Status:          entities.InvitationStatusPending,
+		ExpiresAt:       now.Add(invitationTTL),
+	}
+	if err := uc.invitationRepo.Create(invitation); err != nil {
+		return nil, fmt.Errorf("could not create invitation: %w", err)
+	}
+
+	response := toBoardInvitationResponseDto(*invitation, nil)
+	return &response, nil
+}
+
+func (uc *BoardSharingUseCase) GetBoardInvitations(userID uint, boardID string) ([]entities.BoardInvitationResponseDto, error) {
+	board, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	invitations, err := uc.invitationRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.BoardInvitationResponseDto, 0, len(invitations))
+	for _, invitation := range invitations {
+		response = append(response, toBoardInvitationResponseDto(invitation, board.BoardName))
+	}
+	return response, nil
+}
+
+func (uc *BoardSharingUseCase) RevokeInvitation(userID uint, boardID string, invitationID uint) error {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner); err != nil {
+		return err
+	}
+
+	invitation, err := uc.invitationRepo.FindByID(invitationID)
+	if err != nil {
+		return err
+	}
+	if invitation == nil || invitation.BoardID != boardID {
+		return ErrInvitationNotFound
+	}
+
+	revoked, err := uc.invitationRepo.UpdateStatus(invitation.ID, entities.InvitationStatusPending, entities.InvitationStatusRevoked)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInvitationState
+	}
+	return nil
+}
+
+// GetMyInvitations lists the invitations the user can still accept.
+func (uc *BoardSharingUseCase) GetMyInvitations(userID uint) ([]entities.BoardInvitationResponseDto, error) {
+	invitations, err := uc.invitationRepo.FindPendingByInvitee(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.BoardInvitationResponseDto, 0, len(invitations))
+	if len(invitations) == 0 {
+		return response, nil
+	}
+
+	boardIDs := make([]string, 0, len(invitations))
+	for _, invitation := range invitations {
+		boardIDs = append(boardIDs, invitation.BoardID)
+	}
+	boards, err := uc.boardRepo.FindByBoardIDs(boardIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading boards: %w", err)
+	}
+	boardNames := make(map[string]*string, len(boards))
+	for _, board := range boards {
+		boardNames[board.BoardID] = board.BoardName
+	}
+
+	for _, invitation := range invitations {
+		response = append(response, toBoardInvitationResponseDto(invitation, boardNames[invitation.BoardID]))
+	}
+	return response, nil
+}
+
+// AcceptInvitation gives the user the role the invitation offers.
+func (uc *BoardSharingUseCase) AcceptInvitation(userID uint, invitationID uint) (*entities.BoardRelationshipResponseDto, error) {
+	invitation, err := uc.findOwnInvitation(userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := uc.boardRelationshipRepo.FindByBoardIDAndUserID(invitation.BoardID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking board relationship: %w", err)
+	}
+	if member != nil {
+		return nil, ErrAlreadyBoardMember
+	}
+
+	relationship := &entities.BoardRelationship{
+		UserID:    userID,
+		BoardID:   invitation.BoardID,
+		ConMethod: entities.ConMethodManual,
+		ConStatus: entities.ConStatusActive,
+		Role:      invitation.Role,
+	}
+	accepted, err := uc.invitationRepo.Accept(invitation, relationship)
+	if err != nil {
+		return nil, fmt.Errorf("could not accept invitation: %w", err)
+	}
+	if !accepted {
+		// Revoked or expired between the check above and the update.
+		return nil, ErrInvitationState
+	}
+
+	return &entities.BoardRelationshipResponseDto{
+		BoardID:   relationship.BoardID,
+		UserID:    relationship.UserID,
+		Role:      relationship.Role,
+		ConStatus: relationship.ConStatus,
+		ConMethod: relationship.ConMethod,
+		CreatedAt: relationship.CreatedAt,
+		UpdatedAt: relationship.UpdatedAt,
+	}, nil
+}
+
+func (uc *BoardSharingUseCase) DeclineInvitation(userID uint, invitationID uint) error {
+	invitation, err := uc.findOwnInvitation(userID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	declined, err := uc.invitationRepo.UpdateStatus(invitation.ID, entities.InvitationStatusPending, entities.InvitationStatusDeclined)
+	if err != nil {
+		return err
+	}
+	if !declined {
+		return ErrInvitationState
+	}
+	return nil
+}
+
+// findOwnInvitation loads a pending invitation addressed to the user. Other
+// users' invitations are reported as not found. An invitation found past its
+// expiry is marked expired.
+func (uc *BoardSharingUseCase) findOwnInvitation(userID uint, invitationID uint) (*entities.BoardInvitation, error) {
+	invitation, err := uc.invitationRepo.FindByID(invitationID)
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || invitation.InviteeUserID != userID {
+		return nil, ErrInvitationNotFound
+	}
+	if invitation.Status != entities.InvitationStatusPending {
+		return nil, ErrInvitationState
+	}
+	if !time.Now().Before(invitation.ExpiresAt) {
+		if _, err := uc.invitationRepo.UpdateStatus(invitation.ID, entities.InvitationStatusPending, entities.InvitationStatusExpired); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvitationState
+	}
+	return invitation, nil
+}
+
+func (uc *BoardSharingUseCase) GetBoardMembers(userID uint, boardID string) ([]entities.BoardMemberResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	relationships, err := uc.boardRelationshipRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, 0, len(relationships))
+	for _, relationship := range relationships {
+		userIDs = append(userIDs, relationship.UserID)
+	}
+	users, err := uc.userRepo.FindByIDs(userIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading users: %w", err)
+	}
+	usersByID := make(map[uint]entities.User, len(users))
+	for _, user := range users {
+		if user.UserID != nil {
+			usersByID[*user.UserID] = user
+		}
+	}
+
+	members := make([]entities.BoardMemberResponseDto, 0, len(relationships))
+	for _, relationship := range relationships {
+		members = append(members, toBoardMemberResponseDto(relationship, usersByID[relationship.UserID]))
+	}
+	return members, nil
+}
+
+// UpdateMemberRole makes another member an operator or a viewer. The owner's
+// own role only changes through TransferOwnership.
+func (uc *BoardSharingUseCase) UpdateMemberRole(userID uint, boardID string, memberUserID uint, dto entities.UpdateBoardMemberDto) (*entities.BoardMemberResponseDto, error) {
+	if dto.Role != entities.BoardRoleOperator && dto.Role != entities.BoardRoleViewer {
+		return nil, fmt.Errorf("%w: role must be operator or viewer", ErrInvalidBoardRole)
+	}
+	member, err := uc.findMember(userID, boardID, memberUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.Role != dto.Role {
+		if err := uc.boardRelationshipRepo.UpdateRole(member, dto.Role); err != nil {
+			return nil, fmt.Errorf("could not update role: %w", err)
+		}
+	}
+
+	return uc.memberResponse(member)
+}
+
+// DisableMember suspends another member's access to the board. The member
+// keeps their role and sees the board in their list as disabled, and the
+// other members are not affected.
+func (uc *BoardSharingUseCase) DisableMember(userID uint, boardID string, memberUserID uint) (*entities.BoardMemberResponseDto, error) {
+	return uc.setMemberConStatus(userID, boardID, memberUserID, entities.ConStatusDisabled)
+}
+
+// EnableMember gives a disabled member their access back.
+func (uc *BoardSharingUseCase) EnableMember(userID uint, boardID string, memberUserID uint) (*entities.BoardMemberResponseDto, error) {
+	return uc.setMemberConStatus(userID, boardID, memberUserID, entities.ConStatusActive)
+}
+
+func (uc *BoardSharingUseCase) setMemberConStatus(userID uint, boardID string, memberUserID uint, status entities.ConStatusEnum) (*entities.BoardMemberResponseDto, error) {
+	member, err := uc.findMember(userID, boardID, memberUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if member.ConStatus != status {
+		if err := uc.boardRelationshipRepo.UpdateConStatus(member, status); err != nil {
+			return nil, fmt.Errorf("could not update member status: %w", err)
+		}
+	}
+	if status == entities.ConStatusDisabled {
+		uc.revokeAccess(memberUserID, boardID)
+	}
+	return uc.memberResponse(member)
+}
+
+func (uc *BoardSharingUseCase) memberResponse(member *entities.BoardRelationship) (*entities.BoardMemberResponseDto, error) {
+	user, err := uc.userRepo.FindByID(member.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+	response := toBoardMemberResponseDto(*member, *user)
+	return &response, nil
+}
+
+// RemoveMember revokes another member's access to the board.
+func (uc *BoardSharingUseCase) RemoveMember(userID uint, boardID string, memberUserID uint) error {
+	member, err := uc.findMember(userID, boardID, memberUserID)
+	if err != nil {
+		return err
+	}
+	if err := uc.boardRelationshipRepo.Delete(member); err != nil {
+		return err
+	}
+	uc.revokeAccess(memberUserID, boardID)
+	return nil
+}
+
+func (uc *BoardSharingUseCase) revokeAccess(userID uint, boardID string) {
+	if uc.accessNotifier != nil {
+		uc.accessNotifier.RevokeBoardAccess(userID, boardID)
+	}
+}
+
+// findMember authorizes the owner and loads the relationship of another
+// member of the board. Another owner is never a member an owner may act on.
+func (uc *BoardSharingUseCase) findMember(userID uint, boardID string, memberUserID uint) (*entities.BoardRelationship, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner); err != nil {
+		return nil, err
+	}
+	if memberUserID == userID {
+		return nil, ErrOwnerMustTransfer
+	}
+
+	member, err := uc.boardRelationshipRepo.FindByBoardIDAndUserID(boardID, memberUserID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking board relationship: %w", err)
+	}
+	if member == nil {
+		return nil, ErrBoardMemberNotFound
+	}
+	if member.Role == entities.BoardRoleOwner {
+		return nil, ErrBoardRoleForbidden
+	}
+	return member, nil
+}
+
+// TransferOwnership hands the board to another member. The previous owner
+// stays on the board as an operator.
+func (uc *BoardSharingUseCase) TransferOwnership(userID uint, boardID string, dto entities.TransferBoardOwnershipDto) error {
+	if dto.UserID == userID {
+		return fmt.Errorf("%w: you already own this board", ErrInvalidBoardRole)
+	}
+	member, err := uc.findMember(userID, boardID, dto.UserID)
+	if err != nil {
+		return err
+	}
+	if member.ConStatus == entities.ConStatusDisabled {
+		return ErrBoardMemberDisabled
+	}
+
+	transferred, err := uc.boardRelationshipRepo.TransferOwnership(boardID, userID, dto.UserID)
+	if err != nil {
+		return fmt.Errorf("could not transfer ownership: %w", err)
+	}
+	if !transferred {
+		// Ownership or membership changed since the checks above.
+		return ErrBoardRoleForbidden
+	}
+	return nil
+}
+
+func toBoardInvitationResponseDto(invitation entities.BoardInvitation, boardName *string) entities.BoardInvitationResponseDto {
+	return entities.BoardInvitationResponseDto{
+		ID:              invitation.ID,
+		BoardID:         invitation.BoardID,
+		BoardName:       boardName,
+		InvitedByUserID: invitation.InvitedByUserID,
+		InviteeUserID:   invitation.InviteeUserID,
+		Role:            invitation.Role,
+		Status:          invitation.Status,
+		ExpiresAt:       invitation.ExpiresAt,
+		RespondedAt:     invitation.RespondedAt,
+		CreatedAt:       invitation.CreatedAt,
+	}
+}
+
+func toBoardMemberResponseDto(relationship entities.BoardRelationship, user entities.User) entities.BoardMemberResponseDto {
+	return entities.BoardMemberResponseDto{
+		UserID:      relationship.UserID,
+		UserName:    user.UserName,
+		Role:        relationship.Role,
+		ConStatus:   relationship.ConStatus,
+		ConnectedAt: relationship.CreatedAt,
+	}
+}
@@ -0,0 +1,244 @@
+package usecases
+
+import (
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"time"
+)
+
+const (
+	maxStatusHistoryRange = 366 * 24 * time.Hour
+	// maxStatusTransitions bounds the rows read for one request. A range
+	// with more changes than this has to be narrowed.
+	maxStatusTransitions = 20000
+	uptimePrecision      = 2
+)
+
+// ErrTooManyTransitions is returned when the requested range holds more
+// status changes than are read at once.
+var ErrTooManyTransitions = errors.New("too many status changes in range, narrow the range")
+
+type BoardStatusUseCaseInterface interface {
+	GetStatusHistory(userID uint, boardID string, from, to time.Time, interval entities.UptimeIntervalEnum) (*entities.BoardStatusHistoryResponseDto, error)
+}
+
+type BoardStatusUseCase struct {
+	boardStatusRepo       repositories.BoardStatusRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	pondRepo              repositories.PondRepositoryInterface
+}
+
+func NewBoardStatusUseCase(
+	boardStatusRepo repositories.BoardStatusRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	pondRepo repositories.PondRepositoryInterface,
+) BoardStatusUseCaseInterface {
+	return &BoardStatusUseCase{
+		boardStatusRepo:       boardStatusRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		pondRepo:              pondRepo,
+	}
+}
+
+// GetStatusHistory returns the status changes of a board in [from, to) and its
+// uptime per day or week. Days and weeks start at midnight, and weeks on
+// Monday, in the timezone of the board's pond.
+func (uc *BoardStatusUseCase) GetStatusHistory(userID uint, boardID string, from, to time.Time, interval entities.UptimeIntervalEnum) (*entities.BoardStatusHistoryResponseDto, error) {
+	if !from.Before(to) || to.Sub(from) > maxStatusHistoryRange {
+		return nil, ErrInvalidTimeRange
+	}
+	if interval != entities.UptimeIntervalDay && interval != entities.UptimeIntervalWeek {
+		return nil, ErrInvalidInterval
+	}
+
+	board, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	location, err := uc.boardLocation(board)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions, err := uc.boardStatusRepo.FindByBoardIDInRange(boardID, from, to, maxStatusTransitions+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(transitions) > maxStatusTransitions {
+		return nil, ErrTooManyTransitions
+	}
+	previous, err := uc.boardStatusRepo.FindLastBefore(boardID, from)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &entities.BoardStatusHistoryResponseDto{
+		BoardID:     boardID,
+		From:        from,
+		To:          to,
+		Interval:    interval,
+		Timezone:    location.String(),
+		Transitions: make([]entities.BoardStatusTransitionDto, 0, len(transitions)),
+	}
+	if previous != nil {
+		status := previous.Status
+		response.InitialStatus = &status
+	}
+	for _, transition := range transitions {
+		response.Transitions = append(response.Transitions, entities.BoardStatusTransitionDto{
+			FromStatus: transition.FromStatus,
+			Status:     transition.Status,
+			Cause:      transition.Cause,
+			ChangedAt:  transition.ChangedAt,
+		})
+	}
+
+	response.Uptime, response.UptimePercent = computeUptime(response.InitialStatus, transitions, from, to, time.Now(), interval, location)
+	return response, nil
+}
+
+// boardLocation is the timezone of the board's pond, or UTC.
+func (uc *BoardStatusUseCase) boardLocation(board *entities.Board) (*time.Location, error) {
+	if board.PondID == nil {
+		return time.UTC, nil
+	}
+	pond, err := uc.pondRepo.FindByID(*board.PondID)
+	if err != nil {
+		return nil, err
+	}
+	if pond == nil {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(pond.Timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return location, nil
+}
+
+// computeUptime splits [from, to) into buckets and adds up the time the board
+// was active in each. The board's status is taken to hold from one change to
+// the next; time before the first known status, after now, or while the
+// board was disabled is not observed.
+func computeUptime(
+	initial *entities.BoardStatusEnum,
+	transitions []entities.BoardStatus,
+	from, to, now time.Time,
+	interval entities.UptimeIntervalEnum,
+	location *time.Location,
+) ([]entities.UptimeBucketDto, *float64) {
+	buckets := uptimeBuckets(from, to, interval, location)
+	end := to
+	if now.Before(end) {
+		end = now
+	}
+
+	var totalOnline, totalObserved int64
+	index := 0
+	addSegment := func(start, stop time.Time, status *entities.BoardStatusEnum) {
+		if status == nil || *status == entities.BoardStatusDisabled {
+			return
+		}
+		if stop.After(end) {
+			stop = end
+		}
+		for index < len(buckets) && !buckets[index].BucketEnd.After(start) {
+			index++
+		}
+		for i := index; i < len(buckets) && buckets[i].BucketStart.Before(stop); i++ {
+			overlapStart := maxTime(start, buckets[i].BucketStart, from)
+			overlapEnd := minTime(stop, buckets[i].BucketEnd)
+			if !overlapStart.Before(overlapEnd) {
+				continue
+			}
+			seconds := int64(overlapEnd.Sub(overlapStart) / time.Second)
+			buckets[i].ObservedSeconds += seconds
+			totalObserved += seconds
+			if *status == entities.BoardStatusActive {
+				buckets[i].OnlineSeconds += seconds
+				totalOnline += seconds
+			}
+		}
+	}
+
+	status := initial
+	segmentStart := from
+	for _, transition := range transitions {
+		addSegment(segmentStart, transition.ChangedAt, status)
+		next := transition.Status
+		status = &next
+		segmentStart = transition.ChangedAt
+
+		if transition.Status == entities.BoardStatusInactive &&
+			transition.FromStatus != nil && *transition.FromStatus == entities.BoardStatusActive {
+			for i := range buckets {
+				if !transition.ChangedAt.Before(buckets[i].BucketStart) && transition.ChangedAt.Before(buckets[i].BucketEnd) {
+					buckets[i].Disconnects++
+					break
+				}
+			}
+		}
+	}
+	if segmentStart.Before(end) {
+		addSegment(segmentStart, end, status)
+	}
+
+	for i := range buckets {
+		buckets[i].UptimePercent = uptimePercent(buckets[i].OnlineSeconds, buckets[i].ObservedSeconds)
+	}
+	return buckets, uptimePercent(totalOnline, totalObserved)
+}
+
+// uptimeBuckets returns the days or weeks covering [from, to), the first and
+// last cut to the range.
+func uptimeBuckets(from, to time.Time, interval entities.UptimeIntervalEnum, location *time.Location) []entities.UptimeBucketDto {
+	local := from.In(location)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	days := 1
+	if interval == entities.UptimeIntervalWeek {
+		days = 7
+		// Go counts weekdays from Sunday; weeks here start on Monday.
+		start = start.AddDate(0, 0, -((int(start.Weekday()) + 6) % 7))
+	}
+
+	var buckets []entities.UptimeBucketDto
+	for start.Before(to) {
+		// AddDate keeps midnight across daylight saving changes.
+		next := start.AddDate(0, 0, days)
+		buckets = append(buckets, entities.UptimeBucketDto{
+			BucketStart: maxTime(start, from),
+			BucketEnd:   minTime(next, to),
+		})
+		start = next
+	}
+	return buckets
+}
+
+func uptimePercent(online, observed int64) *float64 {
+	if observed <= 0 {
+		return nil
+	}
+	precision := uptimePrecision
+	percent := roundToPrecision(float64(online)*100/float64(observed), &precision)
+	return &percent
+}
+
+func maxTime(first time.Time, rest ...time.Time) time.Time {
+	for _, t := range rest {
+		if t.After(first) {
+			first = t
+		}
+	}
+	return first
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,41 @@
+package usecases
+
+import (
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+)
+
+// authorizeBoardAccess loads the board and makes sure the user has an enabled
+// relationship with it, with at least the required role, before any
+// board-scoped data is returned or changed.
+func authorizeBoardAccess(
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	userID uint,
+	boardID string,
+	required entities.BoardRoleEnum,
+) (*entities.Board, error) {
+	board, err := boardRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading board: %w", err)
+	}
+	if board == nil {
+		return nil, ErrBoardNotFound
+	}
+
+	relationship, err := boardRelationshipRepo.FindByBoardIDAndUserID(boardID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error checking board relationship: %w", err)
+	}
+	if relationship == nil {
+		return nil, ErrBoardAccessDenied
+	}
+	if relationship.ConStatus == entities.ConStatusDisabled {
+		return nil, ErrBoardAccessDisabled
+	}
+	if !relationship.Role.Allows(required) {
+		return nil, ErrBoardRoleForbidden
+	}
+	return board, nil
+}
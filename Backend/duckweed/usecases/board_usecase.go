@@ -1,23 +1,41 @@
 package usecases
 
 import (
+	"fmt"
 	"main/duckweed/entities"
 	"main/duckweed/repositories"
+	"time"
 )
 
 type BoardUseCaseInterface interface {
 	CreateBoard(dto entities.InsertBoardDto) (*entities.Board, error)
 	GetAllBoards() ([]entities.Board, error)
 	GetBoardByID(id uint) (*entities.Board, error)
-	GetBoardByBoardID(boardID string) (*entities.Board, error) 
+	GetBoardByBoardID(userID uint, boardID string) (*entities.BoardResponseDto, error)
+	UpdateBoard(userID uint, boardID string, dto entities.UpdateBoardDto) (*entities.BoardResponseDto, error)
+	DisableBoard(userID uint, boardID string, dto entities.DisableBoardDto) (*entities.BoardResponseDto, error)
+	EnableBoard(userID uint, boardID string) (*entities.BoardResponseDto, error)
 }
 
 type BoardUseCase struct {
-	repo repositories.BoardRepositoryInterface
+	repo                  repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	ingestionPipeline     IngestionPipeline
+	statusNotifier        BoardStatusOutputPort
 }
 
-func NewBoardUseCase(repo repositories.BoardRepositoryInterface) BoardUseCaseInterface {
-	return &BoardUseCase{repo}
+func NewBoardUseCase(
+	repo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	ingestionPipeline IngestionPipeline,
+	statusNotifier BoardStatusOutputPort,
+) BoardUseCaseInterface {
+	return &BoardUseCase{
+		repo:                  repo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		ingestionPipeline:     ingestionPipeline,
+		statusNotifier:        statusNotifier,
+	}
 }
 
 func (uc *BoardUseCase) CreateBoard(dto entities.InsertBoardDto) (*entities.Board, error) {
@@ -36,6 +54,105 @@ func (uc *BoardUseCase) GetBoardByID(id uint) (*entities.Board, error) {
 	return uc.repo.FindByID(id)
 }
 
-func (uc *BoardUseCase) GetBoardByBoardID(boardID string) (*entities.Board, error) {
-	return uc.repo.FindByBoardID(boardID)
+// GetBoardByBoardID returns a board the user may view.
+func (uc *BoardUseCase) GetBoardByBoardID(userID uint, boardID string) (*entities.BoardResponseDto, error) {
+	board, err := authorizeBoardAccess(uc.repo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	response := toBoardResponseDto(*board)
+	return &response, nil
+}
+
+// UpdateBoard renames the board or changes its location and notes for a user
+// connected to it.
+func (uc *BoardUseCase) UpdateBoard(userID uint, boardID string, dto entities.UpdateBoardDto) (*entities.BoardResponseDto, error) {
+	board, err := authorizeBoardAccess(uc.repo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{}, 3)
+	for column, value := range map[string]*string{
+		"board_name": dto.BoardName,
+		"location":   dto.Location,
+		"notes":      dto.Notes,
+	} {
+		if value != nil {
+			updates[column] = trimmedOrNil(value)
+		}
+	}
+	if len(updates) > 0 {
+		if err := uc.repo.UpdateDetails(boardID, updates); err != nil {
+			return nil, fmt.Errorf("could not update board: %w", err)
+		}
+		if board, err = uc.repo.FindByBoardID(boardID); err != nil {
+			return nil, fmt.Errorf("error loading board: %w", err)
+		}
+		if board == nil {
+			return nil, ErrBoardNotFound
+		}
+	}
+
+	response := toBoardResponseDto(*board)
+	return &response, nil
+}
+
+// DisableBoard takes the board out of service for every user until its owner
+// enables it again. Its telemetry is quarantined as dead letters meanwhile.
+func (uc *BoardUseCase) DisableBoard(userID uint, boardID string, dto entities.DisableBoardDto) (*entities.BoardResponseDto, error) {
+	return uc.setDisabled(userID, boardID, true, trimmedOrNil(dto.Reason))
+}
+
+// EnableBoard puts a disabled board back in service. It shows as inactive
+// until it reports again.
+func (uc *BoardUseCase) EnableBoard(userID uint, boardID string) (*entities.BoardResponseDto, error) {
+	return uc.setDisabled(userID, boardID, false, nil)
+}
+
+func (uc *BoardUseCase) setDisabled(userID uint, boardID string, disabled bool, reason *string) (*entities.BoardResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.repo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner); err != nil {
+		return nil, err
+	}
+
+	board, err := uc.repo.SetDisabled(boardID, disabled, reason, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("could not update board status: %w", err)
+	}
+	if board == nil {
+		return nil, ErrBoardNotFound
+	}
+	if uc.ingestionPipeline != nil {
+		uc.ingestionPipeline.InvalidateBoard(boardID)
+	}
+	if uc.statusNotifier != nil {
+		uc.statusNotifier.BroadcastStatus(board)
+	}
+
+	response := toBoardResponseDto(*board)
+	return &response, nil
+}
+
+func toBoardResponseDto(board entities.Board) entities.BoardResponseDto {
+	return entities.BoardResponseDto{
+		ID:                board.ID,
+		BoardID:           board.BoardID,
+		BoardName:         board.BoardName,
+		Location:          board.Location,
+		Notes:             board.Notes,
+		PondID:            board.PondID,
+		BoardRegisterDate: board.BoardRegisterDate,
+		BoardStatus:       board.BoardStatus,
+		DisabledAt:        board.DisabledAt,
+		DisabledReason:    board.DisabledReason,
+		LastSeen:          board.LastSeen,
+		RunTime:           board.RunTime,
+		UptimeSeconds:     board.UptimeSeconds,
+		FirmwareVersion:   board.FirmwareVersion,
+		HardwareModel:     board.HardwareModel,
+		RSSI:              board.RSSI,
+		FreeHeap:          board.FreeHeap,
+		ResetReason:       board.ResetReason,
+		LastHeartbeatAt:   board.LastHeartbeatAt,
+	}
 }
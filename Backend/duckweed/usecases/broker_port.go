@@ -0,0 +1,8 @@
+package usecases
+
+import "main/duckweed/entities"
+
+// BrokerConnection reports the state of the backend's MQTT connection.
+type BrokerConnection interface {
+	ConnectionHealth() entities.MqttHealthDto
+}
@@ -0,0 +1,169 @@
+package usecases
+
+import (
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"math"
+	"time"
+)
+
+var (
+	ErrInvalidCalibration = errors.New("calibration is missing values for its method or its buffer points are equal")
+	ErrNoCalibrations     = errors.New("no calibrations recorded for this board and channel")
+)
+
+type CalibrationUseCaseInterface interface {
+	CreateSession(userID uint, boardID string, dto entities.InsertCalibrationSessionDto) ([]entities.BoardCalibration, error)
+	GetCalibrations(userID uint, boardID string, channel entities.MetricEnum) ([]entities.BoardCalibration, error)
+	Recompute(userID uint, boardID string, dto entities.RecomputeCalibrationDto) (*entities.RecomputeCalibrationResponseDto, error)
+}
+
+type CalibrationUseCase struct {
+	calibrationRepo       repositories.CalibrationRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+}
+
+func NewCalibrationUseCase(
+	calibrationRepo repositories.CalibrationRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+) CalibrationUseCaseInterface {
+	return &CalibrationUseCase{
+		calibrationRepo:       calibrationRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+	}
+}
+
+// CreateSession records the calibrations of one session. They start applying
+// to new telemetry once the ingestion cache expires; readings already stored
+// keep their values until Recompute is called.
+func (uc *CalibrationUseCase) CreateSession(userID uint, boardID string, dto entities.InsertCalibrationSessionDto) ([]entities.BoardCalibration, error) {
+	validFrom := time.Now()
+	if dto.ValidFrom != nil {
+		validFrom = *dto.ValidFrom
+	}
+
+	calibrations := make([]*entities.BoardCalibration, 0, len(dto.Calibrations))
+	for _, entry := range dto.Calibrations {
+		if !entry.Channel.IsValidChannel() {
+			return nil, ErrInvalidChannel
+		}
+		calibration, err := newCalibration(entry)
+		if err != nil {
+			return nil, err
+		}
+		calibration.BoardID = boardID
+		calibration.ValidFrom = validFrom
+		calibration.CalibratedBy = userID
+		calibration.Note = dto.Note
+		calibrations = append(calibrations, calibration)
+	}
+
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return nil, err
+	}
+
+	if err := uc.calibrationRepo.CreateSession(calibrations); err != nil {
+		return nil, err
+	}
+
+	created := make([]entities.BoardCalibration, 0, len(calibrations))
+	for _, calibration := range calibrations {
+		created = append(created, *calibration)
+	}
+	return created, nil
+}
+
+func (uc *CalibrationUseCase) GetCalibrations(userID uint, boardID string, channel entities.MetricEnum) ([]entities.BoardCalibration, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+	return uc.calibrationRepo.FindByBoardID(boardID, channel)
+}
+
+// Recompute re-derives the calibrated value of stored measurements from their
+// raw value, using whichever calibration was valid at the time of each one.
+func (uc *CalibrationUseCase) Recompute(userID uint, boardID string, dto entities.RecomputeCalibrationDto) (*entities.RecomputeCalibrationResponseDto, error) {
+	if dto.From != nil && dto.To != nil && !dto.From.Before(*dto.To) {
+		return nil, ErrInvalidTimeRange
+	}
+	if dto.Channel != nil && !dto.Channel.IsValidChannel() {
+		return nil, ErrInvalidChannel
+	}
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOperator); err != nil {
+		return nil, err
+	}
+
+	var channels []entities.MetricEnum
+	if dto.Channel != nil {
+		channels = []entities.MetricEnum{*dto.Channel}
+	} else {
+		var err error
+		if channels, err = uc.calibrationRepo.FindChannelsByBoardID(boardID); err != nil {
+			return nil, err
+		}
+	}
+
+	response := &entities.RecomputeCalibrationResponseDto{Channels: channels}
+	for _, channel := range channels {
+		calibrations, err := uc.calibrationRepo.FindByBoardID(boardID, channel)
+		if err != nil {
+			return nil, err
+		}
+		if len(calibrations) == 0 {
+			return nil, ErrNoCalibrations
+		}
+		updated, err := uc.calibrationRepo.Recompute(boardID, channel, calibrations, dto.From, dto.To)
+		if err != nil {
+			return nil, err
+		}
+		response.Updated += updated
+	}
+	return response, nil
+}
+
+// newCalibration normalises an entry into slope and offset.
+func newCalibration(dto entities.InsertCalibrationDto) (*entities.BoardCalibration, error) {
+	calibration := &entities.BoardCalibration{
+		Channel: dto.Channel,
+		Method:  dto.Method,
+	}
+
+	switch dto.Method {
+	case entities.CalibrationOffset:
+		if dto.Offset == nil {
+			return nil, ErrInvalidCalibration
+		}
+		calibration.Slope = 1
+		calibration.Offset = *dto.Offset
+	case entities.CalibrationLinear:
+		if dto.Slope == nil || dto.Offset == nil || *dto.Slope == 0 {
+			return nil, ErrInvalidCalibration
+		}
+		calibration.Slope = *dto.Slope
+		calibration.Offset = *dto.Offset
+	case entities.CalibrationTwoPoint:
+		if dto.RawLow == nil || dto.ReferenceLow == nil || dto.RawHigh == nil || dto.ReferenceHigh == nil {
+			return nil, ErrInvalidCalibration
+		}
+		if *dto.RawHigh == *dto.RawLow || *dto.ReferenceHigh == *dto.ReferenceLow {
+			return nil, ErrInvalidCalibration
+		}
+		calibration.Slope = (*dto.ReferenceHigh - *dto.ReferenceLow) / (*dto.RawHigh - *dto.RawLow)
+		calibration.Offset = *dto.ReferenceLow - calibration.Slope**dto.RawLow
+		calibration.RawLow = dto.RawLow
+		calibration.ReferenceLow = dto.ReferenceLow
+		calibration.RawHigh = dto.RawHigh
+		calibration.ReferenceHigh = dto.ReferenceHigh
+	default:
+		return nil, ErrInvalidCalibration
+	}
+
+	if math.IsNaN(calibration.Slope) || math.IsInf(calibration.Slope, 0) {
+		return nil, ErrInvalidCalibration
+	}
+	return calibration, nil
+}
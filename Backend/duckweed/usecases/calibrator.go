@@ -0,0 +1,98 @@
+package usecases
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"sync"
+	"time"
+)
+
+// calibrationCacheTTL bounds how long a newly recorded calibration takes to
+// reach the ingestion path.
+const calibrationCacheTTL = 30 * time.Second
+
+type CalibratorInterface interface {
+	Calibrate(boardID string, measurements []entities.Measurement) error
+	Invalidate(boardID string)
+}
+
+type cachedCalibrations struct {
+	loadedAt time.Time
+	channels map[entities.MetricEnum][]entities.BoardCalibration
+}
+
+// Calibrator applies the calibration that was valid at the time of each
+// reading while telemetry is ingested.
+type Calibrator struct {
+	calibrationRepo repositories.CalibrationRepositoryInterface
+	mutex           sync.Mutex
+	boards          map[string]*cachedCalibrations
+}
+
+func NewCalibrator(calibrationRepo repositories.CalibrationRepositoryInterface) CalibratorInterface {
+	return &Calibrator{
+		calibrationRepo: calibrationRepo,
+		boards:          make(map[string]*cachedCalibrations),
+	}
+}
+
+// Calibrate keeps the value each measurement arrived with as RawValue and
+// replaces Value with the calibrated reading.
+func (c *Calibrator) Calibrate(boardID string, measurements []entities.Measurement) error {
+	channels, err := c.calibrations(boardID)
+	if err != nil {
+		return err
+	}
+
+	for i := range measurements {
+		measurement := &measurements[i]
+		raw := measurement.Value
+		measurement.RawValue = &raw
+
+		calibration := validCalibration(channels[measurement.Channel], measurement.CreatedAt)
+		if calibration == nil {
+			continue
+		}
+		measurement.Value = calibration.Apply(raw)
+		measurement.CalibrationID = &calibration.ID
+	}
+	return nil
+}
+
+func (c *Calibrator) Invalidate(boardID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.boards, boardID)
+}
+
+func (c *Calibrator) calibrations(boardID string) (map[entities.MetricEnum][]entities.BoardCalibration, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	if cached, ok := c.boards[boardID]; ok && now.Sub(cached.loadedAt) < calibrationCacheTTL {
+		return cached.channels, nil
+	}
+
+	rows, err := c.calibrationRepo.FindByBoardID(boardID, "")
+	if err != nil {
+		return nil, err
+	}
+	channels := make(map[entities.MetricEnum][]entities.BoardCalibration)
+	for _, row := range rows {
+		channels[row.Channel] = append(channels[row.Channel], row)
+	}
+	c.boards[boardID] = &cachedCalibrations{loadedAt: now, channels: channels}
+	return channels, nil
+}
+
+// validCalibration returns the latest calibration that started at or before
+// the reading. calibrations must be ordered by ValidFrom.
+func validCalibration(calibrations []entities.BoardCalibration, at time.Time) *entities.BoardCalibration {
+	for i := len(calibrations) - 1; i >= 0; i-- {
+		if !calibrations[i].ValidFrom.After(at) {
+			return &calibrations[i]
+		}
+	}
+	return nil
+}
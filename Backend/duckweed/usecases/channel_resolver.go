@@ -0,0 +1,191 @@
+package usecases
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"math"
+	"sync"
+	"time"
+)
+
+// channelCacheTTL bounds how long channel mapping and sensor edits take to
+// reach the ingestion path.
+const channelCacheTTL = 30 * time.Second
+
+type ChannelResolverInterface interface {
+	Describe(boardID string, channels []entities.MetricEnum) ([]entities.ChannelInfoDto, error)
+	TelemetryPoint(sensorLog *entities.SensorLog) (*entities.TelemetryPointDto, error)
+	Invalidate(boardID string)
+}
+
+type cachedBoardChannels struct {
+	loadedAt time.Time
+	channels map[entities.MetricEnum]entities.BoardChannel
+}
+
+// ChannelResolver finds the Sensor definition behind each channel of a board.
+// An explicit BoardChannel mapping wins; otherwise the Sensor whose
+// SensorType equals the channel name is used, which keeps the original
+// temperature/ec/ph boards working without any configuration.
+type ChannelResolver struct {
+	channelRepo repositories.BoardChannelRepositoryInterface
+	sensorRepo  *repositories.SensorRepository
+
+	mutex           sync.Mutex
+	sensorsByType   map[string]entities.Sensor
+	sensorsLoadedAt time.Time
+	boards          map[string]*cachedBoardChannels
+}
+
+func NewChannelResolver(
+	channelRepo repositories.BoardChannelRepositoryInterface,
+	sensorRepo *repositories.SensorRepository,
+) ChannelResolverInterface {
+	return &ChannelResolver{
+		channelRepo: channelRepo,
+		sensorRepo:  sensorRepo,
+		boards:      make(map[string]*cachedBoardChannels),
+	}
+}
+
+// Describe returns the definition of each requested channel, in order.
+func (r *ChannelResolver) Describe(boardID string, channels []entities.MetricEnum) ([]entities.ChannelInfoDto, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	mapped, err := r.boardChannels(boardID)
+	if err != nil {
+		return nil, err
+	}
+	sensors, err := r.sensors()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]entities.ChannelInfoDto, 0, len(channels))
+	for _, channel := range channels {
+		info := entities.ChannelInfoDto{Channel: channel}
+		var sensor *entities.Sensor
+		if mapping, ok := mapped[channel]; ok {
+			info.Label = mapping.Label
+			sensor = mapping.Sensor
+		}
+		if sensor == nil {
+			if fallback, ok := sensors[string(channel)]; ok {
+				sensor = &fallback
+			}
+		}
+		if sensor != nil {
+			info.SensorID = sensor.SensorID
+			info.SensorType = sensor.SensorType
+			info.Unit = sensor.SensorUnit
+			info.Precision = sensor.SensorPrecision
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// TelemetryPoint renders a stored reading with the unit of every channel and
+// its values rounded to the sensor precision.
+func (r *ChannelResolver) TelemetryPoint(sensorLog *entities.SensorLog) (*entities.TelemetryPointDto, error) {
+	point := &entities.TelemetryPointDto{
+		ID:           sensorLog.ID,
+		Sequence:     sensorLog.Sequence,
+		Measurements: make([]entities.MeasurementDto, 0, len(sensorLog.Measurements)),
+		CreatedAt:    sensorLog.CreatedAt,
+	}
+	if sensorLog.BoardID != nil {
+		point.BoardID = *sensorLog.BoardID
+	}
+
+	channels := make([]entities.MetricEnum, 0, len(sensorLog.Measurements))
+	for _, measurement := range sensorLog.Measurements {
+		channels = append(channels, measurement.Channel)
+	}
+	infos, err := r.Describe(point.BoardID, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, measurement := range sensorLog.Measurements {
+		info := infos[i]
+		dto := entities.MeasurementDto{
+			Channel:    measurement.Channel,
+			Value:      roundToPrecision(measurement.Value, info.Precision),
+			SensorType: info.SensorType,
+			Unit:       info.Unit,
+		}
+		if measurement.CalibrationID != nil {
+			dto.RawValue = roundPointer(measurement.RawValue, info.Precision)
+		}
+		if measurement.Quality == entities.QualitySuspect {
+			dto.Quality = measurement.Quality
+			dto.QualityReason = measurement.QualityReason
+		}
+		point.Measurements = append(point.Measurements, dto)
+	}
+	return point, nil
+}
+
+func (r *ChannelResolver) Invalidate(boardID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.boards, boardID)
+	r.sensorsByType = nil
+}
+
+// boardChannels returns the mappings of a board. The caller must hold r.mutex.
+func (r *ChannelResolver) boardChannels(boardID string) (map[entities.MetricEnum]entities.BoardChannel, error) {
+	now := time.Now()
+	if cached, ok := r.boards[boardID]; ok && now.Sub(cached.loadedAt) < channelCacheTTL {
+		return cached.channels, nil
+	}
+
+	rows, err := r.channelRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	channels := make(map[entities.MetricEnum]entities.BoardChannel, len(rows))
+	for _, row := range rows {
+		channels[row.Channel] = row
+	}
+	r.boards[boardID] = &cachedBoardChannels{loadedAt: now, channels: channels}
+	return channels, nil
+}
+
+// sensors returns the Sensor definitions keyed by type. The caller must hold
+// r.mutex.
+func (r *ChannelResolver) sensors() (map[string]entities.Sensor, error) {
+	now := time.Now()
+	if r.sensorsByType != nil && now.Sub(r.sensorsLoadedAt) < channelCacheTTL {
+		return r.sensorsByType, nil
+	}
+
+	sensors, err := r.sensorRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]entities.Sensor, len(sensors))
+	for _, sensor := range sensors {
+		if sensor.SensorType == nil {
+			continue
+		}
+		if _, exists := byType[*sensor.SensorType]; !exists {
+			byType[*sensor.SensorType] = sensor
+		}
+	}
+	r.sensorsByType = byType
+	r.sensorsLoadedAt = now
+	return byType, nil
+}
+
+// roundToPrecision rounds a value to the number of decimals of its sensor.
+// Values are stored unrounded; precision only applies to what is shown.
+func roundToPrecision(value float64, precision *int) float64 {
+	if precision == nil || *precision < 0 {
+		return value
+	}
+	scale := math.Pow(10, float64(*precision))
+	return math.Round(value*scale) / scale
+}
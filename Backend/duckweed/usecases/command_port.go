@@ -0,0 +1,6 @@
+package usecases
+
+// CommandPublisher delivers a downlink command payload to a board.
+type CommandPublisher interface {
+	PublishCommand(boardID string, payload []byte) error
+}
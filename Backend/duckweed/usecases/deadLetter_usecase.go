@@ -0,0 +1,173 @@
+package usecases
+
+import (
+	"encoding/base64"
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"time"
+	"unicode/utf8"
+)
+
+const maxDeadLetters = 500
+
+var (
+	ErrDeadLetterNotFound   = errors.New("dead letter not found")
+	ErrIngestionUnavailable = errors.New("ingestion pipeline is not running")
+	ErrPurgeFilterRequired  = errors.New("purge needs a board_id, reason or before filter")
+	// ErrDeadLetterReplayed is returned when a message that was already
+	// replayed would be replayed again without force.
+	ErrDeadLetterReplayed = errors.New("dead letter was already replayed")
+)
+
+type DeadLetterUseCaseInterface interface {
+	GetDeadLetters(filter entities.DeadLetterFilterDto) ([]entities.DeadLetterResponseDto, error)
+	GetDeadLetter(id uint) (*entities.DeadLetterResponseDto, error)
+	ReplayDeadLetter(id uint, force bool) (*entities.DeadLetterReplayResultDto, error)
+	ReplayBoardDeadLetters(boardID string) ([]entities.DeadLetterReplayResultDto, error)
+	DeleteDeadLetter(id uint) error
+	PurgeDeadLetters(filter entities.DeadLetterFilterDto) (int64, error)
+}
+
+type DeadLetterUseCase struct {
+	deadLetterRepo repositories.DeadLetterRepositoryInterface
+	pipeline       IngestionPipeline
+}
+
+func NewDeadLetterUseCase(deadLetterRepo repositories.DeadLetterRepositoryInterface, pipeline IngestionPipeline) DeadLetterUseCaseInterface {
+	return &DeadLetterUseCase{
+		deadLetterRepo: deadLetterRepo,
+		pipeline:       pipeline,
+	}
+}
+
+func (uc *DeadLetterUseCase) GetDeadLetters(filter entities.DeadLetterFilterDto) ([]entities.DeadLetterResponseDto, error) {
+	if filter.Limit <= 0 || filter.Limit > maxDeadLetters {
+		filter.Limit = maxDeadLetters
+	}
+	messages, err := uc.deadLetterRepo.Find(filter)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.DeadLetterResponseDto, 0, len(messages))
+	for _, message := range messages {
+		response = append(response, toDeadLetterResponseDto(message))
+	}
+	return response, nil
+}
+
+func (uc *DeadLetterUseCase) GetDeadLetter(id uint) (*entities.DeadLetterResponseDto, error) {
+	message, err := uc.deadLetterRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	response := toDeadLetterResponseDto(*message)
+	return &response, nil
+}
+
+// ReplayDeadLetter replays one message. One that was replayed already would
+// store its readings without a dedup key twice, so it is only replayed again
+// when forced.
+func (uc *DeadLetterUseCase) ReplayDeadLetter(id uint, force bool) (*entities.DeadLetterReplayResultDto, error) {
+	if uc.pipeline == nil {
+		return nil, ErrIngestionUnavailable
+	}
+	message, err := uc.deadLetterRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil {
+		return nil, ErrDeadLetterNotFound
+	}
+	if message.ReplayedAt != nil && !force {
+		return nil, ErrDeadLetterReplayed
+	}
+	return uc.replay(message)
+}
+
+// ReplayBoardDeadLetters replays every pending dead letter of a board in the
+// order it was received, typically right after the board has been claimed.
+func (uc *DeadLetterUseCase) ReplayBoardDeadLetters(boardID string) ([]entities.DeadLetterReplayResultDto, error) {
+	if uc.pipeline == nil {
+		return nil, ErrIngestionUnavailable
+	}
+	messages, err := uc.deadLetterRepo.Find(entities.DeadLetterFilterDto{BoardID: boardID, Limit: maxDeadLetters})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]entities.DeadLetterReplayResultDto, 0, len(messages))
+	for i := range messages {
+		result, err := uc.replay(&messages[i])
+		if err != nil {
+			return results, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+func (uc *DeadLetterUseCase) replay(message *entities.DeadLetterMessage) (*entities.DeadLetterReplayResultDto, error) {
+	result := &entities.DeadLetterReplayResultDto{ID: message.ID}
+
+	readings, err := uc.pipeline.ReplayTelemetry(message.Topic, message.Payload, message.ReceivedAt)
+	if err != nil && !errors.Is(err, ErrReplayRejected) {
+		return nil, err
+	}
+
+	message.ReplayCount++
+	if err != nil {
+		message.Detail = err.Error()
+		result.Error = err.Error()
+	} else {
+		now := time.Now()
+		message.ReplayedAt = &now
+		result.Replayed = true
+		result.Readings = readings
+	}
+	if err := uc.deadLetterRepo.Save(message); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (uc *DeadLetterUseCase) DeleteDeadLetter(id uint) error {
+	message, err := uc.deadLetterRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if message == nil {
+		return ErrDeadLetterNotFound
+	}
+	return uc.deadLetterRepo.Delete(id)
+}
+
+func (uc *DeadLetterUseCase) PurgeDeadLetters(filter entities.DeadLetterFilterDto) (int64, error) {
+	if filter.BoardID == "" && filter.ReasonCode == "" && filter.Before == nil {
+		return 0, ErrPurgeFilterRequired
+	}
+	filter.IncludeReplayed = true
+	return uc.deadLetterRepo.Purge(filter)
+}
+
+func toDeadLetterResponseDto(message entities.DeadLetterMessage) entities.DeadLetterResponseDto {
+	dto := entities.DeadLetterResponseDto{
+		ID:            message.ID,
+		Topic:         message.Topic,
+		BoardID:       message.BoardID,
+		ReasonCode:    message.ReasonCode,
+		Detail:        message.Detail,
+		ReceivedAt:    message.ReceivedAt,
+		ReplayCount:   message.ReplayCount,
+		ReplayedAt:    message.ReplayedAt,
+		PayloadBase64: base64.StdEncoding.EncodeToString(message.Payload),
+	}
+	if utf8.Valid(message.Payload) {
+		text := string(message.Payload)
+		dto.PayloadText = &text
+	}
+	return dto
+}
@@ -0,0 +1,102 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"time"
+)
+
+// ErrInvalidDeviceToken is returned for a missing or wrong device token. It
+// does not say whether the board exists.
+var ErrInvalidDeviceToken = errors.New("invalid device token")
+
+type DeviceTelemetryUseCaseInterface interface {
+	IssueDeviceToken(userID uint, boardID string) (*entities.DeviceTokenResponseDto, error)
+	IngestTelemetry(boardID string, token string, payload []byte) (*entities.IngestTelemetryResponseDto, error)
+}
+
+// DeviceTelemetryUseCase accepts telemetry from devices that cannot speak
+// MQTT, such as LoRa gateways and cellular loggers, and hands it to the same
+// ingestion pipeline as the broker.
+type DeviceTelemetryUseCase struct {
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	pipeline              IngestionPipeline
+}
+
+func NewDeviceTelemetryUseCase(
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	pipeline IngestionPipeline,
+) DeviceTelemetryUseCaseInterface {
+	return &DeviceTelemetryUseCase{
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		pipeline:              pipeline,
+	}
+}
+
+// IssueDeviceToken creates a new token for the board, replacing any previous
+// one. Only its hash is stored.
+func (uc *DeviceTelemetryUseCase) IssueDeviceToken(userID uint, boardID string) (*entities.DeviceTokenResponseDto, error) {
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(secret)
+	tokenHash := hashDeviceToken(token)
+	issuedAt := time.Now()
+
+	if err := uc.boardRepo.UpdateDeviceToken(boardID, &tokenHash, &issuedAt); err != nil {
+		return nil, err
+	}
+	return &entities.DeviceTokenResponseDto{
+		BoardID:  boardID,
+		Token:    token,
+		IssuedAt: issuedAt,
+	}, nil
+}
+
+func (uc *DeviceTelemetryUseCase) IngestTelemetry(boardID string, token string, payload []byte) (*entities.IngestTelemetryResponseDto, error) {
+	if uc.pipeline == nil {
+		return nil, ErrIngestionUnavailable
+	}
+	if token == "" {
+		return nil, ErrInvalidDeviceToken
+	}
+
+	board, err := uc.boardRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, err
+	}
+	if board == nil || board.DeviceTokenHash == nil {
+		return nil, ErrInvalidDeviceToken
+	}
+	tokenHash := hashDeviceToken(token)
+	if subtle.ConstantTimeCompare([]byte(tokenHash), []byte(*board.DeviceTokenHash)) != 1 {
+		return nil, ErrInvalidDeviceToken
+	}
+
+	accepted, err := uc.pipeline.IngestTelemetry(board.BoardID, payload, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &entities.IngestTelemetryResponseDto{
+		BoardID:  board.BoardID,
+		Accepted: accepted,
+	}, nil
+}
+
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,18 @@
+package usecases
+
+import "errors"
+
+var (
+	ErrBoardNotFound     = errors.New("board not found")
+	ErrBoardAccessDenied = errors.New("user has no relationship with this board")
+	// ErrBoardRoleForbidden is returned when the user's role on the board
+	// does not allow the action.
+	ErrBoardRoleForbidden = errors.New("your role on this board does not allow this")
+	// ErrBoardAccessDisabled is returned when the board's owner disabled the
+	// user's relationship with the board.
+	ErrBoardAccessDisabled = errors.New("your access to this board is disabled")
+	// ErrBoardDisabled is returned for telemetry of a disabled board.
+	ErrBoardDisabled    = errors.New("board is disabled")
+	ErrInvalidTimeRange = errors.New("invalid time range")
+	ErrInvalidInterval  = errors.New("invalid interval")
+)
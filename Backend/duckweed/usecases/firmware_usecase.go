@@ -0,0 +1,800 @@
+package usecases
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxFirmwareRollouts     = 100
+	maxBoardFirmwareUpdates = 20
+)
+
+var (
+	ErrFirmwareNotFound       = errors.New("firmware release not found")
+	ErrFirmwareExists         = errors.New("this version already exists for the hardware model")
+	ErrInvalidFirmware        = errors.New("invalid firmware image")
+	ErrFirmwareChecksum       = errors.New("firmware checksum does not match the uploaded image")
+	ErrRolloutNotFound        = errors.New("rollout not found")
+	ErrInvalidRollout         = errors.New("invalid rollout")
+	ErrRolloutConflict        = errors.New("boards already have a firmware update in progress")
+	ErrRolloutState           = errors.New("rollout does not allow this in its current state")
+	ErrFirmwareUpdateNotFound = errors.New("firmware update not found")
+	ErrInvalidDownloadLink    = errors.New("download link is invalid or expired")
+)
+
+// FirmwareOptions are the settings the firmware use case takes from the
+// configuration. The MQTT side only tracks progress and leaves the storage
+// and download settings empty.
+type FirmwareOptions struct {
+	StoragePath string
+	// BaseURL is how boards reach this server; download links start with it.
+	BaseURL       string
+	SigningSecret []byte
+	URLTTL        time.Duration
+	// FailureThreshold and MinFailureSamples are the defaults for new rollouts.
+	FailureThreshold  float64
+	MinFailureSamples int
+}
+
+type FirmwareUseCaseInterface interface {
+	UploadRelease(userID uint, dto entities.InsertFirmwareReleaseDto, image io.Reader) (*entities.FirmwareRelease, error)
+	GetReleases() ([]entities.FirmwareRelease, error)
+	GetRelease(id uint) (*entities.FirmwareRelease, error)
+	CreateRollout(userID uint, dto entities.InsertFirmwareRolloutDto) (*entities.FirmwareRolloutResponseDto, error)
+	GetRollouts() ([]entities.FirmwareRolloutResponseDto, error)
+	GetRollout(id uint) (*entities.FirmwareRolloutResponseDto, error)
+	GetRolloutUpdates(id uint) ([]entities.FirmwareUpdate, error)
+	AdvanceRollout(id uint) (*entities.FirmwareRolloutResponseDto, error)
+	HaltRollout(id uint, reason string) (*entities.FirmwareRolloutResponseDto, error)
+	ResumeRollout(id uint) (*entities.FirmwareRolloutResponseDto, error)
+	CancelRollout(id uint) (*entities.FirmwareRolloutResponseDto, error)
+	GetBoardFirmware(userID uint, boardID string) (*entities.BoardFirmwareDto, error)
+	OpenDownload(releaseID uint, boardID string, expires int64, signature string) (*entities.FirmwareRelease, error)
+	HandleProgress(boardID string, progress entities.FirmwareProgressDto) (*entities.FirmwareUpdate, error)
+	HandleReportedVersion(boardID string, version string) error
+	HandleCommandAck(command *entities.BoardCommand) error
+	ExpireStaleUpdates(timeout time.Duration) (int, error)
+}
+
+// FirmwareUseCase keeps the release registry and drives OTA rollouts. Boards
+// are told about a release with the ota board command, fetch the image from
+// a signed link and report back on their OTA topic or in their heartbeat.
+type FirmwareUseCase struct {
+	firmwareRepo          repositories.FirmwareRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	commandRepo           repositories.BoardCommandRepositoryInterface
+	publisher             CommandPublisher
+	options               FirmwareOptions
+}
+
+func NewFirmwareUseCase(
+	firmwareRepo repositories.FirmwareRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	commandRepo repositories.BoardCommandRepositoryInterface,
+	publisher CommandPublisher,
+	options FirmwareOptions,
+) FirmwareUseCaseInterface {
+	return &FirmwareUseCase{
+		firmwareRepo:          firmwareRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		commandRepo:           commandRepo,
+		publisher:             publisher,
+		options:               options,
+	}
+}
+
+// UploadRelease stores the image under its checksum and registers it. When
+// the uploader gave a checksum, the image must match it.
+func (uc *FirmwareUseCase) UploadRelease(userID uint, dto entities.InsertFirmwareReleaseDto, image io.Reader) (*entities.FirmwareRelease, error) {
+	existing, err := uc.firmwareRepo.FindReleaseByVersion(dto.HardwareModel, dto.Version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrFirmwareExists
+	}
+
+	if err := os.MkdirAll(uc.options.StoragePath, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create firmware storage: %w", err)
+	}
+	file, err := os.CreateTemp(uc.options.StoragePath, "upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("could not store firmware image: %w", err)
+	}
+	// Removes the upload if it is rejected; after the rename there is nothing left to remove.
+	defer os.Remove(file.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), image)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not store firmware image: %w", err)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("%w: the image is empty", ErrInvalidFirmware)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if dto.Checksum != "" && !strings.EqualFold(dto.Checksum, checksum) {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrFirmwareChecksum, strings.ToLower(dto.Checksum), checksum)
+	}
+	path := filepath.Join(uc.options.StoragePath, checksum+".bin")
+	if err := os.Rename(file.Name(), path); err != nil {
+		return nil, fmt.Errorf("could not store firmware image: %w", err)
+	}
+
+	return uc.firmwareRepo.CreateRelease(&entities.FirmwareRelease{
+		HardwareModel: dto.HardwareModel,
+		Version:       dto.Version,
+		Checksum:      checksum,
+		Size:          size,
+		FilePath:      path,
+		Notes:         dto.Notes,
+		UploadedBy:    userID,
+	})
+}
+
+func (uc *FirmwareUseCase) GetReleases() ([]entities.FirmwareRelease, error) {
+	return uc.firmwareRepo.FindReleases()
+}
+
+func (uc *FirmwareUseCase) GetRelease(id uint) (*entities.FirmwareRelease, error) {
+	release, err := uc.firmwareRepo.FindReleaseByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		return nil, ErrFirmwareNotFound
+	}
+	return release, nil
+}
+
+// CreateRollout targets the boards, places them in cohorts and notifies the
+// boards of the first stage right away.
+func (uc *FirmwareUseCase) CreateRollout(userID uint, dto entities.InsertFirmwareRolloutDto) (*entities.FirmwareRolloutResponseDto, error) {
+	if uc.publisher == nil {
+		return nil, ErrCommandChannelUnavailable
+	}
+
+	release, err := uc.GetRelease(dto.ReleaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	stages := dto.Stages
+	if len(stages) == 0 {
+		stages = []int{100}
+	}
+	for i, percent := range stages {
+		if i > 0 && percent <= stages[i-1] {
+			return nil, fmt.Errorf("%w: stages must increase", ErrInvalidRollout)
+		}
+	}
+	if stages[len(stages)-1] != 100 {
+		return nil, fmt.Errorf("%w: the last stage must be 100", ErrInvalidRollout)
+	}
+	encodedStages, err := json.Marshal(stages)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := uc.options.FailureThreshold
+	if dto.FailureThreshold != nil {
+		threshold = *dto.FailureThreshold
+	}
+
+	boards, err := uc.rolloutTargets(release, dto.BoardIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(boards) == 0 {
+		return nil, fmt.Errorf("%w: no board needs %s %s", ErrInvalidRollout, release.HardwareModel, release.Version)
+	}
+	boardIDs := make([]string, 0, len(boards))
+	for _, board := range boards {
+		boardIDs = append(boardIDs, board.BoardID)
+	}
+	busy, err := uc.firmwareRepo.FindBoardsWithUnfinishedUpdates(boardIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(busy) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRolloutConflict, strings.Join(busy, ", "))
+	}
+
+	now := time.Now()
+	rollout := &entities.FirmwareRollout{
+		ReleaseID:         release.ID,
+		State:             entities.RolloutStateActive,
+		Stages:            string(encodedStages),
+		StageStartedAt:    &now,
+		FailureThreshold:  threshold,
+		MinFailureSamples: max(uc.options.MinFailureSamples, 1),
+		CreatedBy:         userID,
+	}
+	if err := uc.firmwareRepo.CreateRollout(rollout, assignCohorts(release, boards)); err != nil {
+		return nil, fmt.Errorf("could not save rollout: %w", err)
+	}
+	rollout.Release = release
+
+	if err := uc.notifyStage(rollout); err != nil {
+		return nil, err
+	}
+	return uc.describeRollout(rollout)
+}
+
+// rolloutTargets returns the listed boards, or every board of the release's
+// hardware model when none are listed. Boards already running the release
+// are left out.
+func (uc *FirmwareUseCase) rolloutTargets(release *entities.FirmwareRelease, boardIDs []string) ([]entities.Board, error) {
+	var boards []entities.Board
+	if len(boardIDs) == 0 {
+		all, err := uc.boardRepo.FindByHardwareModel(release.HardwareModel)
+		if err != nil {
+			return nil, err
+		}
+		boards = all
+	}
+
+	seen := make(map[string]bool, len(boardIDs))
+	for _, boardID := range boardIDs {
+		if seen[boardID] {
+			continue
+		}
+		seen[boardID] = true
+
+		board, err := uc.boardRepo.FindByBoardID(boardID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading board: %w", err)
+		}
+		if board == nil {
+			return nil, fmt.Errorf("%w: %s", ErrBoardNotFound, boardID)
+		}
+		if board.HardwareModel != nil && *board.HardwareModel != release.HardwareModel {
+			return nil, fmt.Errorf("%w: board %s is a %s, the release is for %s", ErrInvalidRollout, boardID, *board.HardwareModel, release.HardwareModel)
+		}
+		boards = append(boards, *board)
+	}
+
+	targets := make([]entities.Board, 0, len(boards))
+	for _, board := range boards {
+		if board.FirmwareVersion != nil && *board.FirmwareVersion == release.Version {
+			continue
+		}
+		targets = append(targets, board)
+	}
+	return targets, nil
+}
+
+// assignCohorts spreads the boards evenly over cohorts 0 to 99, in an order
+// shuffled by the release version so every stage reaches its share of the
+// boards and the first stage is not always the same boards.
+func assignCohorts(release *entities.FirmwareRelease, boards []entities.Board) []entities.FirmwareUpdate {
+	order := func(boardID string) uint32 {
+		hash := fnv.New32a()
+		hash.Write([]byte(release.Version + "/" + boardID))
+		return hash.Sum32()
+	}
+	sort.Slice(boards, func(i, j int) bool {
+		return order(boards[i].BoardID) < order(boards[j].BoardID)
+	})
+
+	updates := make([]entities.FirmwareUpdate, 0, len(boards))
+	for i, board := range boards {
+		updates = append(updates, entities.FirmwareUpdate{
+			ReleaseID:   release.ID,
+			BoardID:     board.BoardID,
+			Cohort:      i * 100 / len(boards),
+			State:       entities.FirmwareUpdatePending,
+			FromVersion: board.FirmwareVersion,
+		})
+	}
+	return updates
+}
+
+// notifyStage sends the release to every board of the current stage that has
+// not been told yet. A board that cannot be reached stays pending and is
+// tried again when the rollout is advanced or resumed.
+func (uc *FirmwareUseCase) notifyStage(rollout *entities.FirmwareRollout) error {
+	stages, err := rolloutStages(rollout)
+	if err != nil {
+		return err
+	}
+	updates, err := uc.firmwareRepo.FindPendingUpdates(rollout.ID, stages[rollout.StageIndex])
+	if err != nil {
+		return err
+	}
+
+	for i := range updates {
+		if err := uc.notify(rollout, &updates[i]); err != nil {
+			log.Printf("Could not send firmware %s to board %s: %v", rollout.Release.Version, updates[i].BoardID, err)
+		}
+	}
+	return nil
+}
+
+func (uc *FirmwareUseCase) notify(rollout *entities.FirmwareRollout, update *entities.FirmwareUpdate) error {
+	if uc.publisher == nil {
+		return ErrCommandChannelUnavailable
+	}
+
+	release := rollout.Release
+	expiresAt := time.Now().Add(uc.options.URLTTL).Truncate(time.Second)
+	params, err := json.Marshal(entities.FirmwareOtaParamsDto{
+		UpdateID:  update.ID,
+		Version:   release.Version,
+		URL:       uc.downloadURL(release.ID, update.BoardID, expiresAt.Unix()),
+		Checksum:  release.Checksum,
+		Size:      release.Size,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	command, err := dispatchCommand(uc.commandRepo, uc.publisher, rollout.CreatedBy, update.BoardID, entities.FirmwareCommand, params)
+	if err != nil {
+		return err
+	}
+	if command.State == entities.CommandStateFailed {
+		update.ErrorMessage = command.ErrorMessage
+		if err := uc.firmwareRepo.SaveUpdate(update); err != nil {
+			return err
+		}
+		return errors.New(*command.ErrorMessage)
+	}
+
+	update.State = entities.FirmwareUpdateNotified
+	update.CorrelationID = &command.CorrelationID
+	update.NotifiedAt = command.SentAt
+	update.ErrorMessage = nil
+	return uc.firmwareRepo.SaveUpdate(update)
+}
+
+// downloadURL builds the link served by FirmwareHandler.DownloadFirmware. It
+// only works for the board it was made for and until expires.
+func (uc *FirmwareUseCase) downloadURL(releaseID uint, boardID string, expires int64) string {
+	query := url.Values{}
+	query.Set("board", boardID)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("signature", uc.signDownload(releaseID, boardID, expires))
+	return fmt.Sprintf("%s/ota/firmware/%d?%s", strings.TrimSuffix(uc.options.BaseURL, "/"), releaseID, query.Encode())
+}
+
+func (uc *FirmwareUseCase) signDownload(releaseID uint, boardID string, expires int64) string {
+	mac := hmac.New(sha256.New, uc.options.SigningSecret)
+	fmt.Fprintf(mac, "%d:%s:%d", releaseID, boardID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (uc *FirmwareUseCase) GetRollouts() ([]entities.FirmwareRolloutResponseDto, error) {
+	rollouts, err := uc.firmwareRepo.FindRollouts(maxFirmwareRollouts)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.FirmwareRolloutResponseDto, 0, len(rollouts))
+	for i := range rollouts {
+		dto, err := uc.describeRollout(&rollouts[i])
+		if err != nil {
+			return nil, err
+		}
+		response = append(response, *dto)
+	}
+	return response, nil
+}
+
+func (uc *FirmwareUseCase) GetRollout(id uint) (*entities.FirmwareRolloutResponseDto, error) {
+	rollout, err := uc.loadRollout(id)
+	if err != nil {
+		return nil, err
+	}
+	return uc.describeRollout(rollout)
+}
+
+func (uc *FirmwareUseCase) GetRolloutUpdates(id uint) ([]entities.FirmwareUpdate, error) {
+	if _, err := uc.loadRollout(id); err != nil {
+		return nil, err
+	}
+	return uc.firmwareRepo.FindUpdatesByRolloutID(id)
+}
+
+// AdvanceRollout moves an active rollout to its next stage and notifies the
+// boards that join with it.
+func (uc *FirmwareUseCase) AdvanceRollout(id uint) (*entities.FirmwareRolloutResponseDto, error) {
+	if uc.publisher == nil {
+		return nil, ErrCommandChannelUnavailable
+	}
+
+	rollout, err := uc.loadRollout(id)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.State != entities.RolloutStateActive {
+		return nil, fmt.Errorf("%w: rollout is %s", ErrRolloutState, rollout.State)
+	}
+	stages, err := rolloutStages(rollout)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.StageIndex >= len(stages)-1 {
+		return nil, fmt.Errorf("%w: rollout is already at its last stage", ErrRolloutState)
+	}
+
+	now := time.Now()
+	advanced, err := uc.firmwareRepo.AdvanceRolloutStage(id, rollout.StageIndex+1, now)
+	if err != nil {
+		return nil, err
+	}
+	if !advanced {
+		// Halted, cancelled or advanced since it was loaded.
+		return nil, fmt.Errorf("%w: rollout changed meanwhile", ErrRolloutState)
+	}
+	rollout.StageIndex++
+	rollout.StageStartedAt = &now
+	if err := uc.notifyStage(rollout); err != nil {
+		return nil, err
+	}
+	return uc.describeRollout(rollout)
+}
+
+// HaltRollout stops an active rollout from reaching more boards. Boards that
+// were already notified finish their update.
+func (uc *FirmwareUseCase) HaltRollout(id uint, reason string) (*entities.FirmwareRolloutResponseDto, error) {
+	if reason == "" {
+		reason = "halted manually"
+	}
+	if err := uc.transitionRollout(id, entities.RolloutStateActive, entities.RolloutStateHalted, &reason); err != nil {
+		return nil, err
+	}
+	return uc.GetRollout(id)
+}
+
+// ResumeRollout reactivates a halted rollout and notifies the boards of the
+// current stage still waiting. The failures so far are acknowledged, so they
+// do not halt the rollout again on the next result.
+func (uc *FirmwareUseCase) ResumeRollout(id uint) (*entities.FirmwareRolloutResponseDto, error) {
+	if uc.publisher == nil {
+		return nil, ErrCommandChannelUnavailable
+	}
+
+	rollout, err := uc.loadRollout(id)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.State != entities.RolloutStateHalted {
+		return nil, fmt.Errorf("%w: rollout is %s", ErrRolloutState, rollout.State)
+	}
+	counts, err := uc.firmwareRepo.CountUpdatesByState(id)
+	if err != nil {
+		return nil, err
+	}
+	rollout.AcknowledgedFailed = counts[entities.FirmwareUpdateFailed]
+	rollout.AcknowledgedFinished = counts[entities.FirmwareUpdateFailed] + counts[entities.FirmwareUpdateSucceeded]
+	acknowledged, err := uc.firmwareRepo.AcknowledgeRolloutResults(id, rollout.AcknowledgedFailed, rollout.AcknowledgedFinished)
+	if err != nil {
+		return nil, err
+	}
+	if !acknowledged {
+		return nil, fmt.Errorf("%w: rollout is not %s", ErrRolloutState, entities.RolloutStateHalted)
+	}
+	if err := uc.transitionRollout(id, entities.RolloutStateHalted, entities.RolloutStateActive, nil); err != nil {
+		return nil, err
+	}
+
+	rollout.State = entities.RolloutStateActive
+	rollout.HaltReason = nil
+	if err := uc.notifyStage(rollout); err != nil {
+		return nil, err
+	}
+	return uc.describeRollout(rollout)
+}
+
+// CancelRollout ends an active or halted rollout and cancels the updates that
+// were never sent.
+func (uc *FirmwareUseCase) CancelRollout(id uint) (*entities.FirmwareRolloutResponseDto, error) {
+	rollout, err := uc.loadRollout(id)
+	if err != nil {
+		return nil, err
+	}
+	if rollout.State != entities.RolloutStateActive && rollout.State != entities.RolloutStateHalted {
+		return nil, fmt.Errorf("%w: rollout is %s", ErrRolloutState, rollout.State)
+	}
+	if err := uc.transitionRollout(id, rollout.State, entities.RolloutStateCancelled, rollout.HaltReason); err != nil {
+		return nil, err
+	}
+	if _, err := uc.firmwareRepo.CancelPendingUpdates(id); err != nil {
+		return nil, err
+	}
+	return uc.GetRollout(id)
+}
+
+func (uc *FirmwareUseCase) transitionRollout(id uint, from entities.RolloutStateEnum, to entities.RolloutStateEnum, reason *string) error {
+	if _, err := uc.loadRollout(id); err != nil {
+		return err
+	}
+	changed, err := uc.firmwareRepo.TransitionRollout(id, from, to, reason)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return fmt.Errorf("%w: rollout is not %s", ErrRolloutState, from)
+	}
+	return nil
+}
+
+func (uc *FirmwareUseCase) GetBoardFirmware(userID uint, boardID string) (*entities.BoardFirmwareDto, error) {
+	board, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	updates, err := uc.firmwareRepo.FindUpdatesByBoardID(boardID, maxBoardFirmwareUpdates)
+	if err != nil {
+		return nil, err
+	}
+	return &entities.BoardFirmwareDto{
+		BoardID:         board.BoardID,
+		HardwareModel:   board.HardwareModel,
+		FirmwareVersion: board.FirmwareVersion,
+		Updates:         updates,
+	}, nil
+}
+
+// OpenDownload checks a signed download link and returns the release to
+// serve. The board's update moves to downloading when it fetches the image.
+func (uc *FirmwareUseCase) OpenDownload(releaseID uint, boardID string, expires int64, signature string) (*entities.FirmwareRelease, error) {
+	if boardID == "" || time.Now().Unix() > expires {
+		return nil, ErrInvalidDownloadLink
+	}
+	expected := uc.signDownload(releaseID, boardID, expires)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return nil, ErrInvalidDownloadLink
+	}
+
+	release, err := uc.GetRelease(releaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	update, err := uc.firmwareRepo.FindUnfinishedUpdate(boardID)
+	if err != nil {
+		return nil, err
+	}
+	if update != nil && update.ReleaseID == release.ID && update.State == entities.FirmwareUpdateNotified {
+		update.State = entities.FirmwareUpdateDownloading
+		if err := uc.firmwareRepo.SaveUpdate(update); err != nil {
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// HandleProgress records a report from the board's OTA topic. Reports for
+// updates that already finished are ignored.
+func (uc *FirmwareUseCase) HandleProgress(boardID string, progress entities.FirmwareProgressDto) (*entities.FirmwareUpdate, error) {
+	var update *entities.FirmwareUpdate
+	var err error
+	if progress.UpdateID != nil {
+		update, err = uc.firmwareRepo.FindUpdateByID(*progress.UpdateID)
+		if update != nil && update.BoardID != boardID {
+			update = nil
+		}
+	} else {
+		update, err = uc.firmwareRepo.FindUnfinishedUpdate(boardID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if update == nil {
+		return nil, ErrFirmwareUpdateNotFound
+	}
+	if update.State.Finished() {
+		return update, nil
+	}
+
+	switch state := entities.FirmwareUpdateStateEnum(strings.ToLower(progress.State)); state {
+	case entities.FirmwareUpdateDownloading, entities.FirmwareUpdateInstalling:
+		update.State = state
+		update.Progress = min(max(progress.Progress, 0), 100)
+		return update, uc.firmwareRepo.SaveUpdate(update)
+	case entities.FirmwareUpdateSucceeded:
+		return update, uc.finishUpdate(update, entities.FirmwareUpdateSucceeded, "")
+	case entities.FirmwareUpdateFailed:
+		message := progress.Error
+		if message == "" {
+			message = "board reported the update failed"
+		}
+		return update, uc.finishUpdate(update, entities.FirmwareUpdateFailed, message)
+	}
+	return nil, fmt.Errorf("unknown OTA state %q", progress.State)
+}
+
+// HandleReportedVersion completes the board's update once its heartbeat
+// reports the version being installed, for firmware that does not publish
+// progress.
+func (uc *FirmwareUseCase) HandleReportedVersion(boardID string, version string) error {
+	update, err := uc.firmwareRepo.FindUnfinishedUpdate(boardID)
+	if err != nil || update == nil {
+		return err
+	}
+	release, err := uc.firmwareRepo.FindReleaseByID(update.ReleaseID)
+	if err != nil || release == nil || release.Version != version {
+		return err
+	}
+	return uc.finishUpdate(update, entities.FirmwareUpdateSucceeded, "")
+}
+
+// HandleCommandAck fails the update when the board refuses the ota command.
+func (uc *FirmwareUseCase) HandleCommandAck(command *entities.BoardCommand) error {
+	if command.Command != entities.FirmwareCommand || command.State != entities.CommandStateFailed {
+		return nil
+	}
+	update, err := uc.firmwareRepo.FindUpdateByCorrelationID(command.CorrelationID)
+	if err != nil || update == nil || update.State.Finished() {
+		return err
+	}
+
+	message := "board refused the update"
+	if command.ErrorMessage != nil {
+		message += ": " + *command.ErrorMessage
+	}
+	return uc.finishUpdate(update, entities.FirmwareUpdateFailed, message)
+}
+
+// ExpireStaleUpdates fails the updates whose board has not reported a result
+// within timeout of being notified.
+func (uc *FirmwareUseCase) ExpireStaleUpdates(timeout time.Duration) (int, error) {
+	updates, err := uc.firmwareRepo.FindStaleUpdates(time.Now().Add(-timeout))
+	if err != nil {
+		return 0, err
+	}
+	for i := range updates {
+		message := fmt.Sprintf("no result within %s", timeout)
+		if err := uc.finishUpdate(&updates[i], entities.FirmwareUpdateFailed, message); err != nil {
+			return i, err
+		}
+	}
+	return len(updates), nil
+}
+
+func (uc *FirmwareUseCase) finishUpdate(update *entities.FirmwareUpdate, state entities.FirmwareUpdateStateEnum, message string) error {
+	now := time.Now()
+	update.State = state
+	update.FinishedAt = &now
+	if state == entities.FirmwareUpdateSucceeded {
+		update.Progress = 100
+		update.ErrorMessage = nil
+	} else if message != "" {
+		update.ErrorMessage = &message
+	}
+	if err := uc.firmwareRepo.SaveUpdate(update); err != nil {
+		return err
+	}
+	return uc.evaluateRollout(update.RolloutID)
+}
+
+// evaluateRollout halts an active rollout once too many of its updates have
+// failed, and completes it when the last stage has nothing left to finish.
+func (uc *FirmwareUseCase) evaluateRollout(id uint) error {
+	rollout, err := uc.firmwareRepo.FindRolloutByID(id)
+	if err != nil || rollout == nil || rollout.State != entities.RolloutStateActive {
+		return err
+	}
+	counts, err := uc.firmwareRepo.CountUpdatesByState(id)
+	if err != nil {
+		return err
+	}
+
+	// Failures acknowledged when the rollout was last resumed no longer count.
+	failed := counts[entities.FirmwareUpdateFailed] - rollout.AcknowledgedFailed
+	finished := counts[entities.FirmwareUpdateFailed] + counts[entities.FirmwareUpdateSucceeded] - rollout.AcknowledgedFinished
+	if finished > 0 && finished >= rollout.MinFailureSamples && float64(failed)/float64(finished) > rollout.FailureThreshold {
+		reason := fmt.Sprintf("%d of %d finished updates failed, above the %.0f%% threshold", failed, finished, rollout.FailureThreshold*100)
+		halted, err := uc.firmwareRepo.TransitionRollout(id, entities.RolloutStateActive, entities.RolloutStateHalted, &reason)
+		if halted {
+			log.Printf("Halted firmware rollout %d: %s", id, reason)
+		}
+		return err
+	}
+
+	stages, err := rolloutStages(rollout)
+	if err != nil {
+		return err
+	}
+	unfinished := counts[entities.FirmwareUpdatePending] + counts[entities.FirmwareUpdateNotified] +
+		counts[entities.FirmwareUpdateDownloading] + counts[entities.FirmwareUpdateInstalling]
+	if rollout.StageIndex == len(stages)-1 && unfinished == 0 {
+		_, err := uc.firmwareRepo.TransitionRollout(id, entities.RolloutStateActive, entities.RolloutStateCompleted, nil)
+		return err
+	}
+	return nil
+}
+
+func (uc *FirmwareUseCase) loadRollout(id uint) (*entities.FirmwareRollout, error) {
+	rollout, err := uc.firmwareRepo.FindRolloutByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if rollout == nil {
+		return nil, ErrRolloutNotFound
+	}
+	return rollout, nil
+}
+
+func (uc *FirmwareUseCase) describeRollout(rollout *entities.FirmwareRollout) (*entities.FirmwareRolloutResponseDto, error) {
+	stages, err := rolloutStages(rollout)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := uc.firmwareRepo.CountUpdatesByState(rollout.ID)
+	if err != nil {
+		return nil, err
+	}
+	boards := 0
+	for _, count := range counts {
+		boards += count
+	}
+	rate, _ := failureRate(counts)
+
+	return &entities.FirmwareRolloutResponseDto{
+		ID:                rollout.ID,
+		Release:           rollout.Release,
+		State:             rollout.State,
+		Stages:            stages,
+		StageIndex:        rollout.StageIndex,
+		StagePercent:      stages[rollout.StageIndex],
+		StageStartedAt:    rollout.StageStartedAt,
+		FailureThreshold:  rollout.FailureThreshold,
+		MinFailureSamples: rollout.MinFailureSamples,
+		FailureRate:       rate,
+		Boards:            boards,
+		Updates:           counts,
+		HaltReason:        rollout.HaltReason,
+		CreatedBy:         rollout.CreatedBy,
+		CreatedAt:         rollout.CreatedAt,
+		CompletedAt:       rollout.CompletedAt,
+	}, nil
+}
+
+func rolloutStages(rollout *entities.FirmwareRollout) ([]int, error) {
+	var stages []int
+	if err := json.Unmarshal([]byte(rollout.Stages), &stages); err != nil {
+		return nil, fmt.Errorf("rollout %d has invalid stages: %w", rollout.ID, err)
+	}
+	if rollout.StageIndex < 0 || rollout.StageIndex >= len(stages) {
+		return nil, fmt.Errorf("rollout %d is at stage %d of %d", rollout.ID, rollout.StageIndex, len(stages))
+	}
+	return stages, nil
+}
+
+// failureRate returns the share of finished updates that failed and how many
+// updates have finished. Cancelled updates do not count either way.
+func failureRate(counts map[entities.FirmwareUpdateStateEnum]int) (float64, int) {
+	finished := counts[entities.FirmwareUpdateSucceeded] + counts[entities.FirmwareUpdateFailed]
+	if finished == 0 {
+		return 0, 0
+	}
+	return float64(counts[entities.FirmwareUpdateFailed]) / float64(finished), finished
+}
@@ -0,0 +1,40 @@
+package usecases
+
+import (
+	"errors"
+	"main/duckweed/entities"
+	"time"
+)
+
+var (
+	// ErrReplayRejected is returned when a replayed message fails validation again.
+	ErrReplayRejected = errors.New("message was rejected again")
+	// ErrInvalidTelemetry is returned when a telemetry payload cannot be decoded.
+	ErrInvalidTelemetry = errors.New("invalid telemetry payload")
+	// ErrBoardUnclaimed is returned for telemetry from a board no user has claimed.
+	ErrBoardUnclaimed = errors.New("board has not been claimed by any user")
+	// ErrIngestionBusy is returned when telemetry could not be queued or
+	// stored in time. The sender should retry later.
+	ErrIngestionBusy = errors.New("telemetry could not be stored, retry later")
+)
+
+// IngestionPipeline is the telemetry ingestion path as seen from the HTTP side.
+type IngestionPipeline interface {
+	IngestionMetrics() entities.IngestionMetricsDto
+	// ReplayTelemetry feeds a stored message back through validation and
+	// persistence and returns the number of readings stored, once they are.
+	// Readings without a device timestamp are stamped with receivedAt.
+	ReplayTelemetry(topic string, payload []byte, receivedAt time.Time) (int, error)
+	// IngestTelemetry runs a payload received outside MQTT through the same
+	// validation and persistence as a telemetry message of the board, and
+	// returns once the readings are stored. A rejected payload is reported
+	// to the caller instead of dead-lettered. On ErrIngestionBusy after a
+	// timeout the readings may still be stored, so a retry is only safe for
+	// readings with a dedup key.
+	IngestTelemetry(boardID string, payload []byte, receivedAt time.Time) (int, error)
+	// InvalidateBoard forgets what ingestion cached about the board,
+	// including its alert state, so a board disabled, enabled or unclaimed
+	// here takes effect at once. Other replicas pick the change up when
+	// their cache expires; a disabled board is also checked at every flush.
+	InvalidateBoard(boardID string)
+}
@@ -0,0 +1,43 @@
+package usecases
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"time"
+)
+
+type LivenessUseCaseInterface interface {
+	SweepOverdueBoards() (leader bool, boards []entities.Board, err error)
+}
+
+// LivenessUseCase marks boards that stopped reporting as inactive. Every
+// replica calls it on a timer, but only the one holding the leader lock
+// sweeps.
+type LivenessUseCase struct {
+	livenessRepo repositories.BoardLivenessRepositoryInterface
+	leaderLock   repositories.LeaderLockInterface
+	policy       repositories.LivenessPolicy
+}
+
+func NewLivenessUseCase(
+	livenessRepo repositories.BoardLivenessRepositoryInterface,
+	leaderLock repositories.LeaderLockInterface,
+	policy repositories.LivenessPolicy,
+) LivenessUseCaseInterface {
+	return &LivenessUseCase{
+		livenessRepo: livenessRepo,
+		leaderLock:   leaderLock,
+		policy:       policy,
+	}
+}
+
+// SweepOverdueBoards returns whether this replica leads and, if so, the boards
+// it marked inactive.
+func (uc *LivenessUseCase) SweepOverdueBoards() (bool, []entities.Board, error) {
+	leader, err := uc.leaderLock.TryAcquire()
+	if err != nil || !leader {
+		return false, nil, err
+	}
+	boards, err := uc.livenessRepo.MarkOverdueInactive(time.Now(), uc.policy)
+	return true, boards, err
+}
@@ -0,0 +1,121 @@
+package usecases
+
+import (
+	"crypto/subtle"
+	"errors"
+	"main/duckweed/repositories"
+	"strings"
+)
+
+// ErrMqttAuthUnavailable is returned while the topic layout is not known yet,
+// so topic access cannot be decided.
+var ErrMqttAuthUnavailable = errors.New("mqtt auth hook is not ready")
+
+type MqttAccessEnum string
+
+const (
+	// MqttAccessRead is a message being delivered to the client.
+	MqttAccessRead      MqttAccessEnum = "read"
+	MqttAccessPublish   MqttAccessEnum = "publish"
+	MqttAccessSubscribe MqttAccessEnum = "subscribe"
+)
+
+// MqttAuthOptions identifies the backend's own MQTT client.
+type MqttAuthOptions struct {
+	BackendClientID string
+	BackendUsername string
+	BackendPassword string
+}
+
+type MqttAuthUseCaseInterface interface {
+	Authenticate(username string, password string, clientID string) (bool, error)
+	Authorize(username string, clientID string, topic string, access MqttAccessEnum) (bool, error)
+}
+
+// MqttAuthUseCase answers the broker's auth hook. A board logs in with its
+// board ID and the MQTT password it was provisioned with, and may only
+// publish to its own uplink topics and subscribe to its own command topic.
+// The backend may subscribe to everything and publish commands to any board.
+type MqttAuthUseCase struct {
+	boardRepo repositories.BoardRepositoryInterface
+	topics    BoardTopics
+	options   MqttAuthOptions
+}
+
+func NewMqttAuthUseCase(
+	boardRepo repositories.BoardRepositoryInterface,
+	topics BoardTopics,
+	options MqttAuthOptions,
+) MqttAuthUseCaseInterface {
+	return &MqttAuthUseCase{
+		boardRepo: boardRepo,
+		topics:    topics,
+		options:   options,
+	}
+}
+
+func (uc *MqttAuthUseCase) Authenticate(username string, password string, clientID string) (bool, error) {
+	if username == "" || password == "" {
+		return false, nil
+	}
+	if username == uc.options.BackendUsername {
+		// Without a configured password the backend connects anonymously,
+		// so nobody may log in under its name.
+		if uc.options.BackendPassword == "" || clientID != uc.options.BackendClientID {
+			return false, nil
+		}
+		return subtle.ConstantTimeCompare([]byte(password), []byte(uc.options.BackendPassword)) == 1, nil
+	}
+
+	board, err := uc.boardRepo.FindByBoardID(username)
+	if err != nil {
+		return false, err
+	}
+	if board == nil || board.MqttPasswordHash == nil {
+		return false, nil
+	}
+	passwordHash := hashSecret(password)
+	return subtle.ConstantTimeCompare([]byte(passwordHash), []byte(*board.MqttPasswordHash)) == 1, nil
+}
+
+// Authorize decides whether an authenticated client may use a topic. For
+// subscriptions the topic is the filter, so a board asking for a wildcard
+// is refused.
+func (uc *MqttAuthUseCase) Authorize(username string, clientID string, topic string, access MqttAccessEnum) (bool, error) {
+	if uc.topics == nil {
+		return false, ErrMqttAuthUnavailable
+	}
+	if username == "" || topic == "" {
+		return false, nil
+	}
+
+	if uc.isBackend(username, clientID) {
+		switch access {
+		case MqttAccessRead, MqttAccessSubscribe:
+			return true, nil
+		case MqttAccessPublish:
+			_, ok := uc.topics.DownlinkBoard(topic)
+			return ok, nil
+		}
+		return false, nil
+	}
+
+	if strings.ContainsAny(topic, "+#") {
+		return false, nil
+	}
+	switch access {
+	case MqttAccessPublish:
+		boardID, ok := uc.topics.UplinkBoard(topic)
+		return ok && boardID == username, nil
+	case MqttAccessRead, MqttAccessSubscribe:
+		boardID, ok := uc.topics.DownlinkBoard(topic)
+		return ok && boardID == username, nil
+	}
+	return false, nil
+}
+
+func (uc *MqttAuthUseCase) isBackend(username string, clientID string) bool {
+	return uc.options.BackendPassword != "" &&
+		username == uc.options.BackendUsername &&
+		clientID == uc.options.BackendClientID
+}
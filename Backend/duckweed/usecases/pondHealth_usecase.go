@@ -23,11 +23,12 @@ type PondHealthUseCase interface {
 
 
 type pondHealthUseCase struct {
-	repo repositories.PondHealthRepository
+	repo     repositories.PondHealthRepository
+	pondRepo repositories.PondRepositoryInterface
 }
 
-func NewpondHealthUseCase(repo repositories.PondHealthRepository) PondHealthUseCase {
-	return &pondHealthUseCase{repo: repo}
+func NewpondHealthUseCase(repo repositories.PondHealthRepository, pondRepo repositories.PondRepositoryInterface) PondHealthUseCase {
+	return &pondHealthUseCase{repo: repo, pondRepo: pondRepo}
 }
 
 func (u *pondHealthUseCase) GetPondHealthByID(id uint) (*entities.PondHealth, error) {
@@ -42,12 +43,25 @@ func (u *pondHealthUseCase) GetPondHealthByUserID(userId uint) ([]entities.PondH
 	return u.repo.FindByUserID(userId)
 }
 
+// PostPondHealth stores a scan. A scan that names the pond it was taken of
+// must name one of the user's own ponds.
 func (u *pondHealthUseCase) PostPondHealth(dto *entities.InsertPondHealthDto) (*entities.PondHealth, error){
+	if dto.ScannedPondID != nil {
+		scannedPond, err := u.pondRepo.FindByID(*dto.ScannedPondID)
+		if err != nil {
+			return nil, err
+		}
+		if scannedPond == nil || scannedPond.UserID != dto.UserID {
+			return nil, ErrPondNotFound
+		}
+	}
+
 	pond := entities.PondHealth{
 		UserID: &dto.UserID,
 		Picture: &dto.Picture,
 		Result: &dto.Result,
 		Data: time.Now(),
+		ScannedPondID: dto.ScannedPondID,
 	}
 
 	err := u.repo.PostPondHealth(pond)
@@ -0,0 +1,353 @@
+package usecases
+
+import (
+	"errors"
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrPondNotFound is also returned for another user's pond.
+	ErrPondNotFound = errors.New("pond not found")
+	ErrInvalidPond  = errors.New("invalid pond")
+)
+
+type PondUseCaseInterface interface {
+	CreatePond(userID uint, dto entities.InsertPondDto) (*entities.PondResponseDto, error)
+	GetPonds(userID uint) ([]entities.PondResponseDto, error)
+	GetPond(userID uint, pondID uint) (*entities.PondResponseDto, error)
+	UpdatePond(userID uint, pondID uint, dto entities.UpdatePondDto) (*entities.PondResponseDto, error)
+	DeletePond(userID uint, pondID uint) error
+	AssignBoard(userID uint, boardID string, dto entities.AssignBoardPondDto) (*entities.BoardResponseDto, error)
+	UnassignBoard(userID uint, boardID string) (*entities.BoardResponseDto, error)
+	GetOverview(userID uint, pondID uint) (*entities.PondOverviewDto, error)
+}
+
+// PondUseCase manages a user's ponds and the boards assigned to them. A pond
+// belongs to the user who created it; boards are assigned by their owner.
+type PondUseCase struct {
+	pondRepo              repositories.PondRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	sensorLogRepo         repositories.SensorLogRepositoryInterface
+	alertRepo             repositories.AlertRepositoryInterface
+	pondHealthRepo        repositories.PondHealthRepository
+}
+
+func NewPondUseCase(
+	pondRepo repositories.PondRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	sensorLogRepo repositories.SensorLogRepositoryInterface,
+	alertRepo repositories.AlertRepositoryInterface,
+	pondHealthRepo repositories.PondHealthRepository,
+) PondUseCaseInterface {
+	return &PondUseCase{
+		pondRepo:              pondRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		sensorLogRepo:         sensorLogRepo,
+		alertRepo:             alertRepo,
+		pondHealthRepo:        pondHealthRepo,
+	}
+}
+
+func (uc *PondUseCase) CreatePond(userID uint, dto entities.InsertPondDto) (*entities.PondResponseDto, error) {
+	name := strings.TrimSpace(dto.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidPond)
+	}
+	timezone := "UTC"
+	if dto.Timezone != nil {
+		timezone = strings.TrimSpace(*dto.Timezone)
+	}
+	if err := validateTimezone(timezone); err != nil {
+		return nil, err
+	}
+
+	pond := &entities.Pond{
+		UserID:        userID,
+		Name:          name,
+		VolumeLiters:  dto.VolumeLiters,
+		SurfaceAreaM2: dto.SurfaceAreaM2,
+		Location:      trimmedOrNil(dto.Location),
+		Latitude:      dto.Latitude,
+		Longitude:     dto.Longitude,
+		Species:       trimmedOrNil(dto.Species),
+		Strain:        trimmedOrNil(dto.Strain),
+		Timezone:      timezone,
+	}
+	if err := uc.pondRepo.Create(pond); err != nil {
+		return nil, fmt.Errorf("could not create pond: %w", err)
+	}
+
+	response := toPondResponseDto(*pond, nil)
+	return &response, nil
+}
+
+func (uc *PondUseCase) GetPonds(userID uint) ([]entities.PondResponseDto, error) {
+	ponds, err := uc.pondRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	response := make([]entities.PondResponseDto, 0, len(ponds))
+	for _, pond := range ponds {
+		boards, err := uc.boardRepo.FindByPondID(pond.ID)
+		if err != nil {
+			return nil, err
+		}
+		response = append(response, toPondResponseDto(pond, boards))
+	}
+	return response, nil
+}
+
+func (uc *PondUseCase) GetPond(userID uint, pondID uint) (*entities.PondResponseDto, error) {
+	pond, err := uc.findPond(userID, pondID)
+	if err != nil {
+		return nil, err
+	}
+	boards, err := uc.boardRepo.FindByPondID(pond.ID)
+	if err != nil {
+		return nil, err
+	}
+	response := toPondResponseDto(*pond, boards)
+	return &response, nil
+}
+
+// UpdatePond changes the fields set in dto. An empty string clears an
+// optional text field.
+func (uc *PondUseCase) UpdatePond(userID uint, pondID uint, dto entities.UpdatePondDto) (*entities.PondResponseDto, error) {
+	pond, err := uc.findPond(userID, pondID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if dto.Name != nil {
+		name := strings.TrimSpace(*dto.Name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: name cannot be empty", ErrInvalidPond)
+		}
+		updates["name"] = name
+	}
+	if dto.Timezone != nil {
+		timezone := strings.TrimSpace(*dto.Timezone)
+		if err := validateTimezone(timezone); err != nil {
+			return nil, err
+		}
+		updates["timezone"] = timezone
+	}
+	for column, value := range map[string]*float64{
+		"volume_liters":   dto.VolumeLiters,
+		"surface_area_m2": dto.SurfaceAreaM2,
+		"latitude":        dto.Latitude,
+		"longitude":       dto.Longitude,
+	} {
+		if value != nil {
+			updates[column] = value
+		}
+	}
+	for column, value := range map[string]*string{
+		"location": dto.Location,
+		"species":  dto.Species,
+		"strain":   dto.Strain,
+	} {
+		if value != nil {
+			updates[column] = trimmedOrNil(value)
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := uc.pondRepo.Update(pond, updates); err != nil {
+			return nil, fmt.Errorf("could not update pond: %w", err)
+		}
+	}
+
+	boards, err := uc.boardRepo.FindByPondID(pond.ID)
+	if err != nil {
+		return nil, err
+	}
+	response := toPondResponseDto(*pond, boards)
+	return &response, nil
+}
+
+// DeletePond deletes the pond. Its boards and health scans are kept and
+// become unassigned.
+func (uc *PondUseCase) DeletePond(userID uint, pondID uint) error {
+	pond, err := uc.findPond(userID, pondID)
+	if err != nil {
+		return err
+	}
+	return uc.pondRepo.Delete(pond)
+}
+
+// AssignBoard moves a board the user owns into one of the user's ponds.
+func (uc *PondUseCase) AssignBoard(userID uint, boardID string, dto entities.AssignBoardPondDto) (*entities.BoardResponseDto, error) {
+	board, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	pond, err := uc.findPond(userID, dto.PondID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uc.boardRepo.UpdatePond(boardID, &pond.ID); err != nil {
+		return nil, fmt.Errorf("could not assign board: %w", err)
+	}
+	board.PondID = &pond.ID
+
+	response := toBoardResponseDto(*board)
+	return &response, nil
+}
+
+func (uc *PondUseCase) UnassignBoard(userID uint, boardID string) (*entities.BoardResponseDto, error) {
+	board, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	if board.PondID != nil {
+		if err := uc.boardRepo.UpdatePond(boardID, nil); err != nil {
+			return nil, fmt.Errorf("could not unassign board: %w", err)
+		}
+		board.PondID = nil
+	}
+
+	response := toBoardResponseDto(*board)
+	return &response, nil
+}
+
+// GetOverview combines the latest reading of each board in the pond, the
+// alerts open on them and the latest health scan. Boards in the pond the user
+// no longer has access to are left out.
+func (uc *PondUseCase) GetOverview(userID uint, pondID uint) (*entities.PondOverviewDto, error) {
+	pond, err := uc.findPond(userID, pondID)
+	if err != nil {
+		return nil, err
+	}
+
+	pondBoards, err := uc.boardRepo.FindByPondID(pond.ID)
+	if err != nil {
+		return nil, err
+	}
+	relationships, err := uc.boardRelationshipRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading board relationships: %w", err)
+	}
+	accessible := make(map[string]bool, len(relationships))
+	for _, relationship := range relationships {
+		accessible[relationship.BoardID] = relationship.Grants(entities.BoardRoleViewer)
+	}
+
+	var boards []entities.Board
+	var boardIDs []string
+	for _, board := range pondBoards {
+		if accessible[board.BoardID] {
+			boards = append(boards, board)
+			boardIDs = append(boardIDs, board.BoardID)
+		}
+	}
+
+	overview := &entities.PondOverviewDto{
+		Pond:         toPondResponseDto(*pond, boards),
+		Boards:       make([]entities.PondBoardDto, 0, len(boards)),
+		ActiveAlerts: []entities.AlertIncidentResponseDto{},
+		GeneratedAt:  time.Now(),
+	}
+
+	if len(boardIDs) > 0 {
+		logs, err := uc.sensorLogRepo.FindLatestByBoardIDs(boardIDs)
+		if err != nil {
+			return nil, fmt.Errorf("error loading latest readings: %w", err)
+		}
+		latest := make(map[string]entities.SensorLogResponseDto, len(logs))
+		for _, log := range logs {
+			if log.BoardID != nil {
+				latest[*log.BoardID] = toSensorLogResponseDto(log, nil)
+			}
+		}
+		for _, board := range boards {
+			pondBoard := entities.PondBoardDto{BoardResponseDto: toBoardResponseDto(board)}
+			if reading, ok := latest[board.BoardID]; ok {
+				pondBoard.LatestReading = &reading
+			}
+			overview.Boards = append(overview.Boards, pondBoard)
+		}
+
+		incidents, err := uc.alertRepo.FindOpenIncidentsByBoardIDs(boardIDs)
+		if err != nil {
+			return nil, fmt.Errorf("error loading alerts: %w", err)
+		}
+		for _, incident := range incidents {
+			overview.ActiveAlerts = append(overview.ActiveAlerts, toAlertIncidentResponseDto(incident))
+		}
+	}
+
+	scan, err := uc.pondHealthRepo.FindLatestByScannedPondID(pond.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading health scans: %w", err)
+	}
+	overview.LatestHealthScan = scan
+	return overview, nil
+}
+
+// findPond loads a pond of the user. Other users' ponds are reported as not
+// found.
+func (uc *PondUseCase) findPond(userID uint, pondID uint) (*entities.Pond, error) {
+	pond, err := uc.pondRepo.FindByID(pondID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading pond: %w", err)
+	}
+	if pond == nil || pond.UserID != userID {
+		return nil, ErrPondNotFound
+	}
+	return pond, nil
+}
+
+func validateTimezone(timezone string) error {
+	if timezone == "" {
+		return fmt.Errorf("%w: timezone cannot be empty", ErrInvalidPond)
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidPond, timezone)
+	}
+	return nil
+}
+
+func trimmedOrNil(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+func toPondResponseDto(pond entities.Pond, boards []entities.Board) entities.PondResponseDto {
+	boardIDs := make([]string, 0, len(boards))
+	for _, board := range boards {
+		boardIDs = append(boardIDs, board.BoardID)
+	}
+	return entities.PondResponseDto{
+		ID:            pond.ID,
+		UserID:        pond.UserID,
+		Name:          pond.Name,
+		VolumeLiters:  pond.VolumeLiters,
+		SurfaceAreaM2: pond.SurfaceAreaM2,
+		Location:      pond.Location,
+		Latitude:      pond.Latitude,
+		Longitude:     pond.Longitude,
+		Species:       pond.Species,
+		Strain:        pond.Strain,
+		Timezone:      pond.Timezone,
+		BoardIDs:      boardIDs,
+		CreatedAt:     pond.CreatedAt,
+		UpdatedAt:     pond.UpdatedAt,
+	}
+}
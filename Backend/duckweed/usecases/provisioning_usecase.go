@@ -0,0 +1,215 @@
+package usecases
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBoardExists         = errors.New("board is already provisioned")
+	ErrInvalidProvisioning = errors.New("invalid provisioning request")
+	// ErrInvalidClaimCode is returned for a missing or wrong claim code.
+	ErrInvalidClaimCode = errors.New("invalid claim code")
+	// ErrBoardAlreadyClaimed is returned once the board's claim code has been
+	// used. An admin can issue a new one, for example for a resold board.
+	ErrBoardAlreadyClaimed = errors.New("board has already been claimed")
+)
+
+// claimCodeAlphabet is Crockford's base32: no I, L, O or U, so codes survive
+// being read off a label, and every character fits a QR code's alphanumeric
+// mode.
+const claimCodeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// claimCodeLength gives 80 random bits, printed as four groups of four.
+const claimCodeLength = 16
+
+type ProvisioningUseCaseInterface interface {
+	ProvisionBoards(dto entities.ProvisionBoardsDto) ([]entities.ProvisionedBoardDto, error)
+	IssueClaimCode(boardID string) (*entities.ProvisionedBoardDto, error)
+	RotateMqttCredentials(boardID string) (*entities.ProvisionedBoardDto, error)
+}
+
+// ProvisioningUseCase registers boards at the factory. Each board gets a
+// one-time claim code, which a user needs to add it to their account, and
+// its own MQTT password.
+type ProvisioningUseCase struct {
+	boardRepo repositories.BoardRepositoryInterface
+}
+
+func NewProvisioningUseCase(boardRepo repositories.BoardRepositoryInterface) ProvisioningUseCaseInterface {
+	return &ProvisioningUseCase{boardRepo: boardRepo}
+}
+
+// ProvisionBoards imports a batch of boards. The whole batch is rejected when
+// any board ID is repeated or already exists.
+func (uc *ProvisioningUseCase) ProvisionBoards(dto entities.ProvisionBoardsDto) ([]entities.ProvisionedBoardDto, error) {
+	boardIDs := make([]string, 0, len(dto.Boards))
+	seen := make(map[string]bool, len(dto.Boards))
+	for _, board := range dto.Boards {
+		if seen[board.BoardID] {
+			return nil, fmt.Errorf("%w: board %s is listed twice", ErrInvalidProvisioning, board.BoardID)
+		}
+		seen[board.BoardID] = true
+		boardIDs = append(boardIDs, board.BoardID)
+	}
+
+	existing, err := uc.boardRepo.FindExistingBoardIDs(boardIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrBoardExists, strings.Join(existing, ", "))
+	}
+
+	now := time.Now()
+	boards := make([]entities.Board, 0, len(dto.Boards))
+	provisioned := make([]entities.ProvisionedBoardDto, 0, len(dto.Boards))
+	for _, item := range dto.Boards {
+		claimCode, err := generateClaimCode()
+		if err != nil {
+			return nil, err
+		}
+		password, err := generateMqttPassword()
+		if err != nil {
+			return nil, err
+		}
+		claimCodeHash := hashSecret(normalizeClaimCode(claimCode))
+		passwordHash := hashSecret(password)
+
+		boards = append(boards, entities.Board{
+			BoardID:          item.BoardID,
+			BoardName:        item.BoardName,
+			HardwareModel:    item.HardwareModel,
+			ClaimCodeHash:    &claimCodeHash,
+			MqttPasswordHash: &passwordHash,
+			ProvisionedAt:    &now,
+		})
+		provisioned = append(provisioned, entities.ProvisionedBoardDto{
+			BoardID:      item.BoardID,
+			ClaimCode:    claimCode,
+			QRPayload:    claimQRPayload(item.BoardID, claimCode),
+			MqttUsername: item.BoardID,
+			MqttPassword: password,
+			IssuedAt:     now,
+		})
+	}
+
+	if err := uc.boardRepo.CreateProvisioned(boards); err != nil {
+		return nil, err
+	}
+	return provisioned, nil
+}
+
+// IssueClaimCode replaces the board's claim code, so the board can be claimed
+// again after it was resold or its label was lost. Existing relationships
+// are kept, and the new code cannot be used while the board has an owner: the
+// previous owner has to unclaim it first.
+func (uc *ProvisioningUseCase) IssueClaimCode(boardID string) (*entities.ProvisionedBoardDto, error) {
+	if _, err := uc.findBoard(boardID); err != nil {
+		return nil, err
+	}
+
+	claimCode, err := generateClaimCode()
+	if err != nil {
+		return nil, err
+	}
+	claimCodeHash := hashSecret(normalizeClaimCode(claimCode))
+	if err := uc.boardRepo.UpdateClaimCode(boardID, &claimCodeHash); err != nil {
+		return nil, err
+	}
+	return &entities.ProvisionedBoardDto{
+		BoardID:   boardID,
+		ClaimCode: claimCode,
+		QRPayload: claimQRPayload(boardID, claimCode),
+		IssuedAt:  time.Now(),
+	}, nil
+}
+
+// RotateMqttCredentials gives the board a new MQTT password. The old one
+// stops working at the board's next connect.
+func (uc *ProvisioningUseCase) RotateMqttCredentials(boardID string) (*entities.ProvisionedBoardDto, error) {
+	if _, err := uc.findBoard(boardID); err != nil {
+		return nil, err
+	}
+
+	password, err := generateMqttPassword()
+	if err != nil {
+		return nil, err
+	}
+	passwordHash := hashSecret(password)
+	if err := uc.boardRepo.UpdateMqttPassword(boardID, &passwordHash); err != nil {
+		return nil, err
+	}
+	return &entities.ProvisionedBoardDto{
+		BoardID:      boardID,
+		MqttUsername: boardID,
+		MqttPassword: password,
+		IssuedAt:     time.Now(),
+	}, nil
+}
+
+func (uc *ProvisioningUseCase) findBoard(boardID string) (*entities.Board, error) {
+	board, err := uc.boardRepo.FindByBoardID(boardID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading board: %w", err)
+	}
+	if board == nil {
+		return nil, ErrBoardNotFound
+	}
+	return board, nil
+}
+
+// generateClaimCode returns a code such as 7KQ4-M2XD-9PHA-3TRC.
+func generateClaimCode() (string, error) {
+	random := make([]byte, claimCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	var code strings.Builder
+	for i, b := range random {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(claimCodeAlphabet[b&31])
+	}
+	return code.String(), nil
+}
+
+// normalizeClaimCode undoes what typing a code in by hand does to it: case,
+// dashes and spaces are dropped, and the letters Crockford's base32 leaves
+// out are read as the digits they look like.
+func normalizeClaimCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "", "O", "0", "I", "1", "L", "1").Replace(code)
+	return code
+}
+
+// claimQRPayload is what the board's label encodes as a QR code, so the app
+// fills in both the board ID and the code from one scan.
+func claimQRPayload(boardID string, claimCode string) string {
+	return fmt.Sprintf("DUCKWEED:%s:%s", boardID, claimCode)
+}
+
+func generateMqttPassword() (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// hashSecret hashes a random secret for storage. Plain SHA-256 is enough
+// because the secrets are generated with at least 80 bits of entropy.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package usecases
+
+import (
+	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"sort"
+)
+
+const (
+	maxRawTelemetryPoints = 5000
+	maxTelemetryBuckets   = 5000
+)
+
+type SensorLogUseCaseInterface interface {
+	GetTelemetryHistory(userID uint, boardID string, query entities.TelemetryQueryDto) (*entities.TelemetryHistoryResponseDto, error)
+}
+
+type SensorLogUseCase struct {
+	sensorLogRepo         repositories.SensorLogRepositoryInterface
+	boardRepo             repositories.BoardRepositoryInterface
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface
+	channelResolver       ChannelResolverInterface
+}
+
+func NewSensorLogUseCase(
+	sensorLogRepo repositories.SensorLogRepositoryInterface,
+	boardRepo repositories.BoardRepositoryInterface,
+	boardRelationshipRepo repositories.BoardRelationshipRepositoryInterface,
+	channelResolver ChannelResolverInterface,
+) SensorLogUseCaseInterface {
+	return &SensorLogUseCase{
+		sensorLogRepo:         sensorLogRepo,
+		boardRepo:             boardRepo,
+		boardRelationshipRepo: boardRelationshipRepo,
+		channelResolver:       channelResolver,
+	}
+}
+
+func (uc *SensorLogUseCase) GetTelemetryHistory(userID uint, boardID string, query entities.TelemetryQueryDto) (*entities.TelemetryHistoryResponseDto, error) {
+	if !query.From.Before(query.To) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	bucket := query.Interval.Duration()
+	if bucket == 0 && query.Interval != entities.TelemetryIntervalRaw {
+		return nil, ErrInvalidInterval
+	}
+	if bucket > 0 && int64(query.To.Sub(query.From)/bucket) > maxTelemetryBuckets {
+		return nil, ErrInvalidTimeRange
+	}
+
+	if _, err := authorizeBoardAccess(uc.boardRepo, uc.boardRelationshipRepo, userID, boardID, entities.BoardRoleViewer); err != nil {
+		return nil, err
+	}
+
+	response := &entities.TelemetryHistoryResponseDto{
+		BoardID:  boardID,
+		From:     query.From,
+		To:       query.To,
+		Interval: query.Interval,
+	}
+	seen := make(map[entities.MetricEnum]bool)
+	for _, channel := range query.Channels {
+		seen[channel] = true
+	}
+
+	if query.Interval == entities.TelemetryIntervalRaw {
+		logs, err := uc.sensorLogRepo.FindByBoardIDInRange(boardID, query.From, query.To, query.Channels, maxRawTelemetryPoints)
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			for _, measurement := range log.Measurements {
+				seen[measurement.Channel] = true
+			}
+		}
+		precision, err := uc.describeChannels(response, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		response.Points = make([]entities.SensorLogResponseDto, 0, len(logs))
+		for _, log := range logs {
+			response.Points = append(response.Points, toSensorLogResponseDto(log, precision))
+		}
+		return response, nil
+	}
+
+	rows, err := uc.sensorLogRepo.AggregateByBoardID(boardID, query.From, query.To, bucket, query.Channels, query.IncludeSuspect)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		seen[row.Channel] = true
+	}
+	precision, err := uc.describeChannels(response, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Buckets = make([]entities.TelemetryBucketDto, 0)
+	for _, row := range rows {
+		last := len(response.Buckets) - 1
+		if last < 0 || !response.Buckets[last].BucketStart.Equal(row.BucketStart) {
+			response.Buckets = append(response.Buckets, entities.TelemetryBucketDto{
+				BucketStart: row.BucketStart,
+				Channels:    make(map[entities.MetricEnum]entities.MetricAggregateDto),
+			})
+			last++
+		}
+		response.Buckets[last].Channels[row.Channel] = entities.MetricAggregateDto{
+			Count: row.Count,
+			Min:   roundPointer(row.Min, precision[row.Channel]),
+			Avg:   roundPointer(row.Avg, precision[row.Channel]),
+			Max:   roundPointer(row.Max, precision[row.Channel]),
+		}
+	}
+	return response, nil
+}
+
+// describeChannels fills response.Channels for the given channels, sorted by
+// name, and returns the display precision of each.
+func (uc *SensorLogUseCase) describeChannels(response *entities.TelemetryHistoryResponseDto, seen map[entities.MetricEnum]bool) (map[entities.MetricEnum]*int, error) {
+	channels := make([]entities.MetricEnum, 0, len(seen))
+	for channel := range seen {
+		channels = append(channels, channel)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+
+	infos, err := uc.channelResolver.Describe(response.BoardID, channels)
+	if err != nil {
+		return nil, err
+	}
+	response.Channels = infos
+
+	precision := make(map[entities.MetricEnum]*int, len(infos))
+	for _, info := range infos {
+		precision[info.Channel] = info.Precision
+	}
+	return precision, nil
+}
+
+func toSensorLogResponseDto(log entities.SensorLog, precision map[entities.MetricEnum]*int) entities.SensorLogResponseDto {
+	dto := entities.SensorLogResponseDto{
+		ID:        log.ID,
+		Sequence:  log.Sequence,
+		Values:    make(map[entities.MetricEnum]float64, len(log.Measurements)),
+		CreatedAt: log.CreatedAt,
+	}
+	if log.BoardID != nil {
+		dto.BoardID = *log.BoardID
+	}
+	for _, measurement := range log.Measurements {
+		dto.Values[measurement.Channel] = roundToPrecision(measurement.Value, precision[measurement.Channel])
+		if measurement.CalibrationID != nil && measurement.RawValue != nil {
+			if dto.RawValues == nil {
+				dto.RawValues = make(map[entities.MetricEnum]float64)
+			}
+			dto.RawValues[measurement.Channel] = roundToPrecision(*measurement.RawValue, precision[measurement.Channel])
+		}
+		if measurement.Quality == entities.QualitySuspect && measurement.QualityReason != nil {
+			if dto.Suspect == nil {
+				dto.Suspect = make(map[entities.MetricEnum]entities.QualityReasonEnum)
+			}
+			dto.Suspect[measurement.Channel] = *measurement.QualityReason
+		}
+	}
+	return dto
+}
+
+func roundPointer(value *float64, precision *int) *float64 {
+	if value == nil {
+		return nil
+	}
+	rounded := roundToPrecision(*value, precision)
+	return &rounded
+}
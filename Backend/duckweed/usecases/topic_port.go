@@ -0,0 +1,11 @@
+package usecases
+
+// BoardTopics tells which board an MQTT topic belongs to, according to the
+// configured topic templates.
+type BoardTopics interface {
+	// UplinkBoard returns the board a telemetry, status, command ack or OTA
+	// topic belongs to.
+	UplinkBoard(topic string) (string, bool)
+	// DownlinkBoard returns the board a command topic belongs to.
+	DownlinkBoard(topic string) (string, bool)
+}
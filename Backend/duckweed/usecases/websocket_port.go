@@ -9,4 +9,20 @@ type WebSocketOutputPort interface {
 	// SendMessageToClient(clientID string, message string)
 	// RegisterClient(clientID string, connection interface{}) // Connection might be an interface
 	// UnregisterClient(clientID string)
-}
\ No newline at end of file
+}
+
+// AlertOutputPort pushes alert incident changes to the clients watching a board.
+type AlertOutputPort interface {
+	BroadcastAlert(boardID string, incident *entities.AlertIncident)
+}
+
+// BoardStatusOutputPort pushes a board's changed status to connected clients.
+type BoardStatusOutputPort interface {
+	BroadcastStatus(status *entities.Board)
+}
+
+// BoardAccessOutputPort closes the live connections of a user who lost
+// access to a board, which are otherwise only checked when they connect.
+type BoardAccessOutputPort interface {
+	RevokeBoardAccess(userID uint, boardID string)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GetUserIDFromToken reads the user_id claim set by GenerateJWT from the
+// token the JWT middleware stored under the "user" context key.
+func GetUserIDFromToken(c *fiber.Ctx) (uint, error) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok || token == nil {
+		return 0, errors.New("missing JWT in request context")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("invalid JWT claims")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok || userID <= 0 {
+		return 0, errors.New("user_id claim missing from JWT")
+	}
+	return uint(userID), nil
+}
@@ -11,12 +11,25 @@ import (
 func main() {
 	conf := config.GetConfig()
 	db := database.NewPostgresDatabase(conf)
+	if conf.MQTT.EmbeddedBroker {
+		broker, err := mqtt.StartEmbeddedBroker(conf.MQTT.EmbeddedBrokerAddress)
+		if err != nil {
+			log.Fatalf("Failed to start embedded MQTT broker: %v", err)
+		}
+		defer broker.Close()
+		conf.MQTT.BrokerURL = broker.URL()
+		log.Printf("Embedded MQTT broker listening on %s", conf.MQTT.BrokerURL)
+	}
 	fiberServer := server.NewFiberServer(conf, db)
 	mqttClient := mqtt.Initialize(db.GetDb(), conf, fiberServer) // Pass db, conf, and server
 	if mqttClient == nil {
 		log.Fatalf("MQTT initialization failed") // Exit if MQTT init fails
 	}
 	defer mqttClient.Disconnect(250)
+	fiberServer.SetCommandPublisher(mqtt.NewCommandPublisher(mqttClient))
+	fiberServer.SetIngestionPipeline(mqtt.Pipeline())
+	fiberServer.SetBoardTopics(mqtt.Topics())
+	fiberServer.SetBrokerConnection(mqtt.Connection())
 
 	go fiberServer.Start() // Start the initialized server
 	select {}
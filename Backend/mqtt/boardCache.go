@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"sync"
+	"time"
+
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+)
+
+// boardCacheTTL bounds how long a new claim or a new board takes to be picked
+// up by ingestion, and how long an unknown board ID is remembered as unknown.
+const boardCacheTTL = 30 * time.Second
+
+type cachedBoard struct {
+	id        uint
+	boardID   string
+	found     bool
+	claimed   bool
+	disabled  bool
+	expiresAt time.Time
+}
+
+// boardCache remembers whether a board exists, is disabled and has at least
+// one user relationship so every telemetry message does not hit the boards and
+// board_relationships tables.
+type boardCache struct {
+	db      *gorm.DB
+	mutex   sync.RWMutex
+	entries map[string]cachedBoard
+}
+
+func newBoardCache(database *gorm.DB) *boardCache {
+	return &boardCache{
+		db:      database,
+		entries: make(map[string]cachedBoard),
+	}
+}
+
+// lookup returns the cached entry for the board and whether it was a cache hit.
+func (c *boardCache) lookup(boardID string) (cachedBoard, bool, error) {
+	now := time.Now()
+
+	c.mutex.RLock()
+	entry, ok := c.entries[boardID]
+	c.mutex.RUnlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry, true, nil
+	}
+
+	entry = cachedBoard{boardID: boardID, expiresAt: now.Add(boardCacheTTL)}
+
+	var board entities.Board
+	err := c.db.Where("board_id = ?", boardID).Limit(1).Find(&board).Error
+	if err != nil {
+		return entry, false, err
+	}
+	if board.ID != 0 {
+		entry.id = board.ID
+		entry.found = true
+		entry.disabled = board.BoardStatus != nil && *board.BoardStatus == entities.BoardStatusDisabled
+
+		var relationshipCount int64
+		err := c.db.Model(&entities.BoardRelationship{}).
+			Where("board_id = ?", boardID).
+			Limit(1).
+			Count(&relationshipCount).Error
+		if err != nil {
+			return entry, false, err
+		}
+		entry.claimed = relationshipCount > 0
+	}
+
+	c.mutex.Lock()
+	c.entries[boardID] = entry
+	c.mutex.Unlock()
+	return entry, false, nil
+}
+
+// invalidate drops the cached entry so the next lookup reads the database.
+func (c *boardCache) invalidate(boardID string) {
+	c.mutex.Lock()
+	delete(c.entries, boardID)
+	c.mutex.Unlock()
+}
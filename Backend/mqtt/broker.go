@@ -0,0 +1,49 @@
+package mqtt
+
+import (
+	"log/slog"
+	"os"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+// EmbeddedBroker is an in-process MQTT broker for running the backend on a
+// laptop or in CI without Mosquitto.
+type EmbeddedBroker struct {
+	server   *mochi.Server
+	listener *listeners.TCP
+}
+
+// StartEmbeddedBroker listens on address and serves MQTT until Close. It
+// accepts every client and topic, so it must not be exposed beyond localhost.
+func StartEmbeddedBroker(address string) (*EmbeddedBroker, error) {
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		return nil, err
+	}
+
+	listener := listeners.NewTCP(listeners.Config{ID: "embedded", Address: address})
+	if err := server.AddListener(listener); err != nil {
+		return nil, err
+	}
+	if err := server.Serve(); err != nil {
+		server.Close()
+		return nil, err
+	}
+	return &EmbeddedBroker{server: server, listener: listener}, nil
+}
+
+// URL is the broker URL clients connect to. It reflects the real port when
+// the broker was started on port 0.
+func (b *EmbeddedBroker) URL() string {
+	return "tcp://" + b.listener.Address()
+}
+
+func (b *EmbeddedBroker) Close() error {
+	return b.server.Close()
+}
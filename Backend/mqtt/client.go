@@ -2,13 +2,13 @@ package mqtt
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
-	"strings"
 	"time"
 
 	"main/config"
 	"main/duckweed/entities"
+	"main/duckweed/repositories"
+	"main/duckweed/usecases"
 	"main/server"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -18,151 +18,153 @@ import (
 var db *gorm.DB
 var serverInstance server.Server
 var mqttConfig *config.Config
+var alertEngine usecases.AlertEngineInterface
+var commandUseCase usecases.BoardCommandUseCaseInterface
+var pipeline *ingestPipeline
+var deadLetterRepo repositories.DeadLetterRepositoryInterface
+var router *topicRouter
+var channelResolver usecases.ChannelResolverInterface
+var calibrator usecases.CalibratorInterface
+var firmwareUseCase usecases.FirmwareUseCaseInterface
+var connection *brokerConnection
 
 func Initialize(database *gorm.DB, conf *config.Config, s server.Server) mqtt.Client {
 	db = database
 	serverInstance = s
 	mqttConfig = conf
-	opts := mqtt.NewClientOptions().
-		AddBroker(conf.MQTT.BrokerURL).
-		SetClientID(conf.MQTT.ClientID)
-
-	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
-		fmt.Printf("Received unexpected message on topic: %s\n", msg.Topic())
-		fmt.Printf("Message: %s\n", msg.Payload())
-	})
-
-	opts.OnConnect = func(c mqtt.Client) {
-		log.Println("Connected to MQTT broker")
-		subscribe(c, conf.MQTT.TopicTelemetry, handleTelemetryMessage)
-		subscribe(c, conf.MQTT.TopicStatus, handleStatusMessage)
+	var err error
+	router, err = newTopicRouter(conf.MQTT, database)
+	if err != nil {
+		log.Fatalf("Invalid MQTT topic configuration: %v", err)
+		return nil
 	}
-
-	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
-		log.Printf("Connection to MQTT broker lost: %v", err)
-	})
-
-	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		log.Fatalf("Failed to connect to MQTT broker: %v", token.Error())
+	alertEngine = usecases.NewAlertEngine(
+		repositories.NewAlertRepository(database),
+		repositories.NewSensorRepository(database),
+		s,
+	)
+	channelResolver = usecases.NewChannelResolver(
+		repositories.NewBoardChannelRepository(database),
+		repositories.NewSensorRepository(database),
+	)
+	calibrator = usecases.NewCalibrator(repositories.NewCalibrationRepository(database))
+	commandUseCase = usecases.NewBoardCommandUseCase(
+		repositories.NewBoardCommandRepository(database),
+		repositories.NewBoardRepository(database),
+		repositories.NewBoardRelationshipRepository(database),
+		nil,
+	)
+	firmwareUseCase = usecases.NewFirmwareUseCase(
+		repositories.NewFirmwareRepository(database),
+		repositories.NewBoardRepository(database),
+		repositories.NewBoardRelationshipRepository(database),
+		repositories.NewBoardCommandRepository(database),
+		nil,
+		usecases.FirmwareOptions{},
+	)
+	deadLetterRepo = repositories.NewDeadLetterRepository(database)
+	pipeline = newIngestPipeline(database, conf.MQTT, conf.Quality)
+	pipeline.start()
+
+	var subs []*subscription
+	for _, group := range []struct {
+		templates []topicTemplate
+		qos       int
+		handler   mqtt.MessageHandler
+	}{
+		{router.telemetry, conf.MQTT.TelemetryQoS, handleTelemetryMessage},
+		{router.status, conf.MQTT.StatusQoS, handleStatusMessage},
+		{router.commandAck, conf.MQTT.CommandAckQoS, handleCommandAckMessage},
+		{router.ota, conf.MQTT.OtaQoS, handleOtaMessage},
+	} {
+		if group.qos < 0 || group.qos > 2 {
+			log.Fatalf("Invalid MQTT QoS %d, it must be 0, 1 or 2", group.qos)
+			return nil
+		}
+		for _, topic := range subscriptions(group.templates) {
+			subs = append(subs, &subscription{topic: topic, qos: byte(group.qos), handler: group.handler})
+		}
+	}
+
+	connection, err = newBrokerConnection(conf.MQTT, subs)
+	if err != nil {
+		log.Fatalf("Invalid MQTT configuration: %v", err)
 		return nil
 	}
+	// The server starts without waiting for the broker; until the first
+	// connection is made /v1/health/mqtt reports it as down.
+	go connection.connect()
+	go connection.resubscribeLoop()
 
-	return client
+	return connection.client
 }
 
-func subscribe(client mqtt.Client, topic string, handler mqtt.MessageHandler) {
-	token := client.Subscribe(topic, 1, handler)
-	token.Wait()
-	if token.Error() != nil {
-		log.Fatalf("Failed to subscribe to topic '%s': %v", topic, token.Error())
-	}
-	log.Printf("Subscribed to topic: %s\n", topic)
+// Pipeline exposes the telemetry ingestion pipeline. It is only valid after
+// Initialize.
+func Pipeline() usecases.IngestionPipeline {
+	return pipeline
 }
 
-func handleTelemetryMessage(client mqtt.Client, msg mqtt.Message) {
-	log.Printf("Received telemetry message on topic: %s", msg.Topic())
-
-	parts := strings.Split(msg.Topic(), "/")
-	if len(parts) != 3 || parts[0] != "iot" || parts[2] != "telemetry" {
-		log.Printf("Could not extract Board ID from telemetry topic: %s", msg.Topic())
-		return
-	}
-	boardIdStr := parts[1]
-
-	var dto entities.InsertSensorLogDto
-	if err := json.Unmarshal(msg.Payload(), &dto); err != nil {
-		log.Printf("Error unmarshaling telemetry payload: %v", err)
-		return
-	}
-
-	var board entities.Board
-	if err := db.Where("board_id = ?", boardIdStr).First(&board).Error; err != nil {
-		log.Printf("Board with BoardID %s not found in database", boardIdStr)
-		return
-	}
-
-	var relationshipCount int64
-	if err := db.Model(&entities.BoardRelationship{}).Where("board_id = ?", board.BoardID).Count(&relationshipCount).Error; err != nil {
-		log.Printf("Error checking board relationships for board %d: %v", board.ID, err)
-		return
-	}
+// Connection exposes the state of the broker connection. It is only valid
+// after Initialize.
+func Connection() usecases.BrokerConnection {
+	return connection
+}
 
-	if relationshipCount == 0 {
-		log.Printf("No user relationship found for board %d (%s). Discarding telemetry.", board.ID, boardIdStr)
-		return
-	}
+// Topics exposes the configured topic layout, for checking which board a
+// topic belongs to. It is only valid after Initialize.
+func Topics() usecases.BoardTopics {
+	return router
+}
 
-	sensorLog := &entities.SensorLog{
-		BoardID:     &board.BoardID,
-		Temperature: &dto.Temperature,
-		Ec:          &dto.Ec,
-		Ph:          &dto.Ph,
-	}
+func handleTelemetryMessage(client mqtt.Client, msg mqtt.Message) {
+	log.Printf("Received telemetry message on topic: %s", msg.Topic())
 
-	if err := db.Create(&sensorLog).Error; err != nil {
-		log.Printf("Failed to save sensor log: %v", err)
+	receivedAt := time.Now()
+	route, err := router.routeTelemetry(msg.Topic())
+	if err != nil {
+		log.Printf("Could not extract Board ID from telemetry topic: %v", err)
+		storeDeadLetter(msg.Topic(), nil, msg.Payload(), entities.DeadLetterMalformedTopic, err.Error(), receivedAt)
 		return
 	}
-	log.Printf("Successfully saved sensor log for BoardID: %s", *sensorLog.BoardID)
 
-	if serverInstance != nil {
-		serverInstance.BroadcastTelemetryData(board.BoardID, sensorLog)
-	} else {
-		log.Println("serverInstance is nil, cannot broadcast WebSocket message")
-	}
-
-	updateBoardLastSeen(board.ID)
+	pipeline.enqueue(ingestJob{
+		topic:      msg.Topic(),
+		boardID:    route.boardID,
+		params:     route.params,
+		payload:    msg.Payload(),
+		receivedAt: receivedAt,
+	})
 }
 
-func handleStatusMessage(client mqtt.Client, msg mqtt.Message) {
-	log.Printf("Received status message on topic: %s", msg.Topic())
+func handleCommandAckMessage(client mqtt.Client, msg mqtt.Message) {
+	log.Printf("Received command ack on topic: %s", msg.Topic())
 
-	parts := strings.Split(msg.Topic(), "/")
-	if len(parts) != 3 || parts[0] != "iot" || parts[2] != "status" {
-		log.Printf("Could not extract Board ID from status topic: %s", msg.Topic())
+	route, err := router.routeCommandAck(msg.Topic())
+	if err != nil {
+		log.Printf("Could not extract Board ID from command ack topic: %v", err)
 		return
 	}
-	boardIdStr := parts[1]
-
-	statusText := string(msg.Payload())
-	statusEnum := entities.BoardStatusEnum(statusText)
+	boardIdStr := route.boardID
 
-	switch statusEnum {
-	case entities.BoardStatusActive, entities.BoardStatusInactive, entities.BoardStatusDisabled:
-	default:
-		log.Printf("Invalid status received for board %s: %s", boardIdStr, statusText)
+	var ack entities.BoardCommandAckDto
+	if err := json.Unmarshal(msg.Payload(), &ack); err != nil {
+		log.Printf("Error unmarshaling command ack payload: %v", err)
 		return
 	}
-
-	var board entities.Board
-	if err := db.Where("board_id = ?", boardIdStr).First(&board).Error; err != nil {
-		log.Printf("Board with BoardID %s not found: %v", boardIdStr, err)
+	if ack.CorrelationID == "" {
+		log.Printf("Command ack from board %s has no correlation_id", boardIdStr)
 		return
 	}
 
-	now := time.Now()
-	board.BoardStatus = &statusEnum
-	board.LastSeen = &now
-
-	if err := db.Save(&board).Error; err != nil {
-		log.Printf("Failed to update board status for BoardID %s: %v", boardIdStr, err)
+	command, err := commandUseCase.HandleAck(boardIdStr, ack)
+	if err != nil {
+		log.Printf("Failed to record ack %s for board %s: %v", ack.CorrelationID, boardIdStr, err)
 		return
 	}
+	log.Printf("Command %s for board %s is now %s", command.CorrelationID, boardIdStr, command.State)
 
-	log.Printf("Updated status for board %s to %s", boardIdStr, statusText)
-
-	if serverInstance != nil {
-		serverInstance.BroadcastStatus(&board)
-	}
-}
-
-func updateBoardLastSeen(boardID uint) {
-	now := time.Now()
-	result := db.Model(&entities.Board{}).Where("id = ?", boardID).Update("last_seen", &now)
-	if result.Error != nil {
-		log.Printf("Failed to update LastSeen for board %d: %v", boardID, result.Error)
-	} else if result.RowsAffected > 0 {
-		log.Printf("Updated LastSeen for board %d", boardID)
+	if err := firmwareUseCase.HandleCommandAck(command); err != nil {
+		log.Printf("Failed to record refused firmware update for board %s: %v", boardIdStr, err)
 	}
 }
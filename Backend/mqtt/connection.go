@@ -0,0 +1,318 @@
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"main/config"
+	"main/duckweed/entities"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	subscribeTimeout = 10 * time.Second
+	// resubscribeInterval is how often subscriptions that failed are tried
+	// again while the connection is up.
+	resubscribeInterval = 15 * time.Second
+)
+
+type subscription struct {
+	topic         string
+	qos           byte
+	handler       mqtt.MessageHandler
+	subscribed    bool
+	err           *string
+	lastAttemptAt *time.Time
+}
+
+// brokerConnection keeps the backend connected to the broker and its
+// subscriptions in place. Nothing here exits the process: a failed connect or
+// subscribe is retried and shows up in ConnectionHealth until it succeeds.
+type brokerConnection struct {
+	client mqtt.Client
+	broker string
+	retry  time.Duration
+	max    time.Duration
+
+	mutex          sync.Mutex
+	connected      bool
+	connectedSince *time.Time
+	reconnects     uint64
+	lastError      *string
+	lastErrorAt    *time.Time
+	subscriptions  []*subscription
+}
+
+func newBrokerConnection(conf *config.MQTT, subscriptions []*subscription) (*brokerConnection, error) {
+	if conf.CommandQoS < 0 || conf.CommandQoS > 2 {
+		return nil, fmt.Errorf("invalid command QoS %d", conf.CommandQoS)
+	}
+
+	c := &brokerConnection{
+		broker:        conf.BrokerURL,
+		retry:         time.Duration(max(conf.ConnectRetrySeconds, 1)) * time.Second,
+		max:           time.Duration(max(conf.ReconnectMaxSeconds, 1)) * time.Second,
+		subscriptions: subscriptions,
+	}
+
+	opts, err := c.clientOptions(conf)
+	if err != nil {
+		return nil, err
+	}
+	c.client = mqtt.NewClient(opts)
+
+	// With a persistent session the broker may deliver queued messages
+	// before the subscriptions are renewed, so the handlers are registered
+	// up front.
+	for _, sub := range subscriptions {
+		c.client.AddRoute(sub.topic, sub.handler)
+	}
+	return c, nil
+}
+
+func (c *brokerConnection) clientOptions(conf *config.MQTT) (*mqtt.ClientOptions, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(conf.BrokerURL).
+		SetClientID(conf.ClientID).
+		SetCleanSession(!conf.PersistentSession).
+		SetKeepAlive(time.Duration(max(conf.KeepAliveSeconds, 1)) * time.Second).
+		SetConnectTimeout(time.Duration(max(conf.ConnectTimeoutSeconds, 1)) * time.Second).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(c.max)
+
+	if conf.Username != "" || conf.Password != "" {
+		username := conf.Username
+		if username == "" {
+			username = conf.ClientID
+		}
+		opts.SetUsername(username)
+		opts.SetPassword(conf.Password)
+	}
+
+	tlsConfig, err := brokerTLSConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	// The payload is not logged; it may hold device secrets.
+	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
+		log.Printf("Received unexpected message on topic: %s", msg.Topic())
+	})
+	opts.SetOnConnectHandler(c.onConnect)
+	opts.SetConnectionLostHandler(c.onConnectionLost)
+	opts.SetReconnectingHandler(func(mqtt.Client, *mqtt.ClientOptions) {
+		c.mutex.Lock()
+		c.reconnects++
+		c.mutex.Unlock()
+		log.Printf("Reconnecting to MQTT broker %s", c.broker)
+	})
+	return opts, nil
+}
+
+// brokerTLSConfig builds the TLS settings from the certificate files. It
+// returns nil when none are configured, which leaves TLS to the URL scheme
+// with the system roots.
+func brokerTLSConfig(conf *config.MQTT) (*tls.Config, error) {
+	if conf.CACertFile == "" && conf.ClientCertFile == "" && !conf.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+	if parsed, err := url.Parse(conf.BrokerURL); err == nil {
+		tlsConfig.ServerName = parsed.Hostname()
+	}
+
+	if conf.CACertFile != "" {
+		pem, err := os.ReadFile(conf.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MQTT CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", conf.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if conf.ClientCertFile != "" || conf.ClientKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(conf.ClientCertFile, conf.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// connect makes the first connection in the background, waiting longer after
+// each failure. Once connected, paho reconnects by itself.
+func (c *brokerConnection) connect() {
+	wait := c.retry
+	for {
+		token := c.client.Connect()
+		token.Wait()
+		err := token.Error()
+		if err == nil {
+			return
+		}
+
+		c.recordError(err)
+		log.Printf("Failed to connect to MQTT broker %s, retrying in %s: %v", c.broker, wait, err)
+		time.Sleep(wait)
+		wait = min(wait*2, c.max)
+	}
+}
+
+func (c *brokerConnection) onConnect(client mqtt.Client) {
+	now := time.Now()
+	c.mutex.Lock()
+	c.connected = true
+	c.connectedSince = &now
+	c.mutex.Unlock()
+
+	log.Printf("Connected to MQTT broker %s", c.broker)
+	c.subscribePending()
+}
+
+func (c *brokerConnection) onConnectionLost(client mqtt.Client, err error) {
+	c.mutex.Lock()
+	c.connected = false
+	c.connectedSince = nil
+	for _, sub := range c.subscriptions {
+		sub.subscribed = false
+	}
+	c.mutex.Unlock()
+
+	c.recordError(err)
+	log.Printf("Connection to MQTT broker lost: %v", err)
+}
+
+func (c *brokerConnection) recordError(err error) {
+	message := err.Error()
+	now := time.Now()
+	c.mutex.Lock()
+	c.lastError = &message
+	c.lastErrorAt = &now
+	c.mutex.Unlock()
+}
+
+// subscribePending subscribes every topic that is not subscribed yet. A
+// failure is recorded on the subscription and retried by resubscribeLoop.
+func (c *brokerConnection) subscribePending() {
+	c.mutex.Lock()
+	var pending []*subscription
+	for _, sub := range c.subscriptions {
+		if !sub.subscribed {
+			pending = append(pending, sub)
+		}
+	}
+	c.mutex.Unlock()
+
+	for _, sub := range pending {
+		var err error
+		token := c.client.Subscribe(sub.topic, sub.qos, sub.handler)
+		if !token.WaitTimeout(subscribeTimeout) {
+			err = fmt.Errorf("timed out after %s", subscribeTimeout)
+		} else if err = token.Error(); err == nil {
+			err = subscriptionRefused(token)
+		}
+
+		now := time.Now()
+		c.mutex.Lock()
+		sub.lastAttemptAt = &now
+		if err != nil {
+			message := err.Error()
+			sub.err = &message
+		} else {
+			sub.subscribed = true
+			sub.err = nil
+		}
+		c.mutex.Unlock()
+
+		if err != nil {
+			log.Printf("Failed to subscribe to topic '%s': %v", sub.topic, err)
+		} else {
+			log.Printf("Subscribed to topic: %s (QoS %d)", sub.topic, sub.qos)
+		}
+	}
+}
+
+// subscriptionRefused reports a subscription the broker answered with a
+// failure code, as it does when an ACL denies the topic.
+func subscriptionRefused(token mqtt.Token) error {
+	subscribeToken, ok := token.(*mqtt.SubscribeToken)
+	if !ok {
+		return nil
+	}
+	for topic, code := range subscribeToken.Result() {
+		if code == 0x80 {
+			return fmt.Errorf("broker refused the subscription to %s", topic)
+		}
+	}
+	return nil
+}
+
+func (c *brokerConnection) resubscribeLoop() {
+	ticker := time.NewTicker(resubscribeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		c.mutex.Lock()
+		connected := c.connected
+		c.mutex.Unlock()
+		if connected {
+			c.subscribePending()
+		}
+	}
+}
+
+func (c *brokerConnection) ConnectionHealth() entities.MqttHealthDto {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	health := entities.MqttHealthDto{
+		Connected:      c.connected,
+		Broker:         redactBrokerURL(c.broker),
+		ConnectedSince: c.connectedSince,
+		Reconnects:     c.reconnects,
+		LastError:      c.lastError,
+		LastErrorAt:    c.lastErrorAt,
+		Subscriptions:  make([]entities.MqttSubscriptionDto, 0, len(c.subscriptions)),
+	}
+	health.Healthy = c.connected
+	for _, sub := range c.subscriptions {
+		health.Subscriptions = append(health.Subscriptions, entities.MqttSubscriptionDto{
+			Topic:         sub.topic,
+			QoS:           sub.qos,
+			Subscribed:    sub.subscribed,
+			Error:         sub.err,
+			LastAttemptAt: sub.lastAttemptAt,
+		})
+		if !sub.subscribed {
+			health.Healthy = false
+		}
+	}
+	return health
+}
+
+// redactBrokerURL drops credentials from the broker URL before it is shown.
+func redactBrokerURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.User == nil {
+		return raw
+	}
+	return strings.Replace(raw, parsed.User.String()+"@", "", 1)
+}
@@ -0,0 +1,24 @@
+package mqtt
+
+import (
+	"log"
+	"time"
+
+	"main/duckweed/entities"
+)
+
+// storeDeadLetter keeps a rejected message for later inspection and replay.
+// Failing to store it is only logged; ingestion must not stall on it.
+func storeDeadLetter(topic string, boardID *string, payload []byte, reason entities.DeadLetterReasonEnum, detail string, receivedAt time.Time) {
+	message := &entities.DeadLetterMessage{
+		Topic:      topic,
+		BoardID:    boardID,
+		Payload:    payload,
+		ReasonCode: reason,
+		Detail:     detail,
+		ReceivedAt: receivedAt,
+	}
+	if err := deadLetterRepo.Create(message); err != nil {
+		log.Printf("Failed to store dead letter for topic %s: %v", topic, err)
+	}
+}
@@ -0,0 +1,36 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log"
+
+	"main/duckweed/entities"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// handleOtaMessage records the progress a board reports while it installs a
+// release it was sent with the ota command.
+func handleOtaMessage(client mqtt.Client, msg mqtt.Message) {
+	log.Printf("Received OTA progress on topic: %s", msg.Topic())
+
+	route, err := router.routeOta(msg.Topic())
+	if err != nil {
+		log.Printf("Could not extract Board ID from OTA topic: %v", err)
+		return
+	}
+	boardIdStr := route.boardID
+
+	var progress entities.FirmwareProgressDto
+	if err := json.Unmarshal(msg.Payload(), &progress); err != nil {
+		log.Printf("Error unmarshaling OTA progress payload: %v", err)
+		return
+	}
+
+	update, err := firmwareUseCase.HandleProgress(boardIdStr, progress)
+	if err != nil {
+		log.Printf("Failed to record OTA progress for board %s: %v", boardIdStr, err)
+		return
+	}
+	log.Printf("Firmware update %d for board %s is %s (%d%%)", update.ID, boardIdStr, update.State, update.Progress)
+}
@@ -0,0 +1,644 @@
+package mqtt
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"main/config"
+	"main/duckweed/entities"
+	"main/duckweed/usecases"
+
+	"gorm.io/gorm"
+)
+
+// enqueueTimeout is how long the paho callback waits for room in a full
+// worker queue before the message is dropped.
+const enqueueTimeout = 250 * time.Millisecond
+
+// storeTimeout bounds how long a caller waiting for its readings to be stored
+// is held.
+const storeTimeout = 10 * time.Second
+
+type ingestJob struct {
+	topic      string
+	boardID    string
+	params     map[string]string
+	payload    []byte
+	receivedAt time.Time
+}
+
+// ingestBatch is the validated output of one telemetry message, waiting in
+// the writer to be flushed to the database. The message itself is kept so it
+// can still be dead-lettered at flush time. A caller waiting for the outcome
+// passes done, and is told instead of the message being dead-lettered.
+type ingestBatch struct {
+	boardPK    uint
+	boardID    string
+	sensorLogs []*entities.SensorLog
+	topic      string
+	payload    []byte
+	receivedAt time.Time
+	done       chan error
+}
+
+// ingestPipeline moves telemetry work off the paho callback goroutine. Each
+// board is hashed onto one worker queue so its readings stay in order; the
+// workers validate and decode, and a single writer flushes rows in bulk and
+// debounces last_seen updates.
+type ingestPipeline struct {
+	db            *gorm.DB
+	boards        *boardCache
+	quality       *qualityFilter
+	queues        []chan ingestJob
+	results       chan ingestBatch
+	batchSize     int
+	flushInterval time.Duration
+	lastSeenEvery time.Duration
+
+	queueCapacity    int64
+	received         atomic.Uint64
+	dropped          atomic.Uint64
+	rejected         atomic.Uint64
+	persisted        atomic.Uint64
+	duplicates       atomic.Uint64
+	suspect          atomic.Uint64
+	pendingRows      atomic.Int64
+	flushes          atomic.Uint64
+	flushErrors      atomic.Uint64
+	persistFailed    atomic.Uint64
+	boardCacheHits   atomic.Uint64
+	boardCacheMisses atomic.Uint64
+
+	flushMutex  sync.RWMutex
+	lastFlushAt *time.Time
+	lastFlushMs int64
+}
+
+func newIngestPipeline(database *gorm.DB, conf *config.MQTT, qualityConf *config.Quality) *ingestPipeline {
+	workers := max(conf.IngestWorkers, 1)
+	perWorker := max(conf.IngestQueueSize/workers, 1)
+
+	p := &ingestPipeline{
+		db:            database,
+		boards:        newBoardCache(database),
+		quality:       newQualityFilter(qualityConf),
+		queues:        make([]chan ingestJob, workers),
+		results:       make(chan ingestBatch, perWorker),
+		batchSize:     max(conf.IngestBatchSize, 1),
+		flushInterval: time.Duration(max(conf.IngestFlushIntervalMs, 10)) * time.Millisecond,
+		lastSeenEvery: time.Duration(max(conf.LastSeenDebounceSeconds, 1)) * time.Second,
+		queueCapacity: int64(workers * perWorker),
+	}
+	for i := range p.queues {
+		p.queues[i] = make(chan ingestJob, perWorker)
+	}
+	return p
+}
+
+func (p *ingestPipeline) start() {
+	for i := range p.queues {
+		go p.worker(p.queues[i])
+	}
+	go p.writer()
+	log.Printf("Telemetry pipeline started with %d workers (queue capacity %d)", len(p.queues), p.queueCapacity)
+}
+
+// enqueue hands a message to the worker that owns its board. It never blocks
+// the caller for longer than enqueueTimeout.
+func (p *ingestPipeline) enqueue(job ingestJob) bool {
+	p.received.Add(1)
+
+	hash := fnv.New32a()
+	hash.Write([]byte(job.boardID))
+	queue := p.queues[hash.Sum32()%uint32(len(p.queues))]
+
+	select {
+	case queue <- job:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case queue <- job:
+		return true
+	case <-timer.C:
+		p.dropped.Add(1)
+		log.Printf("Telemetry queue full, dropping message for board %s", job.boardID)
+		return false
+	}
+}
+
+func (p *ingestPipeline) worker(queue chan ingestJob) {
+	for job := range queue {
+		p.process(job)
+	}
+}
+
+func (p *ingestPipeline) process(job ingestJob) {
+	batch, reason, err := p.prepare(job)
+	if err != nil {
+		p.rejected.Add(1)
+		log.Printf("Rejected telemetry on %s: %v", job.topic, err)
+		storeDeadLetter(job.topic, &job.boardID, job.payload, reason, err.Error(), job.receivedAt)
+		return
+	}
+	router.remember(topicRoute{boardID: job.boardID, params: job.params})
+	p.submit(batch)
+}
+
+// prepare validates a telemetry message and turns it into rows. On failure it
+// returns the dead-letter reason code that describes the rejection.
+func (p *ingestPipeline) prepare(job ingestJob) (ingestBatch, entities.DeadLetterReasonEnum, error) {
+	readings, err := decodeTelemetryPayload(job.payload)
+	if err != nil {
+		return ingestBatch{}, entities.DeadLetterInvalidPayload, fmt.Errorf("invalid telemetry payload: %w", err)
+	}
+
+	board, hit, err := p.boards.lookup(job.boardID)
+	if hit {
+		p.boardCacheHits.Add(1)
+	} else {
+		p.boardCacheMisses.Add(1)
+	}
+	if err != nil {
+		return ingestBatch{}, entities.DeadLetterInternalError, fmt.Errorf("error looking up board %s: %w", job.boardID, err)
+	}
+	if !board.found {
+		return ingestBatch{}, entities.DeadLetterUnknownBoard, fmt.Errorf("board %s not found in database", job.boardID)
+	}
+	if !board.claimed {
+		return ingestBatch{}, entities.DeadLetterUnclaimedBoard, fmt.Errorf("no user relationship found for board %s", job.boardID)
+	}
+	if board.disabled {
+		return ingestBatch{}, entities.DeadLetterDisabledBoard, fmt.Errorf("board %s is disabled", job.boardID)
+	}
+
+	sensorLogs, err := buildSensorLogs(board.boardID, readings, job.receivedAt)
+	if err != nil {
+		return ingestBatch{}, entities.DeadLetterInvalidPayload, fmt.Errorf("invalid telemetry payload: %w", err)
+	}
+	for _, sensorLog := range sensorLogs {
+		if err := calibrator.Calibrate(board.boardID, sensorLog.Measurements); err != nil {
+			return ingestBatch{}, entities.DeadLetterInternalError, fmt.Errorf("error loading calibrations for board %s: %w", job.boardID, err)
+		}
+		p.quality.inspect(board.boardID, sensorLog.Measurements)
+		for _, measurement := range sensorLog.Measurements {
+			if measurement.Quality == entities.QualitySuspect {
+				p.suspect.Add(1)
+			}
+		}
+	}
+
+	return ingestBatch{
+		boardPK:    board.id,
+		boardID:    board.boardID,
+		sensorLogs: sensorLogs,
+		topic:      job.topic,
+		payload:    job.payload,
+		receivedAt: job.receivedAt,
+	}, "", nil
+}
+
+func (p *ingestPipeline) submit(batch ingestBatch) {
+	p.pendingRows.Add(int64(len(batch.sensorLogs)))
+	p.results <- batch
+}
+
+// store queues the batch for the writer and waits until it was stored. It
+// gives up with ErrIngestionBusy when the writer has no room for the batch
+// within enqueueTimeout, or does not store it within storeTimeout.
+func (p *ingestPipeline) store(batch ingestBatch) error {
+	batch.done = make(chan error, 1)
+
+	enqueueTimer := time.NewTimer(enqueueTimeout)
+	defer enqueueTimer.Stop()
+	p.pendingRows.Add(int64(len(batch.sensorLogs)))
+	select {
+	case p.results <- batch:
+	case <-enqueueTimer.C:
+		p.pendingRows.Add(-int64(len(batch.sensorLogs)))
+		p.dropped.Add(1)
+		return usecases.ErrIngestionBusy
+	}
+
+	storeTimer := time.NewTimer(storeTimeout)
+	defer storeTimer.Stop()
+	select {
+	case err := <-batch.done:
+		return err
+	case <-storeTimer.C:
+		return usecases.ErrIngestionBusy
+	}
+}
+
+// settle tells a waiting caller the outcome of its batch. It never blocks the
+// writer; done has room for the one outcome a batch gets.
+func settle(batch ingestBatch, err error) {
+	if batch.done == nil {
+		return
+	}
+	select {
+	case batch.done <- err:
+	default:
+	}
+}
+
+// ReplayTelemetry re-runs a stored message through validation, bypassing the
+// board cache so a claim made moments ago is honoured, and waits for the
+// writer to store it. A message rejected again is not dead-lettered a second
+// time.
+func (p *ingestPipeline) ReplayTelemetry(topic string, payload []byte, receivedAt time.Time) (int, error) {
+	route, err := router.routeTelemetry(topic)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", usecases.ErrReplayRejected, err)
+	}
+	boardID := route.boardID
+
+	p.boards.invalidate(boardID)
+	calibrator.Invalidate(boardID)
+	batch, _, err := p.prepare(ingestJob{
+		topic:      topic,
+		boardID:    boardID,
+		payload:    payload,
+		receivedAt: receivedAt,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", usecases.ErrReplayRejected, err)
+	}
+
+	err = p.store(batch)
+	if errors.Is(err, usecases.ErrBoardDisabled) {
+		return 0, fmt.Errorf("%w: %v", usecases.ErrReplayRejected, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(batch.sensorLogs), nil
+}
+
+func (p *ingestPipeline) IngestTelemetry(boardID string, payload []byte, receivedAt time.Time) (int, error) {
+	p.received.Add(1)
+	batch, reason, err := p.prepare(ingestJob{
+		boardID:    boardID,
+		payload:    payload,
+		receivedAt: receivedAt,
+	})
+	if err != nil {
+		p.rejected.Add(1)
+		switch reason {
+		case entities.DeadLetterInvalidPayload:
+			return 0, fmt.Errorf("%w: %v", usecases.ErrInvalidTelemetry, err)
+		case entities.DeadLetterUnknownBoard:
+			return 0, usecases.ErrBoardNotFound
+		case entities.DeadLetterUnclaimedBoard:
+			return 0, usecases.ErrBoardUnclaimed
+		case entities.DeadLetterDisabledBoard:
+			return 0, usecases.ErrBoardDisabled
+		}
+		return 0, err
+	}
+
+	if err := p.store(batch); err != nil {
+		return 0, err
+	}
+	return len(batch.sensorLogs), nil
+}
+
+func (p *ingestPipeline) InvalidateBoard(boardID string) {
+	p.boards.invalidate(boardID)
+	alertEngine.Forget(boardID)
+	router.forget(boardID)
+}
+
+// writer owns all database writes of the pipeline so rows from many boards
+// share one INSERT and last_seen is bumped once per debounce window.
+func (p *ingestPipeline) writer() {
+	flushTicker := time.NewTicker(p.flushInterval)
+	defer flushTicker.Stop()
+	lastSeenTicker := time.NewTicker(p.lastSeenEvery)
+	defer lastSeenTicker.Stop()
+
+	var pending []ingestBatch
+	pendingRows := 0
+	seen := make(map[uint]struct{})
+
+	for {
+		select {
+		case batch := <-p.results:
+			pending = append(pending, batch)
+			pendingRows += len(batch.sensorLogs)
+			if pendingRows < p.batchSize {
+				continue
+			}
+		case <-flushTicker.C:
+		case <-lastSeenTicker.C:
+			p.flushLastSeen(seen)
+			seen = make(map[uint]struct{})
+			continue
+		}
+
+		if len(pending) == 0 {
+			continue
+		}
+		for _, batch := range p.flush(pending) {
+			seen[batch.boardPK] = struct{}{}
+		}
+		p.pendingRows.Add(-int64(pendingRows))
+		pending = nil
+		pendingRows = 0
+	}
+}
+
+// flush stores the pending batches and returns the ones that were stored.
+// Readings already in the database are dropped first, so a message the broker
+// redelivers is acknowledged without being stored, broadcast or alerted on
+// again. Batches that cannot be stored are dead-lettered, since the broker no
+// longer holds their messages.
+func (p *ingestPipeline) flush(pending []ingestBatch) []ingestBatch {
+	started := time.Now()
+
+	unsaved := pending
+	stored, err := p.dropDisabled(pending)
+	if err == nil {
+		unsaved = stored
+		stored, err = p.dropDuplicates(stored)
+	}
+	if err == nil {
+		unsaved = stored
+		err = p.insert(stored)
+		if err != nil && len(stored) > 1 {
+			// A single bad message, such as a reading another backend
+			// instance stored moments ago, should not cost the others.
+			log.Printf("Failed to save %d sensor logs together, retrying message by message: %v", countSensorLogs(stored), err)
+			stored, err = p.insertEach(stored), nil
+		}
+	}
+
+	p.flushes.Add(1)
+	p.flushMutex.Lock()
+	p.lastFlushAt = &started
+	p.lastFlushMs = time.Since(started).Milliseconds()
+	p.flushMutex.Unlock()
+
+	if err != nil {
+		p.flushErrors.Add(1)
+		log.Printf("Failed to save %d sensor logs: %v", countSensorLogs(unsaved), err)
+		p.persistFailedBatches(unsaved, err)
+		return nil
+	}
+	p.persisted.Add(uint64(countSensorLogs(stored)))
+	for _, batch := range stored {
+		settle(batch, nil)
+	}
+
+	for _, batch := range stored {
+		// Backfilled readings are stored but only the newest one is pushed live.
+		latest := batch.sensorLogs[len(batch.sensorLogs)-1]
+		if serverInstance != nil {
+			point, err := channelResolver.TelemetryPoint(latest)
+			if err != nil {
+				log.Printf("Failed to describe telemetry channels for board %s: %v", batch.boardID, err)
+			} else {
+				serverInstance.BroadcastTelemetryData(batch.boardID, point)
+			}
+		}
+
+		for _, sensorLog := range batch.sensorLogs {
+			alertEngine.Evaluate(batch.boardID, sensorLog.MetricValues(), sensorLog.CreatedAt)
+		}
+	}
+	return stored
+}
+
+func (p *ingestPipeline) insert(batches []ingestBatch) error {
+	var rows []*entities.SensorLog
+	for _, batch := range batches {
+		rows = append(rows, batch.sensorLogs...)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(rows, p.batchSize).Error
+	})
+	if err != nil {
+		// The rolled back insert already handed out IDs; clear them so a
+		// retry lets the database assign new ones.
+		for _, row := range rows {
+			row.ID = 0
+			for i := range row.Measurements {
+				row.Measurements[i].ID = 0
+				row.Measurements[i].SensorLogID = 0
+			}
+		}
+	}
+	return err
+}
+
+// insertEach stores the batches one message at a time and returns the ones
+// that were stored. Each message is checked for duplicates again, since the
+// failed insert may have raced with another writer.
+func (p *ingestPipeline) insertEach(batches []ingestBatch) []ingestBatch {
+	stored := make([]ingestBatch, 0, len(batches))
+	for _, batch := range batches {
+		remaining, err := p.dropDuplicates([]ingestBatch{batch})
+		if err == nil {
+			err = p.insert(remaining)
+		}
+		if err != nil {
+			p.flushErrors.Add(1)
+			log.Printf("Failed to save %d sensor logs of board %s: %v", len(batch.sensorLogs), batch.boardID, err)
+			p.persistFailedBatches([]ingestBatch{batch}, err)
+			continue
+		}
+		stored = append(stored, remaining...)
+	}
+	return stored
+}
+
+// persistFailedBatches dead-letters batches that could not be stored, so they
+// can be replayed once the database is back. A caller waiting on its batch is
+// told instead, and a message without a topic to replay it on is only
+// counted.
+func (p *ingestPipeline) persistFailedBatches(batches []ingestBatch, cause error) {
+	for _, batch := range batches {
+		p.persistFailed.Add(1)
+		if batch.done != nil {
+			settle(batch, fmt.Errorf("%w: %v", usecases.ErrIngestionBusy, cause))
+			continue
+		}
+		if batch.topic == "" {
+			continue
+		}
+		boardID := batch.boardID
+		storeDeadLetter(batch.topic, &boardID, batch.payload, entities.DeadLetterPersistFailed, cause.Error(), batch.receivedAt)
+	}
+}
+
+// dropDisabled removes the batches of boards that are disabled now. The board
+// cache of this replica may not know yet that another replica disabled the
+// board, so the flag is read from the database on every flush. A caller
+// waiting on its batch gets ErrBoardDisabled; messages that came in over MQTT
+// are dead-lettered so they can be replayed once the board is enabled again.
+func (p *ingestPipeline) dropDisabled(pending []ingestBatch) ([]ingestBatch, error) {
+	boardIDs := make([]string, 0, len(pending))
+	for _, batch := range pending {
+		boardIDs = append(boardIDs, batch.boardID)
+	}
+
+	var disabledIDs []string
+	err := p.db.Model(&entities.Board{}).
+		Where("board_id IN ? AND board_status = ?", boardIDs, entities.BoardStatusDisabled).
+		Pluck("board_id", &disabledIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("error checking for disabled boards: %w", err)
+	}
+	if len(disabledIDs) == 0 {
+		return pending, nil
+	}
+
+	disabled := make(map[string]bool, len(disabledIDs))
+	for _, boardID := range disabledIDs {
+		disabled[boardID] = true
+		p.boards.invalidate(boardID)
+	}
+
+	kept := make([]ingestBatch, 0, len(pending))
+	for _, batch := range pending {
+		if !disabled[batch.boardID] {
+			kept = append(kept, batch)
+			continue
+		}
+		p.rejected.Add(1)
+		detail := fmt.Sprintf("board %s is disabled", batch.boardID)
+		log.Printf("Dropped %d sensor logs at flush: %s", len(batch.sensorLogs), detail)
+		if batch.done != nil {
+			settle(batch, usecases.ErrBoardDisabled)
+		} else if batch.topic != "" {
+			boardID := batch.boardID
+			storeDeadLetter(batch.topic, &boardID, batch.payload, entities.DeadLetterDisabledBoard, detail, batch.receivedAt)
+		}
+	}
+	return kept, nil
+}
+
+// dropDuplicates removes readings whose dedup key is already stored for the
+// board, or repeated within the pending batches, and leaves out batches with
+// nothing left to store.
+func (p *ingestPipeline) dropDuplicates(pending []ingestBatch) ([]ingestBatch, error) {
+	var pairs [][]interface{}
+	for _, batch := range pending {
+		for _, sensorLog := range batch.sensorLogs {
+			if sensorLog.DedupKey != nil {
+				pairs = append(pairs, []interface{}{batch.boardID, *sensorLog.DedupKey})
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		return pending, nil
+	}
+
+	var existing []struct {
+		BoardID  string
+		DedupKey string
+	}
+	// Soft-deleted readings still hold their key in the unique index.
+	err := p.db.Unscoped().Model(&entities.SensorLog{}).
+		Select("board_id, dedup_key").
+		Where("(board_id, dedup_key) IN ?", pairs).
+		Scan(&existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("error checking for duplicate readings: %w", err)
+	}
+
+	seen := make(map[string]bool, len(pairs))
+	for _, row := range existing {
+		seen[row.BoardID+"\x00"+row.DedupKey] = true
+	}
+
+	kept := make([]ingestBatch, 0, len(pending))
+	for _, batch := range pending {
+		sensorLogs := batch.sensorLogs[:0:0]
+		for _, sensorLog := range batch.sensorLogs {
+			if sensorLog.DedupKey != nil {
+				key := batch.boardID + "\x00" + *sensorLog.DedupKey
+				if seen[key] {
+					p.duplicates.Add(1)
+					continue
+				}
+				seen[key] = true
+			}
+			sensorLogs = append(sensorLogs, sensorLog)
+		}
+		if len(sensorLogs) == 0 {
+			// Everything in it is stored already.
+			settle(batch, nil)
+			continue
+		}
+		batch.sensorLogs = sensorLogs
+		kept = append(kept, batch)
+	}
+	return kept, nil
+}
+
+func countSensorLogs(batches []ingestBatch) int {
+	count := 0
+	for _, batch := range batches {
+		count += len(batch.sensorLogs)
+	}
+	return count
+}
+
+func (p *ingestPipeline) flushLastSeen(seen map[uint]struct{}) {
+	if len(seen) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	now := time.Now()
+	result := p.db.Model(&entities.Board{}).Where("id IN ?", ids).Update("last_seen", &now)
+	if result.Error != nil {
+		log.Printf("Failed to update LastSeen for %d boards: %v", len(ids), result.Error)
+	}
+}
+
+func (p *ingestPipeline) IngestionMetrics() entities.IngestionMetricsDto {
+	var depth int64
+	for _, queue := range p.queues {
+		depth += int64(len(queue))
+	}
+
+	p.flushMutex.RLock()
+	defer p.flushMutex.RUnlock()
+
+	return entities.IngestionMetricsDto{
+		Workers:          len(p.queues),
+		QueueDepth:       depth,
+		QueueCapacity:    p.queueCapacity,
+		Received:         p.received.Load(),
+		Dropped:          p.dropped.Load(),
+		Rejected:         p.rejected.Load(),
+		Persisted:        p.persisted.Load(),
+		Duplicates:       p.duplicates.Load(),
+		Suspect:          p.suspect.Load(),
+		PendingRows:      p.pendingRows.Load(),
+		Flushes:          p.flushes.Load(),
+		FlushErrors:      p.flushErrors.Load(),
+		PersistFailed:    p.persistFailed.Load(),
+		LastFlushAt:      p.lastFlushAt,
+		LastFlushMs:      p.lastFlushMs,
+		BoardCacheHits:   p.boardCacheHits.Load(),
+		BoardCacheMisses: p.boardCacheMisses.Load(),
+	}
+}
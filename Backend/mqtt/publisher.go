@@ -0,0 +1,44 @@
+package mqtt
+
+import (
+	"fmt"
+	"time"
+
+	"main/duckweed/usecases"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const publishTimeout = 5 * time.Second
+
+type commandPublisher struct {
+	client mqtt.Client
+}
+
+// NewCommandPublisher returns a publisher that sends board commands on the
+// topic rendered from the TopicCommand template with the configured QoS.
+func NewCommandPublisher(client mqtt.Client) usecases.CommandPublisher {
+	return &commandPublisher{client: client}
+}
+
+func (p *commandPublisher) PublishCommand(boardID string, payload []byte) error {
+	topic, err := router.commandTopic(boardID)
+	if err != nil {
+		return err
+	}
+
+	// Publishing while disconnected would queue the command in paho and
+	// deliver it late, after it has been reported as failed.
+	if !p.client.IsConnectionOpen() {
+		return fmt.Errorf("%w: not connected to the MQTT broker", usecases.ErrCommandChannelUnavailable)
+	}
+
+	token := p.client.Publish(topic, byte(mqttConfig.MQTT.CommandQoS), false, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package mqtt
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"main/config"
+	"main/duckweed/entities"
+)
+
+// madScale turns the median absolute deviation into an estimate of the
+// standard deviation for normally distributed readings.
+const madScale = 1.4826
+
+// spikeWindowIdleTTL is how long the window of a board channel that sends no
+// readings is kept, so boards that went away do not stay in memory. A board
+// back after longer starts over with an empty window.
+const spikeWindowIdleTTL = time.Hour
+
+// spikeWindowPruneInterval is how often idle windows are looked for.
+const spikeWindowPruneInterval = 10 * time.Minute
+
+// qualityFilter flags readings that are implausible for their channel or that
+// jump far away from the recent readings of the same board and channel.
+// Flagged readings are still stored, but as suspect.
+type qualityFilter struct {
+	conf     *config.Quality
+	mutex    sync.Mutex
+	windows  map[string]*spikeWindow
+	prunedAt time.Time
+}
+
+// spikeWindow holds the recent good values of one board channel, plus the run
+// of consecutive spikes since the last good value. A run as long as the
+// minimum sample count is taken as a real change of level and replaces the
+// window, so a probe moved to a different pond is not flagged forever.
+type spikeWindow struct {
+	values   []float64
+	run      []float64
+	lastSeen time.Time
+}
+
+func newQualityFilter(conf *config.Quality) *qualityFilter {
+	if conf == nil {
+		conf = &config.Quality{}
+	}
+	return &qualityFilter{
+		conf:    conf,
+		windows: make(map[string]*spikeWindow),
+	}
+}
+
+// inspect sets Quality and QualityReason on every measurement. Readings of a
+// board must be passed in time order for spike detection to be meaningful.
+func (f *qualityFilter) inspect(boardID string, measurements []entities.Measurement) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	f.pruneIdle(now)
+
+	for i := range measurements {
+		measurement := &measurements[i]
+		measurement.Quality = entities.QualityGood
+		measurement.QualityReason = nil
+
+		limits := f.conf.Channels[string(measurement.Channel)]
+		if (limits.Min != nil && measurement.Value < *limits.Min) || (limits.Max != nil && measurement.Value > *limits.Max) {
+			f.flag(measurement, entities.QualityOutOfRange)
+			continue
+		}
+
+		threshold := f.conf.SpikeThreshold
+		if limits.SpikeThreshold != nil {
+			threshold = *limits.SpikeThreshold
+		}
+		if threshold <= 0 || f.conf.SpikeWindow <= 0 {
+			continue
+		}
+
+		key := boardID + "/" + string(measurement.Channel)
+		window, ok := f.windows[key]
+		if !ok {
+			window = &spikeWindow{}
+			f.windows[key] = window
+		}
+		window.lastSeen = now
+		minSamples := max(f.conf.SpikeMinSamples, 3)
+		if window.isSpike(measurement.Value, threshold, minSamples) {
+			window.run = append(window.run, measurement.Value)
+			if len(window.run) < minSamples {
+				f.flag(measurement, entities.QualitySpike)
+				continue
+			}
+			// The level really changed; the run becomes the new baseline.
+			window.values = window.run
+			window.run = nil
+			continue
+		}
+		window.push(measurement.Value, f.conf.SpikeWindow)
+	}
+}
+
+// pruneIdle drops the windows of board channels that have not reported for
+// spikeWindowIdleTTL, checking at most once per spikeWindowPruneInterval.
+func (f *qualityFilter) pruneIdle(now time.Time) {
+	if now.Sub(f.prunedAt) < spikeWindowPruneInterval {
+		return
+	}
+	f.prunedAt = now
+	for key, window := range f.windows {
+		if now.Sub(window.lastSeen) > spikeWindowIdleTTL {
+			delete(f.windows, key)
+		}
+	}
+}
+
+func (f *qualityFilter) flag(measurement *entities.Measurement, reason entities.QualityReasonEnum) {
+	reasonCopy := reason
+	measurement.Quality = entities.QualitySuspect
+	measurement.QualityReason = &reasonCopy
+}
+
+func (w *spikeWindow) isSpike(value, threshold float64, minSamples int) bool {
+	if len(w.values) < minSamples {
+		return false
+	}
+	median := medianOf(w.values)
+	deviations := make([]float64, len(w.values))
+	for i, v := range w.values {
+		deviations[i] = math.Abs(v - median)
+	}
+	// A perfectly flat window has a MAD of zero; fall back to a small share
+	// of the median so sensor noise on a steady signal is not a spike.
+	spread := madScale * medianOf(deviations)
+	if spread == 0 {
+		spread = math.Max(math.Abs(median)*0.01, 1e-6)
+	}
+	return math.Abs(value-median) > threshold*spread
+}
+
+func (w *spikeWindow) push(value float64, size int) {
+	w.run = nil
+	w.values = append(w.values, value)
+	if len(w.values) > size {
+		w.values = w.values[len(w.values)-size:]
+	}
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,163 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"main/duckweed/entities"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// decodeStatusPayload reads a status message. Boards publish a JSON heartbeat,
+// and their Last Will is {"status":"offline"}; the plain text statuses of the
+// original firmware are still accepted. The heartbeat is nil for plain text.
+func decodeStatusPayload(payload []byte) (entities.BoardStatusEnum, *entities.BoardHeartbeatDto, error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var heartbeat entities.BoardHeartbeatDto
+		if err := json.Unmarshal(trimmed, &heartbeat); err != nil {
+			return "", nil, err
+		}
+		status, err := parseBoardStatus(heartbeat.Status)
+		if err != nil {
+			return "", nil, err
+		}
+		return status, &heartbeat, nil
+	}
+
+	if len(trimmed) == 0 {
+		return "", nil, fmt.Errorf("empty status payload")
+	}
+	status, err := parseBoardStatus(string(trimmed))
+	return status, nil, err
+}
+
+// parseBoardStatus reads the status a board reports. Only the owner disables
+// a board, so a board reporting itself disabled is refused.
+func parseBoardStatus(raw string) (entities.BoardStatusEnum, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "online", string(entities.BoardStatusActive):
+		return entities.BoardStatusActive, nil
+	case "offline", string(entities.BoardStatusInactive):
+		return entities.BoardStatusInactive, nil
+	}
+	return "", fmt.Errorf("unknown status %q", raw)
+}
+
+func handleStatusMessage(client mqtt.Client, msg mqtt.Message) {
+	log.Printf("Received status message on topic: %s", msg.Topic())
+
+	route, err := router.routeStatus(msg.Topic())
+	if err != nil {
+		log.Printf("Could not extract Board ID from status topic: %v", err)
+		return
+	}
+	boardIdStr := route.boardID
+
+	status, heartbeat, err := decodeStatusPayload(msg.Payload())
+	if err != nil {
+		log.Printf("Invalid status received for board %s: %v", boardIdStr, err)
+		return
+	}
+
+	// The board row is locked while the status is decided, so an owner
+	// disabling the board meanwhile is not overwritten.
+	var board entities.Board
+	ignored := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("board_id = ?", boardIdStr).First(&board).Error
+		if err != nil {
+			return err
+		}
+
+		// A disabled board stays disabled whatever it reports, and nothing
+		// it reports is recorded or broadcast.
+		if board.BoardStatus != nil && *board.BoardStatus == entities.BoardStatusDisabled {
+			ignored = true
+			return nil
+		}
+
+		now := time.Now()
+		cause := entities.BoardStatusCauseHeartbeat
+		if status == entities.BoardStatusInactive {
+			cause = entities.BoardStatusCauseLWT
+		}
+		transition := entities.NewBoardStatusTransition(boardIdStr, board.BoardStatus, status, cause, now)
+		board.BoardStatus = &status
+		// An offline message is the broker publishing the Last Will, not the
+		// board itself, so it does not count as the board being seen.
+		if status != entities.BoardStatusInactive {
+			board.LastSeen = &now
+		}
+		if heartbeat != nil && status == entities.BoardStatusActive {
+			applyHeartbeat(&board, heartbeat, now)
+		}
+
+		if err := tx.Save(&board).Error; err != nil {
+			return err
+		}
+		if transition != nil {
+			return tx.Create(transition).Error
+		}
+		return nil
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Board with BoardID %s not found: %v", boardIdStr, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to update board status for BoardID %s: %v", boardIdStr, err)
+		return
+	}
+	if ignored {
+		log.Printf("Ignored status of disabled board %s", boardIdStr)
+		return
+	}
+
+	log.Printf("Updated status for board %s to %s", boardIdStr, *board.BoardStatus)
+	router.remember(route)
+
+	if heartbeat != nil && heartbeat.Firmware != nil && status == entities.BoardStatusActive {
+		if err := firmwareUseCase.HandleReportedVersion(boardIdStr, *heartbeat.Firmware); err != nil {
+			log.Printf("Failed to check firmware update of board %s: %v", boardIdStr, err)
+		}
+	}
+
+	if serverInstance != nil {
+		serverInstance.BroadcastStatus(&board)
+	}
+}
+
+// applyHeartbeat copies the reported fields onto the board. Fields missing
+// from the heartbeat keep their previous value.
+func applyHeartbeat(board *entities.Board, heartbeat *entities.BoardHeartbeatDto, receivedAt time.Time) {
+	board.LastHeartbeatAt = &receivedAt
+	if heartbeat.Uptime != nil && *heartbeat.Uptime >= 0 {
+		bootedAt := receivedAt.Add(-time.Duration(*heartbeat.Uptime) * time.Second)
+		board.UptimeSeconds = heartbeat.Uptime
+		board.RunTime = &bootedAt
+	}
+	if heartbeat.Firmware != nil {
+		board.FirmwareVersion = heartbeat.Firmware
+	}
+	if heartbeat.Hardware != nil {
+		board.HardwareModel = heartbeat.Hardware
+	}
+	if heartbeat.RSSI != nil {
+		board.RSSI = heartbeat.RSSI
+	}
+	if heartbeat.FreeHeap != nil {
+		board.FreeHeap = heartbeat.FreeHeap
+	}
+	if heartbeat.ResetReason != nil {
+		board.ResetReason = heartbeat.ResetReason
+	}
+}
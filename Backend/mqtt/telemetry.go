@@ -0,0 +1,123 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"main/duckweed/entities"
+)
+
+const (
+	// maxTelemetryBatch caps how many buffered readings one message may carry.
+	maxTelemetryBatch = 500
+	// maxClockSkew is how far ahead of the server a device timestamp may be
+	// before it is treated as a broken clock.
+	maxClockSkew = 5 * time.Minute
+	// maxMessageIDLength keeps message IDs within the dedup key column.
+	maxMessageIDLength = 64
+)
+
+// minDeviceTime rejects timestamps from boards whose RTC was never synced and
+// still counts from the Unix epoch.
+var minDeviceTime = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// decodeTelemetryPayload accepts either a single reading object or an array of
+// buffered readings, each optionally carrying a device timestamp and sequence.
+func decodeTelemetryPayload(payload []byte) ([]entities.InsertSensorLogDto, error) {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty telemetry payload")
+	}
+
+	if trimmed[0] != '[' {
+		var dto entities.InsertSensorLogDto
+		if err := json.Unmarshal(trimmed, &dto); err != nil {
+			return nil, err
+		}
+		return []entities.InsertSensorLogDto{dto}, nil
+	}
+
+	var batch []entities.InsertSensorLogDto
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return nil, err
+	}
+	if len(batch) == 0 {
+		return nil, errors.New("empty telemetry batch")
+	}
+	if len(batch) > maxTelemetryBatch {
+		return nil, fmt.Errorf("telemetry batch of %d readings exceeds limit of %d", len(batch), maxTelemetryBatch)
+	}
+	return batch, nil
+}
+
+// buildSensorLogs turns decoded readings into SensorLog rows with one
+// Measurement per channel, ordered by reading time. CreatedAt carries the
+// device time so backfilled points land in the right place in the history;
+// readings without a usable device time are stamped with receivedAt.
+func buildSensorLogs(boardID string, readings []entities.InsertSensorLogDto, receivedAt time.Time) ([]*entities.SensorLog, error) {
+	sensorLogs := make([]*entities.SensorLog, 0, len(readings))
+	for i := range readings {
+		values := readings[i].ChannelValues()
+		if len(values) == 0 {
+			return nil, fmt.Errorf("reading %d carries no channel values", i)
+		}
+		if readings[i].MessageID != nil && len(*readings[i].MessageID) > maxMessageIDLength {
+			return nil, fmt.Errorf("reading %d has a message ID longer than %d characters", i, maxMessageIDLength)
+		}
+
+		boardIDCopy := boardID
+		received := receivedAt
+		sensorLog := &entities.SensorLog{
+			BoardID:    &boardIDCopy,
+			Sequence:   readings[i].Sequence,
+			DedupKey:   readings[i].DedupKey(),
+			ReceivedAt: &received,
+		}
+		sensorLog.CreatedAt = receivedAt
+		if readings[i].Timestamp != nil && isPlausibleDeviceTime(readings[i].Timestamp.Time, receivedAt) {
+			sensorLog.CreatedAt = readings[i].Timestamp.Time
+		}
+
+		channels := make([]entities.MetricEnum, 0, len(values))
+		for channel, value := range values {
+			if !channel.IsValidChannel() {
+				return nil, fmt.Errorf("invalid channel name %q", channel)
+			}
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				return nil, fmt.Errorf("channel %s has a non-finite value", channel)
+			}
+			channels = append(channels, channel)
+		}
+		sort.Slice(channels, func(a, b int) bool { return channels[a] < channels[b] })
+		for _, channel := range channels {
+			sensorLog.Measurements = append(sensorLog.Measurements, entities.Measurement{
+				BoardID:   boardID,
+				Channel:   channel,
+				Value:     values[channel],
+				CreatedAt: sensorLog.CreatedAt,
+			})
+		}
+		sensorLogs = append(sensorLogs, sensorLog)
+	}
+
+	sort.SliceStable(sensorLogs, func(i, j int) bool {
+		a, b := sensorLogs[i], sensorLogs[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.Sequence != nil && b.Sequence != nil {
+			return *a.Sequence < *b.Sequence
+		}
+		return false
+	})
+	return sensorLogs, nil
+}
+
+func isPlausibleDeviceTime(deviceTime, receivedAt time.Time) bool {
+	return deviceTime.After(minDeviceTime) && deviceTime.Before(receivedAt.Add(maxClockSkew))
+}
@@ -0,0 +1,346 @@
+package mqtt
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"main/config"
+	"main/duckweed/entities"
+
+	"gorm.io/gorm"
+)
+
+const (
+	boardPlaceholder  = "board"
+	tenantPlaceholder = "tenant"
+)
+
+// topicTemplate is an MQTT topic layout such as
+// farms/{tenant}/boards/{board}/telemetry. Each {name} segment matches one
+// topic level and is extracted by name; + and # keep their MQTT meaning.
+// A template without placeholders is read the legacy way, with its first +
+// standing for the board ID, so iot/+/telemetry keeps working.
+type topicTemplate struct {
+	raw      string
+	segments []string
+}
+
+func parseTopicTemplate(raw string) (topicTemplate, error) {
+	segments := strings.Split(raw, "/")
+	hasPlaceholder := strings.Contains(raw, "{")
+
+	for i, segment := range segments {
+		switch {
+		case strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}"):
+			if len(segment) == 2 {
+				return topicTemplate{}, fmt.Errorf("topic template %q has an empty placeholder", raw)
+			}
+		case strings.ContainsAny(segment, "{}"):
+			return topicTemplate{}, fmt.Errorf("topic template %q: placeholders must fill a whole level", raw)
+		case segment == "#" && i != len(segments)-1:
+			return topicTemplate{}, fmt.Errorf("topic template %q: # must be the last level", raw)
+		case segment == "+" && !hasPlaceholder:
+			segments[i] = "{" + boardPlaceholder + "}"
+			hasPlaceholder = true
+		}
+	}
+	return topicTemplate{raw: raw, segments: segments}, nil
+}
+
+func (t topicTemplate) hasPlaceholder(name string) bool {
+	for _, segment := range t.segments {
+		if segment == "{"+name+"}" {
+			return true
+		}
+	}
+	return false
+}
+
+// subscription returns the MQTT filter for the template, with every
+// placeholder replaced by a single-level wildcard.
+func (t topicTemplate) subscription() string {
+	segments := make([]string, len(t.segments))
+	for i, segment := range t.segments {
+		if strings.HasPrefix(segment, "{") {
+			segments[i] = "+"
+		} else {
+			segments[i] = segment
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// match returns the placeholder values of a topic matching the template.
+func (t topicTemplate) match(topic string) (map[string]string, bool) {
+	levels := strings.Split(topic, "/")
+	params := make(map[string]string)
+
+	for i, segment := range t.segments {
+		if segment == "#" {
+			return params, true
+		}
+		if i >= len(levels) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(segment, "{"):
+			if levels[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = levels[i]
+		case segment == "+":
+		case segment != levels[i]:
+			return nil, false
+		}
+	}
+	if len(levels) != len(t.segments) {
+		return nil, false
+	}
+	return params, true
+}
+
+// render fills the placeholders of the template from params.
+func (t topicTemplate) render(params map[string]string) (string, error) {
+	levels := make([]string, len(t.segments))
+	for i, segment := range t.segments {
+		if segment == "+" || segment == "#" {
+			return "", fmt.Errorf("topic template %q has wildcards and cannot be published to", t.raw)
+		}
+		if strings.HasPrefix(segment, "{") {
+			name := strings.Trim(segment, "{}")
+			value, ok := params[name]
+			if !ok || value == "" {
+				return "", fmt.Errorf("no value for {%s} in topic template %q", name, t.raw)
+			}
+			levels[i] = value
+			continue
+		}
+		levels[i] = segment
+	}
+	return strings.Join(levels, "/"), nil
+}
+
+// topicRoute is a topic that matched one of the configured templates.
+type topicRoute struct {
+	boardID string
+	params  map[string]string
+}
+
+// topicRouter resolves incoming topics against the configured templates and
+// builds outgoing command topics. It remembers the placeholders each known
+// board last published with, so a command to a board of tenant "farm-a" goes
+// out on that tenant's topic. The tenant is also stored on the board, for
+// boards that have not published since a restart.
+type topicRouter struct {
+	db             *gorm.DB
+	telemetry      []topicTemplate
+	status         []topicTemplate
+	commandAck     []topicTemplate
+	ota            []topicTemplate
+	command        topicTemplate
+	allowedTenants map[string]bool
+
+	mutex       sync.RWMutex
+	boardParams map[string]map[string]string
+}
+
+func newTopicRouter(conf *config.MQTT, database *gorm.DB) (*topicRouter, error) {
+	router := &topicRouter{db: database, boardParams: make(map[string]map[string]string)}
+
+	var err error
+	if router.telemetry, err = parseInboundTemplates(conf.TopicTelemetry); err != nil {
+		return nil, err
+	}
+	if router.status, err = parseInboundTemplates(conf.TopicStatus); err != nil {
+		return nil, err
+	}
+	if router.commandAck, err = parseInboundTemplates(conf.TopicCommandAck); err != nil {
+		return nil, err
+	}
+	if router.ota, err = parseInboundTemplates(conf.TopicOta); err != nil {
+		return nil, err
+	}
+	if router.command, err = parseTopicTemplate(conf.TopicCommand); err != nil {
+		return nil, err
+	}
+	if !router.command.hasPlaceholder(boardPlaceholder) {
+		return nil, fmt.Errorf("command topic template %q needs a {board} placeholder", conf.TopicCommand)
+	}
+
+	if len(conf.AllowedTenants) > 0 {
+		router.allowedTenants = make(map[string]bool, len(conf.AllowedTenants))
+		for _, tenant := range conf.AllowedTenants {
+			router.allowedTenants[tenant] = true
+		}
+	}
+	return router, nil
+}
+
+func parseInboundTemplates(raw []string) ([]topicTemplate, error) {
+	templates := make([]topicTemplate, 0, len(raw))
+	for _, value := range raw {
+		template, err := parseTopicTemplate(value)
+		if err != nil {
+			return nil, err
+		}
+		if !template.hasPlaceholder(boardPlaceholder) {
+			return nil, fmt.Errorf("topic template %q needs a {board} placeholder", value)
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
+}
+
+func subscriptions(templates []topicTemplate) []string {
+	filters := make([]string, 0, len(templates))
+	for _, template := range templates {
+		filters = append(filters, template.subscription())
+	}
+	return filters
+}
+
+func (r *topicRouter) routeTelemetry(topic string) (topicRoute, error) {
+	return r.route(r.telemetry, topic)
+}
+
+func (r *topicRouter) routeStatus(topic string) (topicRoute, error) {
+	return r.route(r.status, topic)
+}
+
+func (r *topicRouter) routeCommandAck(topic string) (topicRoute, error) {
+	return r.route(r.commandAck, topic)
+}
+
+func (r *topicRouter) routeOta(topic string) (topicRoute, error) {
+	return r.route(r.ota, topic)
+}
+
+func (r *topicRouter) route(templates []topicTemplate, topic string) (topicRoute, error) {
+	for _, template := range templates {
+		params, ok := template.match(topic)
+		if !ok {
+			continue
+		}
+		if tenant, ok := params[tenantPlaceholder]; ok && r.allowedTenants != nil && !r.allowedTenants[tenant] {
+			return topicRoute{}, fmt.Errorf("tenant %q is not allowed", tenant)
+		}
+
+		return topicRoute{boardID: params[boardPlaceholder], params: params}, nil
+	}
+	return topicRoute{}, fmt.Errorf("topic %s matches no configured template", topic)
+}
+
+// boardOf returns the board a topic belongs to without remembering its
+// placeholders, for checking topics that were not received by the backend.
+func (r *topicRouter) boardOf(templates []topicTemplate, topic string) (string, bool) {
+	for _, template := range templates {
+		params, ok := template.match(topic)
+		if !ok {
+			continue
+		}
+		if tenant, ok := params[tenantPlaceholder]; ok && r.allowedTenants != nil && !r.allowedTenants[tenant] {
+			return "", false
+		}
+		return params[boardPlaceholder], true
+	}
+	return "", false
+}
+
+func (r *topicRouter) UplinkBoard(topic string) (string, bool) {
+	for _, templates := range [][]topicTemplate{r.telemetry, r.status, r.commandAck, r.ota} {
+		if boardID, ok := r.boardOf(templates, topic); ok {
+			return boardID, true
+		}
+	}
+	return "", false
+}
+
+func (r *topicRouter) DownlinkBoard(topic string) (string, bool) {
+	return r.boardOf([]topicTemplate{r.command}, topic)
+}
+
+// remember records the placeholders a board published with. It is only called
+// once the message was validated, so a topic naming an unknown board, or a
+// board of no user, cannot redirect commands or grow the map.
+func (r *topicRouter) remember(route topicRoute) {
+	r.mutex.Lock()
+	previous := r.boardParams[route.boardID]
+	r.boardParams[route.boardID] = route.params
+	r.mutex.Unlock()
+
+	if tenant, ok := route.params[tenantPlaceholder]; ok && (previous == nil || previous[tenantPlaceholder] != tenant) {
+		r.storeTenant(route.boardID, tenant)
+	}
+}
+
+// forget drops what was remembered about a board, for example once it was
+// unclaimed. The stored tenant is read again on its next command.
+func (r *topicRouter) forget(boardID string) {
+	r.mutex.Lock()
+	delete(r.boardParams, boardID)
+	r.mutex.Unlock()
+}
+
+// storeTenant records the tenant a board published under on its row.
+func (r *topicRouter) storeTenant(boardID string, tenant string) {
+	if r.db == nil {
+		return
+	}
+	err := r.db.Model(&entities.Board{}).
+		Where("board_id = ?", boardID).
+		UpdateColumn("tenant", tenant).Error
+	if err != nil {
+		log.Printf("Failed to store tenant %q of board %s: %v", tenant, boardID, err)
+	}
+}
+
+// commandTopic renders the downlink topic of a board. A board not heard from
+// since a restart gets the tenant stored on its row.
+func (r *topicRouter) commandTopic(boardID string) (string, error) {
+	params := map[string]string{boardPlaceholder: boardID}
+
+	r.mutex.RLock()
+	known, ok := r.boardParams[boardID]
+	for name, value := range known {
+		if name != boardPlaceholder {
+			params[name] = value
+		}
+	}
+	r.mutex.RUnlock()
+
+	if !ok && r.command.hasPlaceholder(tenantPlaceholder) && r.db != nil {
+		var board entities.Board
+		err := r.db.Select("tenant").Where("board_id = ?", boardID).Limit(1).Find(&board).Error
+		if err != nil {
+			return "", fmt.Errorf("error loading tenant of board %s: %w", boardID, err)
+		}
+		if board.Tenant != nil {
+			params[tenantPlaceholder] = *board.Tenant
+			r.mutex.Lock()
+			if _, routed := r.boardParams[boardID]; !routed {
+				r.boardParams[boardID] = map[string]string{tenantPlaceholder: *board.Tenant}
+			}
+			r.mutex.Unlock()
+		}
+	}
+
+	return r.command.render(params)
+}
+
+// RenderBoardTopic fills a configured topic template for one board, as a
+// device would publish to it. params supplies any other placeholders.
+func RenderBoardTopic(template string, boardID string, params map[string]string) (string, error) {
+	parsed, err := parseTopicTemplate(template)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]string{boardPlaceholder: boardID}
+	for name, value := range params {
+		if name != boardPlaceholder {
+			values[name] = value
+		}
+	}
+	return parsed.render(values)
+}
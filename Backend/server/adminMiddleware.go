@@ -0,0 +1,32 @@
+package server
+
+import (
+	"main/duckweed/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireAdmin only lets through users listed in Server.AdminUserIDs. It must
+// run after the JWT middleware.
+func (s *FiberServer) requireAdmin(c *fiber.Ctx) error {
+	userID, err := utils.GetUserIDFromToken(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Could not identify user from token.",
+			"data":    err.Error(),
+		})
+	}
+
+	for _, adminID := range s.conf.Server.AdminUserIDs {
+		if adminID == userID {
+			return c.Next()
+		}
+	}
+
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Admin access required.",
+		"data":    nil,
+	})
+}
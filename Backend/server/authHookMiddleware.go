@@ -0,0 +1,33 @@
+package server
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// requireAuthHookSecret only lets the broker through to the auth hook routes
+// when it sends MQTT.AuthHookSecret in the X-Auth-Hook-Secret header. Without
+// a secret configured every request is refused, since the routes would
+// otherwise let anyone test board passwords and ACLs.
+func (s *FiberServer) requireAuthHookSecret(c *fiber.Ctx) error {
+	secret := s.conf.MQTT.AuthHookSecret
+	if secret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Auth hook secret is not configured.",
+			"data":    nil,
+		})
+	}
+
+	given := c.Get("X-Auth-Hook-Secret")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(secret)) == 1 {
+		return c.Next()
+	}
+
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Invalid auth hook secret.",
+		"data":    nil,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/gofiber/fiber/v2/middleware/cors"
 
@@ -25,24 +26,56 @@ type FiberServer struct {
 	app     *fiber.App
 	db      database.Database
 	conf    *config.Config
-	clients map[*websocket.Conn]map[string]bool // map to track connected clients
+	clients map[*websocket.Conn]*socketClient // map to track connected clients
 	mutex   sync.Mutex
+
+	commandPublisher usecases.CommandPublisher
+	ingestionPipeline usecases.IngestionPipeline
+	boardTopics usecases.BoardTopics
+	brokerConnection usecases.BrokerConnection
 }
 
 func NewFiberServer(conf *config.Config, db database.Database) Server {
-	fiberApp := fiber.New()
+	fiberApp := fiber.New(fiber.Config{
+		// Firmware images are uploaded in one multipart request.
+		BodyLimit: max(conf.Firmware.MaxUploadMB, 4) * 1024 * 1024,
+	})
 
 	server := &FiberServer{
 		app:     fiberApp,
 		db:      db,
 		conf:    conf,
-		clients: make(map[*websocket.Conn]map[string]bool),
+		clients: make(map[*websocket.Conn]*socketClient),
 		mutex:   sync.Mutex{},
 	}
 
 	return server
 }
 
+// SetCommandPublisher attaches the downlink channel used by the command routes.
+// It must be called before Start.
+func (s *FiberServer) SetCommandPublisher(publisher usecases.CommandPublisher) {
+	s.commandPublisher = publisher
+}
+
+// SetIngestionPipeline attaches the telemetry pipeline used by the health and
+// dead-letter routes. It must be called before Start.
+func (s *FiberServer) SetIngestionPipeline(pipeline usecases.IngestionPipeline) {
+	s.ingestionPipeline = pipeline
+}
+
+// SetBoardTopics attaches the MQTT topic layout used by the broker auth hook.
+// It must be called before Start.
+func (s *FiberServer) SetBoardTopics(topics usecases.BoardTopics) {
+	s.boardTopics = topics
+}
+
+// SetBrokerConnection attaches the MQTT connection reported by the health
+// route. It must be called before Start.
+func (s *FiberServer) SetBrokerConnection(connection usecases.BrokerConnection) {
+	s.brokerConnection = connection
+}
+
 func (s *FiberServer) Start() {
 	s.app.Use(recover.New())
 	s.app.Use(logger.New())
@@ -51,6 +84,42 @@ func (s *FiberServer) Start() {
 		return c.SendString("OK")
 	})
 
+	s.app.Get("v1/health/ingestion", func(c *fiber.Ctx) error {
+		if s.ingestionPipeline == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Ingestion pipeline is not running.",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "Ingestion metrics retrieved successfully.",
+			"data":    s.ingestionPipeline.IngestionMetrics(),
+		})
+	})
+
+	s.app.Get("v1/health/mqtt", func(c *fiber.Ctx) error {
+		if s.brokerConnection == nil {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":  "error",
+				"message": "MQTT client is not running.",
+			})
+		}
+		health := s.brokerConnection.ConnectionHealth()
+		if !health.Healthy {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"status":  "error",
+				"message": "MQTT connection is down or a subscription failed.",
+				"data":    health,
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status":  "success",
+			"message": "MQTT connection is healthy.",
+			"data":    health,
+		})
+	})
+
 	s.app.Use(cors.New(cors.Config{
 		AllowOrigins:     s.conf.Server.AllowOrigins,
 		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
@@ -62,7 +131,7 @@ func (s *FiberServer) Start() {
 		log.Fatal("JWT Secret Key not configured!")
 	}
 
-	jwtMiddleware := jwtware.New(jwtware.Config{
+	jwtConfig := jwtware.Config{
 		SigningKey: jwtware.SigningKey{
 			JWTAlg: jwtware.HS256,
 			Key:    []byte(jwtSecret),
@@ -77,7 +146,13 @@ func (s *FiberServer) Start() {
 			}
 		},
 		ContextKey: "user",
-	})
+	}
+	jwtMiddleware := jwtware.New(jwtConfig)
+	// Browsers cannot set headers on a WebSocket handshake, so the socket
+	// route also takes the token from the ?token= query parameter.
+	socketJwtConfig := jwtConfig
+	socketJwtConfig.TokenLookup = "header:Authorization,query:token"
+	socketJwtMiddleware := jwtware.New(socketJwtConfig)
 
 	// Repositories
 	userRepo := repositories.NewUserRepository(s.db.GetDb())
@@ -85,25 +160,70 @@ func (s *FiberServer) Start() {
 	educationRepo := repositories.NewEducationRepository(s.db.GetDb())
 	boardRepo := repositories.NewBoardRepository(s.db.GetDb())
 	boardRelationshipRepo := repositories.NewBoardRelationshipRepository(s.db.GetDb())
+	sensorLogRepo := repositories.NewSensorLogRepository(s.db.GetDb())
+	alertRepo := repositories.NewAlertRepository(s.db.GetDb())
+	boardCommandRepo := repositories.NewBoardCommandRepository(s.db.GetDb())
+	deadLetterRepo := repositories.NewDeadLetterRepository(s.db.GetDb())
+	sensorRepo := repositories.NewSensorRepository(s.db.GetDb())
+	boardChannelRepo := repositories.NewBoardChannelRepository(s.db.GetDb())
+	calibrationRepo := repositories.NewCalibrationRepository(s.db.GetDb())
+	firmwareRepo := repositories.NewFirmwareRepository(s.db.GetDb())
+	boardInvitationRepo := repositories.NewBoardInvitationRepository(s.db.GetDb())
+	pondRepo := repositories.NewPondRepository(s.db.GetDb())
+	boardStatusRepo := repositories.NewBoardStatusRepository(s.db.GetDb())
+	boardLivenessRepo := repositories.NewBoardLivenessRepository(s.db.GetDb())
+	livenessLeaderLock := repositories.NewAdvisoryLeaderLock(s.db.GetDb(), livenessLockKey)
 
 	// Use cases
 	userUseCase := usecases.NewUserUseCase(*userRepo)
-	pondHealthUseCase := usecases.NewpondHealthUseCase(*pondHealthRepo)
+	pondHealthUseCase := usecases.NewpondHealthUseCase(*pondHealthRepo, pondRepo)
 	educationUseCase := usecases.NewEducationUseCase(*educationRepo)
-	boardRelationshipUseCase := usecases.NewBoardRelationshipUseCase(boardRepo, boardRelationshipRepo)
-	boardUseCase := usecases.NewBoardUseCase(boardRepo)
+	boardRelationshipUseCase := usecases.NewBoardRelationshipUseCase(boardRepo, boardRelationshipRepo, sensorLogRepo, s.ingestionPipeline, s)
+	boardUseCase := usecases.NewBoardUseCase(boardRepo, boardRelationshipRepo, s.ingestionPipeline, s)
+	boardSharingUseCase := usecases.NewBoardSharingUseCase(boardRepo, boardRelationshipRepo, boardInvitationRepo, *userRepo, s)
+	provisioningUseCase := usecases.NewProvisioningUseCase(boardRepo)
+	mqttAuthUseCase := usecases.NewMqttAuthUseCase(boardRepo, s.boardTopics, s.mqttAuthOptions())
+	channelResolver := usecases.NewChannelResolver(boardChannelRepo, sensorRepo)
+	sensorLogUseCase := usecases.NewSensorLogUseCase(sensorLogRepo, boardRepo, boardRelationshipRepo, channelResolver)
+	boardChannelUseCase := usecases.NewBoardChannelUseCase(boardChannelRepo, sensorLogRepo, sensorRepo, boardRepo, boardRelationshipRepo, channelResolver)
+	calibrationUseCase := usecases.NewCalibrationUseCase(calibrationRepo, boardRepo, boardRelationshipRepo)
+	deviceTelemetryUseCase := usecases.NewDeviceTelemetryUseCase(boardRepo, boardRelationshipRepo, s.ingestionPipeline)
+	alertUseCase := usecases.NewAlertUseCase(alertRepo, boardRepo, boardRelationshipRepo)
+	boardStatusUseCase := usecases.NewBoardStatusUseCase(boardStatusRepo, boardRepo, boardRelationshipRepo, pondRepo)
+	pondUseCase := usecases.NewPondUseCase(pondRepo, boardRepo, boardRelationshipRepo, sensorLogRepo, alertRepo, *pondHealthRepo)
+	boardCommandUseCase := usecases.NewBoardCommandUseCase(boardCommandRepo, boardRepo, boardRelationshipRepo, s.commandPublisher)
+	deadLetterUseCase := usecases.NewDeadLetterUseCase(deadLetterRepo, s.ingestionPipeline)
+	firmwareUseCase := usecases.NewFirmwareUseCase(firmwareRepo, boardRepo, boardRelationshipRepo, boardCommandRepo, s.commandPublisher, s.firmwareOptions())
+	livenessUseCase := usecases.NewLivenessUseCase(boardLivenessRepo, livenessLeaderLock, s.livenessPolicy())
 
 	// Handlers
 	userHandler := handlers.NewUserHandler(userUseCase)
 	pondHealthHandler := handlers.NewPondHealthHandler(pondHealthUseCase)
+	pondHandler := handlers.NewPondHandler(pondUseCase)
+	boardStatusHandler := handlers.NewBoardStatusHandler(boardStatusUseCase)
 	educationHandler := handlers.NewEducationHandler(educationUseCase)
 	boardRelationShipHandler := handlers.NewBoardRelationshipHandler(boardRelationshipUseCase)
 	boardHandler := handlers.NewBoardHandler(boardUseCase)
+	boardSharingHandler := handlers.NewBoardSharingHandler(boardSharingUseCase)
+	provisioningHandler := handlers.NewProvisioningHandler(provisioningUseCase)
+	mqttAuthHandler := handlers.NewMqttAuthHandler(mqttAuthUseCase)
+	sensorLogHandler := handlers.NewSensorLogHandler(sensorLogUseCase)
+	alertHandler := handlers.NewAlertHandler(alertUseCase)
+	boardCommandHandler := handlers.NewBoardCommandHandler(boardCommandUseCase)
+	deadLetterHandler := handlers.NewDeadLetterHandler(deadLetterUseCase)
+	boardChannelHandler := handlers.NewBoardChannelHandler(boardChannelUseCase)
+	calibrationHandler := handlers.NewCalibrationHandler(calibrationUseCase)
+	deviceTelemetryHandler := handlers.NewDeviceTelemetryHandler(deviceTelemetryUseCase)
+	firmwareHandler := handlers.NewFirmwareHandler(firmwareUseCase)
 
 
 	// Routes
 	apivisit := s.app.Group("/visit")
+	ingest := s.app.Group("/ingest")
+	ota := s.app.Group("/ota")
+	mqttAuth := s.app.Group("/mqtt", s.requireAuthHookSecret)
 	api := s.app.Group("/v1", jwtMiddleware)
+	admin := api.Group("/admin", s.requireAdmin)
 
 	// User routes
 	api.Get("/users", userHandler.GetAllUsers)
@@ -117,23 +237,176 @@ func (s *FiberServer) Start() {
 	api.Get("/pondhealthByUserId/:userid", pondHealthHandler.GetPondHealthByUserID)
 	api.Post("/PostPondHealth/", pondHealthHandler.PostPondHealth)
 
+	// Pond routes
+	api.Get("/ponds", pondHandler.GetPonds)
+	api.Post("/ponds", pondHandler.CreatePond)
+	api.Get("/ponds/:id", pondHandler.GetPond)
+	api.Patch("/ponds/:id", pondHandler.UpdatePond)
+	api.Delete("/ponds/:id", pondHandler.DeletePond)
+	api.Get("/ponds/:id/overview", pondHandler.GetOverview)
+	api.Put("/boards/:board_id/pond", pondHandler.AssignBoard)
+	api.Delete("/boards/:board_id/pond", pondHandler.UnassignBoard)
+
 	// Education routes
 	api.Get("/education", educationHandler.GetAllEducation)
 	api.Get("/education/:id", educationHandler.GetEducationByID)
 
 	// Board Relationship routes
 	api.Post("/board-relationships", boardRelationShipHandler.CreateBoardRelationship)
+	api.Get("/me/boards", boardRelationShipHandler.GetUserBoards)
+	api.Delete("/boards/:board_id/relationship", boardRelationShipHandler.DeleteBoardRelationship)
 
 	// Board routes
 	api.Get("/board/:board_id", boardHandler.GetBoardByBoardID)
+	api.Patch("/boards/:board_id", boardHandler.UpdateBoard)
+	api.Post("/boards/:board_id/disable", boardHandler.DisableBoard)
+	api.Post("/boards/:board_id/enable", boardHandler.EnableBoard)
+	api.Get("/boards/:board_id/status-history", boardStatusHandler.GetStatusHistory)
+
+	// Board sharing routes. Owners invite operators and viewers, who get
+	// access once they accept.
+	api.Get("/boards/:board_id/members", boardSharingHandler.GetBoardMembers)
+	api.Patch("/boards/:board_id/members/:user_id", boardSharingHandler.UpdateMemberRole)
+	api.Delete("/boards/:board_id/members/:user_id", boardSharingHandler.RemoveMember)
+	api.Post("/boards/:board_id/members/:user_id/disable", boardSharingHandler.DisableMember)
+	api.Post("/boards/:board_id/members/:user_id/enable", boardSharingHandler.EnableMember)
+	api.Post("/boards/:board_id/transfer-ownership", boardSharingHandler.TransferOwnership)
+	api.Get("/boards/:board_id/invitations", boardSharingHandler.GetBoardInvitations)
+	api.Post("/boards/:board_id/invitations", boardSharingHandler.InviteUser)
+	api.Delete("/boards/:board_id/invitations/:invitation_id", boardSharingHandler.RevokeInvitation)
+	api.Get("/me/invitations", boardSharingHandler.GetMyInvitations)
+	api.Post("/invitations/:invitation_id/accept", boardSharingHandler.AcceptInvitation)
+	api.Post("/invitations/:invitation_id/decline", boardSharingHandler.DeclineInvitation)
+
+	// Admin provisioning routes. Boards must be provisioned before users can
+	// claim them.
+	admin.Post("/boards/provision", provisioningHandler.ProvisionBoards)
+	admin.Post("/boards/:board_id/claim-code", provisioningHandler.IssueClaimCode)
+	admin.Post("/boards/:board_id/mqtt-credentials", provisioningHandler.RotateMqttCredentials)
+
+	// Sensor routes
+	api.Get("/sensors", handlers.GetAllSensors(s.db.GetDb()))
+	api.Get("/sensors/:id", handlers.GetSensorByID(s.db.GetDb()))
+	admin.Post("/sensors", handlers.CreateSensor(s.db.GetDb()))
+
+	// Telemetry routes
+	api.Get("/boards/:board_id/telemetry", sensorLogHandler.GetTelemetryHistory)
+
+	// Board channel routes
+	api.Get("/boards/:board_id/channels", boardChannelHandler.GetChannels)
+	api.Put("/boards/:board_id/channels/:channel", boardChannelHandler.SetChannel)
+	api.Delete("/boards/:board_id/channels/:channel", boardChannelHandler.DeleteChannel)
+
+	// Calibration routes
+	api.Get("/boards/:board_id/calibrations", calibrationHandler.GetCalibrations)
+	api.Post("/boards/:board_id/calibrations", calibrationHandler.CreateSession)
+	api.Post("/boards/:board_id/calibrations/recompute", calibrationHandler.Recompute)
+
+	// Alert routes
+	api.Get("/boards/:board_id/alert-rules", alertHandler.GetAlertRules)
+	api.Post("/boards/:board_id/alert-rules", alertHandler.CreateAlertRule)
+	api.Delete("/boards/:board_id/alert-rules/:rule_id", alertHandler.DeleteAlertRule)
+	api.Get("/boards/:board_id/alerts", alertHandler.GetAlertIncidents)
+
+	// Board command routes
+	api.Post("/boards/:board_id/commands", boardCommandHandler.SendCommand)
+	api.Get("/boards/:board_id/commands", boardCommandHandler.GetCommands)
+	api.Get("/boards/:board_id/commands/:correlation_id", boardCommandHandler.GetCommand)
+
+	// Admin dead-letter routes
+	admin.Get("/dead-letters", deadLetterHandler.GetDeadLetters)
+	admin.Delete("/dead-letters", deadLetterHandler.PurgeDeadLetters)
+	admin.Post("/dead-letters/replay", deadLetterHandler.ReplayBoardDeadLetters)
+	admin.Get("/dead-letters/:id", deadLetterHandler.GetDeadLetter)
+	admin.Post("/dead-letters/:id/replay", deadLetterHandler.ReplayDeadLetter)
+	admin.Delete("/dead-letters/:id", deadLetterHandler.DeleteDeadLetter)
+
+	// Device ingest routes, authenticated with the board's device token
+	api.Post("/boards/:board_id/device-token", deviceTelemetryHandler.IssueDeviceToken)
+	ingest.Post("/boards/:board_id/telemetry", deviceTelemetryHandler.IngestTelemetry)
+
+	// Firmware routes. Boards download images from /ota with the signed link
+	// sent in the ota command instead of a JWT.
+	admin.Get("/firmware/releases", firmwareHandler.GetReleases)
+	admin.Post("/firmware/releases", firmwareHandler.UploadRelease)
+	admin.Get("/firmware/releases/:release_id", firmwareHandler.GetRelease)
+	admin.Get("/firmware/rollouts", firmwareHandler.GetRollouts)
+	admin.Post("/firmware/rollouts", firmwareHandler.CreateRollout)
+	admin.Get("/firmware/rollouts/:rollout_id", firmwareHandler.GetRollout)
+	admin.Get("/firmware/rollouts/:rollout_id/updates", firmwareHandler.GetRolloutUpdates)
+	admin.Post("/firmware/rollouts/:rollout_id/advance", firmwareHandler.AdvanceRollout)
+	admin.Post("/firmware/rollouts/:rollout_id/halt", firmwareHandler.HaltRollout)
+	admin.Post("/firmware/rollouts/:rollout_id/resume", firmwareHandler.ResumeRollout)
+	admin.Post("/firmware/rollouts/:rollout_id/cancel", firmwareHandler.CancelRollout)
+	api.Get("/boards/:board_id/firmware", firmwareHandler.GetBoardFirmware)
+	ota.Get("/firmware/:release_id", firmwareHandler.DownloadFirmware)
+
+	// Broker auth hook routes, for mosquitto-go-auth's HTTP backend and for
+	// EMQX's HTTP authentication and authorization
+	mqttAuth.Post("/auth/user", mqttAuthHandler.MosquittoUser)
+	mqttAuth.Post("/auth/superuser", mqttAuthHandler.MosquittoSuperuser)
+	mqttAuth.Post("/auth/acl", mqttAuthHandler.MosquittoAcl)
+	mqttAuth.Post("/emqx/authn", mqttAuthHandler.EmqxAuthn)
+	mqttAuth.Post("/emqx/authz", mqttAuthHandler.EmqxAuthz)
 
 	// WebSocket Route
-	apivisit.Get("/ws/:userId/:boardId", s.websocketHandler)
+	apivisit.Get("/ws/:boardId", socketJwtMiddleware, s.websocketHandler)
 
 	// Start background tasks
-	go s.monitorBoardStatus()
+	go s.monitorBoardStatus(livenessUseCase)
+	go s.recheckSocketAccess()
+	go s.expireBoardCommands(boardCommandUseCase)
+	go s.expireFirmwareUpdates(firmwareUseCase)
 
 	// Start server
 	serverUrl := fmt.Sprintf(":%d", s.conf.Server.Port)
 	log.Fatal(s.app.Listen(serverUrl))
 }
+
+// firmwareOptions turns the firmware configuration into the settings of the
+// firmware use case.
+func (s *FiberServer) firmwareOptions() usecases.FirmwareOptions {
+	conf := s.conf.Firmware
+	baseURL := conf.PublicBaseURL
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("http://localhost:%d", s.conf.Server.Port)
+	}
+	secret := conf.SigningSecret
+	if secret == "" {
+		secret = s.conf.Server.JwtSecret
+	}
+	return usecases.FirmwareOptions{
+		StoragePath:       conf.StoragePath,
+		BaseURL:           baseURL,
+		SigningSecret:     []byte(secret),
+		URLTTL:            time.Duration(conf.DownloadURLTTLMinutes) * time.Minute,
+		FailureThreshold:  conf.FailureThreshold,
+		MinFailureSamples: conf.MinFailureSamples,
+	}
+}
+
+// livenessPolicy turns the liveness configuration into when boards count as
+// offline.
+func (s *FiberServer) livenessPolicy() repositories.LivenessPolicy {
+	conf := s.conf.Liveness
+	return repositories.LivenessPolicy{
+		DefaultInterval: time.Duration(conf.DefaultIntervalSeconds) * time.Second,
+		GraceMultiplier: conf.GraceMultiplier,
+		MinTimeout:      time.Duration(conf.MinTimeoutSeconds) * time.Second,
+	}
+}
+
+// mqttAuthOptions tells the auth hook how the backend itself logs in to the
+// broker.
+func (s *FiberServer) mqttAuthOptions() usecases.MqttAuthOptions {
+	conf := s.conf.MQTT
+	username := conf.Username
+	if username == "" {
+		username = conf.ClientID
+	}
+	return usecases.MqttAuthOptions{
+		BackendClientID: conf.ClientID,
+		BackendUsername: username,
+		BackendPassword: conf.Password,
+	}
+}
@@ -2,13 +2,19 @@ package server
 
 import (
 	"main/duckweed/entities"
+	"main/duckweed/usecases"
 )
 
 type Server interface {
   Start()
   // BroadcastSensorData(data *entities.SensorData)
-  BroadcastTelemetryData(boardId string,data *entities.SensorLog)
+  BroadcastTelemetryData(boardId string, data *entities.TelemetryPointDto)
   BroadcastStatus(status *entities.Board)
+  BroadcastAlert(boardId string, incident *entities.AlertIncident)
+  SetCommandPublisher(publisher usecases.CommandPublisher)
+  SetIngestionPipeline(pipeline usecases.IngestionPipeline)
+  SetBoardTopics(topics usecases.BoardTopics)
+  SetBrokerConnection(connection usecases.BrokerConnection)
   // BroadcastSensorDataToClient(clientID string, data *entities.SensorData)
   // RegisterClient(clientID string, connection interface{})
 }
\ No newline at end of file
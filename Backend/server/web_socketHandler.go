@@ -3,24 +3,35 @@ package server
 import (
 	"encoding/json"
 	"log"
-	"strconv"
 	"time"
 
 	"main/duckweed/entities"
+	"main/duckweed/usecases"
+	"main/duckweed/utils"
 
 	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 )
 
+// socketAccessRecheckInterval bounds how long a connection outlives its
+// user's access on another replica.
+const socketAccessRecheckInterval = 30 * time.Second
+
+// socketClient is one WebSocket connection and the boards it watches.
+type socketClient struct {
+	userID uint
+	boards map[string]bool
+}
+
+// websocketHandler streams a board's live data to a signed-in user. The user
+// comes from the JWT, never from the URL.
 func (s *FiberServer) websocketHandler(c *fiber.Ctx) error {
-	// 1) Extract userId and boardId from path params
-	userIdStr := c.Params("userId")
-	uid, err := strconv.ParseUint(userIdStr, 10, 32)
+	// 1) Take the user from the token and the board from the path
+	userId, err := utils.GetUserIDFromToken(c)
 	if err != nil {
-		log.Println("Invalid userId param:", userIdStr, err)
-		return fiber.ErrBadRequest
+		log.Println("Could not identify WebSocket user from token:", err)
+		return fiber.ErrUnauthorized
 	}
-	userId := uint(uid)
 
 	boardId := c.Params("boardId")
 	if boardId == "" {
@@ -44,9 +55,9 @@ func (s *FiberServer) websocketHandler(c *fiber.Ctx) error {
 			// 4) Register connection
 			s.mutex.Lock()
 			if s.clients[conn] == nil {
-				s.clients[conn] = make(map[string]bool)
+				s.clients[conn] = &socketClient{userID: userId, boards: make(map[string]bool)}
 			}
-			s.clients[conn][boardId] = true
+			s.clients[conn].boards[boardId] = true
 			s.mutex.Unlock()
 
 			defer func() {
@@ -74,20 +85,30 @@ func (s *FiberServer) websocketHandler(c *fiber.Ctx) error {
 	return fiber.ErrUpgradeRequired
 }
 
+// isUserSubscribedToBoard reports whether the user may watch the board's live
+// data, which every role down to viewer may unless their access is disabled.
 func (s *FiberServer) isUserSubscribedToBoard(userID uint, boardID string) bool {
-	var relationship entities.BoardRelationship
-	result := s.db.GetDb().Where("user_id = ? AND board_id = ?", userID, boardID).First(&relationship)
-	return result.Error == nil // Return true if relationship exists
+	allowed, err := s.boardAccess(userID, boardID)
+	return err == nil && allowed
+}
+
+func (s *FiberServer) boardAccess(userID uint, boardID string) (bool, error) {
+	var relationships []entities.BoardRelationship
+	err := s.db.GetDb().Where("user_id = ? AND board_id = ?", userID, boardID).Limit(1).Find(&relationships).Error
+	if err != nil || len(relationships) == 0 {
+		return false, err
+	}
+	return relationships[0].Grants(entities.BoardRoleViewer), nil
 }
 
 // BroadcastTelemetryData sends telemetry data to clients subscribed to a specific board.
-func (s *FiberServer) BroadcastTelemetryData(boardID string, data *entities.SensorLog) {
+func (s *FiberServer) BroadcastTelemetryData(boardID string, data *entities.TelemetryPointDto) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	envelope := struct {
-		Type string              `json:"type"`
-		Data *entities.SensorLog `json:"data"`
+		Type string                      `json:"type"`
+		Data *entities.TelemetryPointDto `json:"data"`
 	}{
 		Type: "telemetry",
 		Data: data,
@@ -98,10 +119,37 @@ func (s *FiberServer) BroadcastTelemetryData(boardID string, data *entities.Sens
 		return
 	}
 
-	for conn, boardMap := range s.clients {
-		if _, ok := boardMap[boardID]; ok {
+	s.broadcastToBoard(boardID, payload)
+}
+
+// BroadcastAlert sends an alert incident that was opened or closed to clients subscribed to the board.
+func (s *FiberServer) BroadcastAlert(boardID string, incident *entities.AlertIncident) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	envelope := struct {
+		Type string                  `json:"type"`
+		Data *entities.AlertIncident `json:"data"`
+	}{
+		Type: "alert",
+		Data: incident,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Println("Error marshaling alert incident:", err)
+		return
+	}
+
+	s.broadcastToBoard(boardID, payload)
+}
+
+// broadcastToBoard writes the payload to every client subscribed to the board.
+// The caller must hold s.mutex.
+func (s *FiberServer) broadcastToBoard(boardID string, payload []byte) {
+	for conn, client := range s.clients {
+		if client.boards[boardID] {
 			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
-				log.Println("Error sending telemetry to client:", err)
+				log.Println("Error sending message to client:", err)
 				conn.Close()
 				delete(s.clients, conn)
 			}
@@ -109,8 +157,7 @@ func (s *FiberServer) BroadcastTelemetryData(boardID string, data *entities.Sens
 	}
 }
 
-// BroadcastStatus sends board status updates to all connected clients.
-// Note: This broadcasts to ALL clients. You might want to refine this to broadcast only to subscribed users.
+// BroadcastStatus sends a board's status to the clients watching the board.
 func (s *FiberServer) BroadcastStatus(status *entities.Board) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -129,43 +176,127 @@ func (s *FiberServer) BroadcastStatus(status *entities.Board) {
 		return
 	}
 
-	for client := range s.clients {
-		if err := client.WriteMessage(websocket.TextMessage, payload); err != nil {
-			log.Println("Broadcast Status Error:", err)
-			client.Close()
-			delete(s.clients, client)
+	s.broadcastToBoard(status.BoardID, payload)
+}
+
+// RevokeBoardAccess closes the user's connections watching the board, after
+// their access to it was disabled or removed.
+func (s *FiberServer) RevokeBoardAccess(userID uint, boardID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for conn, client := range s.clients {
+		if client.userID == userID && client.boards[boardID] {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"error":"access to board revoked"}`))
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+}
+
+// recheckSocketAccess periodically closes connections whose user lost access
+// to the board they watch. RevokeBoardAccess only reaches the connections of
+// the replica that made the change; this catches those of the others.
+func (s *FiberServer) recheckSocketAccess() {
+	ticker := time.NewTicker(socketAccessRecheckInterval)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		type watch struct {
+			userID  uint
+			boardID string
+		}
+		s.mutex.Lock()
+		watches := make(map[watch]bool)
+		for _, client := range s.clients {
+			for boardID := range client.boards {
+				watches[watch{client.userID, boardID}] = true
+			}
+		}
+		s.mutex.Unlock()
+
+		for w := range watches {
+			allowed, err := s.boardAccess(w.userID, w.boardID)
+			if err != nil {
+				log.Printf("Error rechecking WebSocket access: %v", err)
+				break
+			}
+			if !allowed {
+				log.Printf("Closing WebSocket of user %d on board %s: access revoked", w.userID, w.boardID)
+				s.RevokeBoardAccess(w.userID, w.boardID)
+			}
 		}
 	}
 }
 
-// monitorBoardStatus periodically checks for inactive boards and updates their status.
-func (s *FiberServer) monitorBoardStatus() {
-	ticker := time.NewTicker(30 * time.Second)
+// livenessLockKey is the Postgres advisory lock the replicas compete for to
+// run the liveness sweep.
+const livenessLockKey int64 = 0x6477_6c69_7665
+
+// monitorBoardStatus periodically marks boards that stopped reporting as
+// inactive. Only the replica holding the liveness lock sweeps.
+func (s *FiberServer) monitorBoardStatus(livenessUseCase usecases.LivenessUseCaseInterface) {
+	ticker := time.NewTicker(time.Duration(max(s.conf.Liveness.SweepIntervalSeconds, 1)) * time.Second)
 	defer ticker.Stop()
 
+	leading := false
 	for {
 		<-ticker.C
-		var boards []entities.Board
-		if err := s.db.GetDb().Find(&boards).Error; err != nil {
+		leader, boards, err := livenessUseCase.SweepOverdueBoards()
+		if leader != leading {
+			leading = leader
+			if leader {
+				log.Println("Took over the board liveness sweep")
+			} else {
+				log.Println("Another replica runs the board liveness sweep")
+			}
+		}
+		if err != nil {
 			log.Printf("Error checking board statuses: %v", err)
 			continue
 		}
 
-		now := time.Now()
 		for i := range boards {
-			board := &boards[i]
-			if board.LastSeen != nil && now.Sub(*board.LastSeen) > time.Minute {
-				if board.BoardStatus == nil || *board.BoardStatus != entities.BoardStatusInactive {
-					inactiveStatus := entities.BoardStatusInactive
-					board.BoardStatus = &inactiveStatus
-					if err := s.db.GetDb().Save(board).Error; err != nil {
-						log.Printf("Error updating board status to offline for %s: %v", board.BoardID, err)
-						continue
-					}
-					log.Printf("Marked board %s as OFFLINE", board.BoardID)
-					s.BroadcastStatus(board)
-				}
-			}
+			log.Printf("Marked board %s as OFFLINE", boards[i].BoardID)
+			s.BroadcastStatus(&boards[i])
+		}
+	}
+}
+
+// expireBoardCommands periodically moves commands that were never acknowledged to timed_out.
+func (s *FiberServer) expireBoardCommands(commandUseCase usecases.BoardCommandUseCaseInterface) {
+	timeout := time.Duration(s.conf.MQTT.CommandTimeoutSeconds) * time.Second
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		expired, err := commandUseCase.ExpirePendingCommands(timeout)
+		if err != nil {
+			log.Printf("Error expiring board commands: %v", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("Marked %d board commands as timed out", expired)
+		}
+	}
+}
+
+func (s *FiberServer) expireFirmwareUpdates(firmwareUseCase usecases.FirmwareUseCaseInterface) {
+	timeout := time.Duration(s.conf.Firmware.UpdateTimeoutMinutes) * time.Minute
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		<-ticker.C
+		expired, err := firmwareUseCase.ExpireStaleUpdates(timeout)
+		if err != nil {
+			log.Printf("Error expiring firmware updates: %v", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("Marked %d firmware updates as failed after %s without a result", expired, timeout)
 		}
 	}
 }